@@ -43,6 +43,58 @@ func (m *JWTManager) Parse(tokenStr string) (jwt.MapClaims, error) {
 	return nil, errors.New("invalid claims")
 }
 
+// GenerateShareAccess 签发分享链接的访问凭证，输入正确的分享密码后使用。
+// 凭证不含 sub，不能作为用户令牌使用
+func (m *JWTManager) GenerateShareAccess(shareID uint, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"share": shareID,
+		"exp":   time.Now().Add(ttl).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(m.secret)
+}
+
+// ParseShareAccess 校验分享访问凭证，返回其对应的分享ID
+func (m *JWTManager) ParseShareAccess(tokenStr string) (uint, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+	shareID, ok := claims["share"].(float64)
+	if !ok || shareID <= 0 {
+		return 0, errors.New("invalid share access token")
+	}
+	return uint(shareID), nil
+}
+
+// GenerateShareDownload 签发分享中某个文件的下载凭证，在计入一次下载后使用，
+// 凭证有效期内对同一文件的续传不再计数。凭证不能作为访问凭证或用户令牌使用
+func (m *JWTManager) GenerateShareDownload(shareID, fileID uint, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"download_share": shareID,
+		"download_file":  fileID,
+		"exp":            time.Now().Add(ttl).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(m.secret)
+}
+
+// ParseShareDownload 校验分享下载凭证，返回其对应的分享ID与文件ID
+func (m *JWTManager) ParseShareDownload(tokenStr string) (uint, uint, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	shareID, ok := claims["download_share"].(float64)
+	fileID, ok2 := claims["download_file"].(float64)
+	if !ok || !ok2 || shareID <= 0 || fileID <= 0 {
+		return 0, 0, errors.New("invalid share download token")
+	}
+	return uint(shareID), uint(fileID), nil
+}
+
 func HashPassword(pw string) (string, error) {
 	b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
 	return string(b), err
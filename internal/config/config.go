@@ -22,6 +22,25 @@ type Config struct {
 
     JWTSecret      string
     JWTExpireHours string
+
+    UploadSessionTTLHours string
+    TrashRetentionDays    string
+
+    ConflictPolicy string
+
+    VersionMaxCount   string
+    VersionMaxAgeDays string
+
+    ExtractMaxEntries string
+    ExtractMaxSizeMB  string
+
+    DefaultQuotaMB string
+
+    ContentIndexMaxFileMB string
+    ContentIndexMaxTextKB string
+
+    ImageMaxDimension string
+    ImageCacheMaxMB   string
 }
 
 func getenv(key, def string) string {
@@ -47,5 +66,17 @@ func LoadFromEnv() *Config {
         S3UseSSL:        getenv("S3_USE_SSL", "false"),
         JWTSecret:       getenv("JWT_SECRET", "please_change_me"),
         JWTExpireHours:  getenv("JWT_EXPIRE_HOURS", "72"),
+        UploadSessionTTLHours: getenv("UPLOAD_SESSION_TTL_HOURS", "24"),
+        TrashRetentionDays:    getenv("TRASH_RETENTION_DAYS", "30"),
+        ConflictPolicy:        getenv("CONFLICT_POLICY", "fail"),
+        VersionMaxCount:       getenv("VERSION_MAX_COUNT", "20"),
+        VersionMaxAgeDays:     getenv("VERSION_MAX_AGE_DAYS", "0"),
+        ExtractMaxEntries:     getenv("EXTRACT_MAX_ENTRIES", "10000"),
+        ExtractMaxSizeMB:      getenv("EXTRACT_MAX_SIZE_MB", "10240"),
+        DefaultQuotaMB:        getenv("DEFAULT_QUOTA_MB", "0"),
+        ContentIndexMaxFileMB: getenv("CONTENT_INDEX_MAX_FILE_MB", "50"),
+        ContentIndexMaxTextKB: getenv("CONTENT_INDEX_MAX_TEXT_KB", "1024"),
+        ImageMaxDimension:     getenv("IMAGE_MAX_DIMENSION", "4096"),
+        ImageCacheMaxMB:       getenv("IMAGE_CACHE_MAX_MB", "1024"),
     }
 }
@@ -27,7 +27,8 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 		err error
 	)
 
-	gcfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)}
+	// TranslateError 将各数据库的唯一约束冲突统一转换为 gorm.ErrDuplicatedKey
+	gcfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Warn), TranslateError: true}
 
 	switch cfg.DatabaseDriver {
 	case "sqlite":
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AlbumHandler struct {
+	albumService *service.AlbumService
+}
+
+func NewAlbumHandler(albumService *service.AlbumService) *AlbumHandler {
+	return &AlbumHandler{albumService: albumService}
+}
+
+type createAlbumRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type updateAlbumRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	CoverFileID *uint   `json:"cover_file_id"`
+}
+
+type albumFilesRequest struct {
+	FileIDs []uint `json:"file_ids" binding:"required"`
+}
+
+// Create 创建相册
+func (h *AlbumHandler) Create(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req createAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.albumService.Create(uid, req.Name, req.Description)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, album)
+}
+
+// List 列出当前用户的相册
+func (h *AlbumHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	albums, total, err := h.albumService.List(uid, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"albums": albums,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// Get 获取相册信息
+func (h *AlbumHandler) Get(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	album, err := h.albumService.Get(uid, albumID)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, album)
+}
+
+// Update 修改相册名称、说明或封面
+func (h *AlbumHandler) Update(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	var req updateAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	album, err := h.albumService.Update(uid, albumID, service.AlbumUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		CoverFileID: req.CoverFileID,
+	})
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, album)
+}
+
+// Delete 删除相册，其中的文件保留
+func (h *AlbumHandler) Delete(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.albumService.Delete(uid, albumID); err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "album deleted"})
+}
+
+// Files 列出相册中的文件
+func (h *AlbumHandler) Files(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	files, total, err := h.albumService.ListFiles(uid, albumID, page, limit)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"files": files,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// AddFiles 将文件加入相册，文件保留在原位置
+func (h *AlbumHandler) AddFiles(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	var req albumFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	added, err := h.albumService.AddFiles(uid, albumID, req.FileIDs)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"added": added})
+}
+
+// RemoveFile 从相册中移除文件，文件本身保留
+func (h *AlbumHandler) RemoveFile(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	albumID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "file_id")
+	if !ok {
+		return
+	}
+	if err := h.albumService.RemoveFile(uid, albumID, fileID); err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "file removed from album"})
+}
+
+// writeAlbumError 将相册相关的错误映射为 HTTP 状态码，其余交给 writeFileError
+func writeAlbumError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "album or file not found"})
+	case errors.Is(err, service.ErrInvalidAlbum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeFileError(c, err)
+	}
+}
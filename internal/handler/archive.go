@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveHandler struct {
+	fileService *service.FileService
+}
+
+func NewArchiveHandler(fileService *service.FileService) *ArchiveHandler {
+	return &ArchiveHandler{fileService: fileService}
+}
+
+// Entries 列出压缩包中的条目，无需解压
+func (h *ArchiveHandler) Entries(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	entries, total, err := h.fileService.ListArchiveEntries(uid, fileID, (page-1)*limit, limit)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// Entry 下载压缩包中的单个文件，name 为 Entries 返回的条目名
+func (h *ArchiveHandler) Entry(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	reader, entry, err := h.fileService.OpenArchiveEntry(uid, fileID, name)
+	if err != nil {
+		if errors.Is(err, service.ErrArchiveEntryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		writeFileError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	base := path.Base(entry.Name)
+	mimeType := mime.TypeByExtension(filepath.Ext(base))
+	if rs, ok := reader.(io.ReadSeeker); ok {
+		// 未压缩的 ZIP 条目支持 Range
+		serveContent(c, base, mimeType, "", entry.Modified, rs)
+		return
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": base}))
+	c.DataFromReader(http.StatusOK, entry.Size, mimeType, reader, nil)
+}
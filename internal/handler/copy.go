@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CopyHandler struct {
+	copyService *service.CopyService
+	fileService *service.FileService
+}
+
+func NewCopyHandler(copyService *service.CopyService, fileService *service.FileService) *CopyHandler {
+	return &CopyHandler{copyService: copyService, fileService: fileService}
+}
+
+type copyFileReq struct {
+	Name       string  `json:"name" binding:"max=255"`
+	ParentID   *uint   `json:"parent_id"`
+	Path       *string `json:"path"`
+	OnConflict string  `json:"on_conflict"`
+	Async      bool    `json:"async"`
+}
+
+// Copy 复制文件或文件夹。小规模复制直接返回新文件（200），
+// 条目较多或请求 async 时返回后台任务（202），通过 GET /v1/jobs/:id 查询进度
+func (h *CopyHandler) Copy(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req copyFileReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	parentID := req.ParentID
+	if req.Path != nil {
+		id, err := h.fileService.EnsurePath(uid, 0, *req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
+		}
+		parentID = &id
+	}
+
+	file, job, err := h.copyService.Copy(uid, fileID, req.Name, parentID, policy, req.Async)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	if job != nil {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
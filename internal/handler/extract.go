@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExtractHandler struct {
+	extractService *service.ExtractService
+	fileService    *service.FileService
+}
+
+func NewExtractHandler(extractService *service.ExtractService, fileService *service.FileService) *ExtractHandler {
+	return &ExtractHandler{extractService: extractService, fileService: fileService}
+}
+
+type extractReq struct {
+	ParentID   *uint   `json:"parent_id"`
+	Path       *string `json:"path"`
+	OnConflict string  `json:"on_conflict"`
+}
+
+// Extract 在服务端解压 ZIP / tar / tar.gz 压缩包，返回后台任务（202），通过 GET /v1/jobs/:id 查询进度
+func (h *ExtractHandler) Extract(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req extractReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	parentID := req.ParentID
+	if req.Path != nil {
+		id, err := h.fileService.EnsurePath(uid, 0, *req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
+		}
+		parentID = &id
+	}
+
+	job, err := h.extractService.Extract(uid, fileID, parentID, policy)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
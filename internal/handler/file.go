@@ -1,14 +1,17 @@
 package handler
 
 import (
+	"errors"
+	"log"
+	"mime"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"online-disk-server/internal/middleware"
 	"online-disk-server/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type FileHandler struct {
@@ -35,31 +38,24 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	path := c.DefaultPostForm("path", c.DefaultQuery("path", ""))
 	if path != "" {
 		// 递归查找/创建目录，每一级都用 FindOrCreateFolder，保证 parent_id 递归正确
-		parts := strings.Split(strings.Trim(path, "/"), "/")
-		var fullPathBuilder strings.Builder
-		fullPathBuilder.WriteString("/")
-		for idx, p := range parts {
-			if p == "" || p == "." || p == ".." {
-				continue
-			}
-			if idx > 0 {
-				fullPathBuilder.WriteString("/")
-			}
-			fullPathBuilder.WriteString(p)
-			fullPath := fullPathBuilder.String()
-			folder, err := h.fileService.FindOrCreateFolder(uid, parentID, p, fullPath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
-				return
-			}
-			parentID = folder.ID
+		id, err := h.fileService.EnsurePath(uid, 0, path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
 		}
+		parentID = id
 	} else if pid := c.DefaultPostForm("parent_id", c.DefaultQuery("parent_id", "0")); pid != "0" {
 		if id, err := strconv.ParseUint(pid, 10, 32); err == nil {
 			parentID = uint(id)
 		}
 	}
 
+	// 同名文件处理方式
+	policy, ok := conflictPolicy(c, h.fileService, c.DefaultPostForm("on_conflict", c.Query("on_conflict")))
+	if !ok {
+		return
+	}
+
 	// 获取上传文件
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -68,9 +64,9 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	}
 
 	// 上传文件
-	uploadedFile, err := h.fileService.UploadFile(uid, file, parentID)
+	uploadedFile, err := h.fileService.UploadFile(uid, file, parentID, policy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeFileError(c, err)
 		return
 	}
 
@@ -120,9 +116,14 @@ func (h *FileHandler) BatchUpload(c *gin.Context) {
 		relPaths = form.Value["relative_path"]
 	}
 
-	uploaded, err := h.fileService.UploadFilesWithRelativePaths(uid, files, relPaths, parentID)
+	policy, ok := conflictPolicy(c, h.fileService, c.DefaultPostForm("on_conflict", c.Query("on_conflict")))
+	if !ok {
+		return
+	}
+
+	uploaded, err := h.fileService.UploadFilesWithRelativePaths(uid, files, relPaths, parentID, policy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeFileError(c, err)
 		return
 	}
 
@@ -146,20 +147,67 @@ func (h *FileHandler) Download(c *gin.Context) {
 	}
 
 	reader, file, err := h.fileService.DownloadFile(uid, uint(fileID))
+	if errors.Is(err, service.ErrNotAFile) {
+		// 文件夹打包为 ZIP 下载
+		streamArchive(c, h.fileService, uid, []uint{uint(fileID)})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
 	}
 	defer reader.Close()
 
-	c.Header("Content-Disposition", "attachment; filename="+file.Name)
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	serveContent(c, file.Name, file.MimeType, contentETag(file.SHA256, file.Hash), file.UpdatedAt, reader)
+}
 
-	c.DataFromReader(http.StatusOK, file.Size, file.MimeType, reader, nil)
+type archiveRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
 }
 
-// GetInfo 获取文件信息
+// Archive 将选中的多个文件和文件夹打包为 ZIP 下载
+func (h *FileHandler) Archive(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req archiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	streamArchive(c, h.fileService, uid, req.IDs)
+}
+
+// streamArchive 边打包边输出 ZIP；开始输出后出错只能中断连接
+func streamArchive(c *gin.Context, fileService *service.FileService, userID uint, fileIDs []uint) {
+	archive, err := fileService.PrepareArchive(userID, fileIDs)
+	if err != nil {
+		if errors.Is(err, service.ErrEmptySelection) || errors.Is(err, service.ErrSelectionTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeFileError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archive.Name}))
+	c.Status(http.StatusOK)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	if err := archive.WriteZip(c.Writer); err != nil {
+		log.Printf("stream archive for user %d failed: %v", userID, err)
+		// 直接断开连接，避免客户端把不完整的压缩包当作下载成功
+		if conn, _, err := c.Writer.Hijack(); err == nil {
+			conn.Close()
+		}
+		c.Abort()
+	}
+}
+
+// GetInfo 获取文件信息，照片、音视频与 PDF 附带提取到的媒体元数据
 func (h *FileHandler) GetInfo(c *gin.Context) {
 	userID, exists := c.Get(middleware.CtxUserID)
 	if !exists {
@@ -175,7 +223,7 @@ func (h *FileHandler) GetInfo(c *gin.Context) {
 		return
 	}
 
-	file, err := h.fileService.GetFile(uid, uint(fileID))
+	file, err := h.fileService.GetFileInfo(uid, uint(fileID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
@@ -238,7 +286,7 @@ func (h *FileHandler) List(c *gin.Context) {
 	})
 }
 
-// Delete 删除文件
+// Delete 删除文件或文件夹（递归），permanent=true 时跳过回收站
 func (h *FileHandler) Delete(c *gin.Context) {
 	userID, exists := c.Get(middleware.CtxUserID)
 	if !exists {
@@ -254,12 +302,70 @@ func (h *FileHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.fileService.DeleteFile(uid, uint(fileID)); err != nil {
+	permanent := c.Query("permanent") == "true"
+	result, err := h.fileService.DeleteFile(uid, uint(fileID), permanent)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "file deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "file deleted successfully",
+		"files":   result.Files,
+		"folders": result.Folders,
+		"bytes":   result.Bytes,
+	})
+}
+
+type updateFileReq struct {
+	Name       string  `json:"name" binding:"max=255"`
+	ParentID   *uint   `json:"parent_id"`
+	Path       *string `json:"path"`
+	OnConflict string  `json:"on_conflict"`
+}
+
+// Update 重命名和/或移动文件或文件夹，目标目录可通过 parent_id 或 path 指定
+func (h *FileHandler) Update(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateFileReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	parentID := req.ParentID
+	if req.Path != nil {
+		// path 为空或 "/" 表示移动到根目录
+		id, err := h.fileService.EnsurePath(uid, 0, *req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
+		}
+		parentID = &id
+	}
+
+	file, err := h.fileService.MoveFile(uid, fileID, req.Name, parentID, policy)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
 }
 
 // CreateFolder 创建文件夹
@@ -273,9 +379,10 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 	uid := userID.(uint)
 
 	var req struct {
-		Name     string `json:"name" binding:"required"`
-		ParentID uint   `json:"parent_id"`
-		Path     string `json:"path"` // 新增 path 字段
+		Name       string `json:"name" binding:"required"`
+		ParentID   uint   `json:"parent_id"`
+		Path       string `json:"path"` // 新增 path 字段
+		OnConflict string `json:"on_conflict"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -285,30 +392,22 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 
 	parentID := req.ParentID
 	if req.Path != "" {
-		parts := strings.Split(strings.Trim(req.Path, "/"), "/")
-		var fullPathBuilder strings.Builder
-		fullPathBuilder.WriteString("/")
-		for idx, p := range parts {
-			if p == "" || p == "." || p == ".." {
-				continue
-			}
-			if idx > 0 {
-				fullPathBuilder.WriteString("/")
-			}
-			fullPathBuilder.WriteString(p)
-			fullPath := fullPathBuilder.String()
-			folder, err := h.fileService.FindOrCreateFolder(uid, parentID, p, fullPath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find parent folder failed: " + err.Error()})
-				return
-			}
-			parentID = folder.ID
+		id, err := h.fileService.EnsurePath(uid, parentID, req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find parent folder failed: " + err.Error()})
+			return
 		}
+		parentID = id
 	}
 
-	folder, err := h.fileService.CreateFolder(uid, req.Name, parentID)
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	folder, err := h.fileService.CreateFolder(uid, req.Name, parentID, policy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeFileError(c, err)
 		return
 	}
 
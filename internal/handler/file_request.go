@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead 限制请求体大小时为 multipart 边界和表单字段预留的字节数
+const multipartOverhead = 1 << 20
+
+type FileRequestHandler struct {
+	requestService *service.FileRequestService
+}
+
+func NewFileRequestHandler(requestService *service.FileRequestService) *FileRequestHandler {
+	return &FileRequestHandler{requestService: requestService}
+}
+
+type createFileRequestRequest struct {
+	FolderID          uint       `json:"folder_id" binding:"required"`
+	Title             string     `json:"title"`
+	Message           string     `json:"message"`
+	MaxFileSize       int64      `json:"max_file_size"`
+	MaxFiles          int        `json:"max_files"`
+	AllowedExtensions []string   `json:"allowed_extensions"`
+	Deadline          *time.Time `json:"deadline"`
+}
+
+// Create 为文件夹创建文件收集链接
+func (h *FileRequestHandler) Create(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req createFileRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileRequest, err := h.requestService.Create(uid, req.FolderID, service.FileRequestOptions{
+		Title:             req.Title,
+		Message:           req.Message,
+		MaxFileSize:       req.MaxFileSize,
+		MaxFiles:          req.MaxFiles,
+		AllowedExtensions: req.AllowedExtensions,
+		Deadline:          req.Deadline,
+	})
+	if err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, fileRequest)
+}
+
+// List 列出当前用户创建的文件收集链接
+func (h *FileRequestHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	requests, total, err := h.requestService.List(uid, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"file_requests": requests,
+		"total":         total,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// Uploads 列出通过文件收集链接收到的文件及上传者信息
+func (h *FileRequestHandler) Uploads(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	requestID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	uploads, total, err := h.requestService.ListUploads(uid, requestID, page, limit)
+	if err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"uploads": uploads,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// Delete 关闭文件收集链接，已收到的文件保留
+func (h *FileRequestHandler) Delete(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	requestID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.requestService.Delete(uid, requestID); err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "file request deleted"})
+}
+
+// PublicInfo 查看文件收集链接的说明与限制，无需登录
+func (h *FileRequestHandler) PublicInfo(c *gin.Context) {
+	fileRequest, err := h.requestService.Open(c.Param("token"))
+	if err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	info, err := h.requestService.PublicInfo(fileRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// PublicUpload 通过文件收集链接上传文件，无需登录；可选填写 name 与 email。
+// 响应只包含本次上传的文件名和大小；重名时保存的名称会带序号，但不返回给上传者，以免暴露文件夹中的已有内容
+func (h *FileRequestHandler) PublicUpload(c *gin.Context) {
+	fileRequest, err := h.requestService.Open(c.Param("token"))
+	if err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	if fileRequest.MaxFileSize > 0 {
+		// 在读取请求体前限制大小，避免超限的文件先被完整写入临时文件
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, fileRequest.MaxFileSize+multipartOverhead)
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeFileRequestError(c, service.ErrFileTooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+
+	upload, err := h.requestService.Upload(fileRequest, fh, service.Uploader{
+		Name:  c.PostForm("name"),
+		Email: c.PostForm("email"),
+	})
+	if err != nil {
+		writeFileRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"file_name":  filepath.Base(fh.Filename),
+		"size":       upload.Size,
+		"created_at": upload.CreatedAt,
+	})
+}
+
+// writeFileRequestError 将文件收集相关的错误映射为 HTTP 状态码，其余交给 writeFileError
+func writeFileRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "file request or folder not found"})
+	case errors.Is(err, service.ErrFileRequestClosed):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrExtensionNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidFileRequest), errors.Is(err, service.ErrInvalidUploader):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeFileError(c, err)
+	}
+}
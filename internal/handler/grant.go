@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GrantHandler struct {
+	fileService *service.FileService
+}
+
+func NewGrantHandler(fileService *service.FileService) *GrantHandler {
+	return &GrantHandler{fileService: fileService}
+}
+
+type grantRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// List 列出文件上的用户授权
+func (h *GrantHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	grants, err := h.fileService.ListGrants(uid, fileID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"grants": grants, "total": len(grants)})
+}
+
+// Grant 将文件或文件夹授权给其他用户（viewer 或 editor），已授权时修改角色
+func (h *GrantHandler) Grant(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	var req grantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grant, err := h.fileService.GrantAccess(uid, fileID, req.Username, req.Role)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grant)
+}
+
+// Revoke 撤销一项用户授权
+func (h *GrantHandler) Revoke(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	grantID, ok := uintParam(c, "gid")
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.RevokeGrant(uid, fileID, grantID); err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "grant revoked"})
+}
+
+// SharedWithMe 列出其他用户授权给我的文件和文件夹
+func (h *GrantHandler) SharedWithMe(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	grants, total, err := h.fileService.SharedWithMe(uid, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"grants": grants,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
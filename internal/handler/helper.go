@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"online-disk-server/internal/middleware"
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// currentUserID 读取鉴权中间件写入的用户ID，未登录时直接返回 401
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get(middleware.CtxUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, false
+	}
+	uid, ok := userID.(uint)
+	if !ok || uid == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, false
+	}
+	return uid, true
+}
+
+// uintParam 解析路径参数中的数字ID，非法时直接返回 400
+func uintParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// pagination 读取 page 与 limit 查询参数，非法时使用默认值
+func pagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+// conflictPolicy 读取请求指定的 on_conflict，未指定时使用服务端默认策略；非法时直接返回 400
+func conflictPolicy(c *gin.Context, fileService *service.FileService, value string) (service.ConflictPolicy, bool) {
+	policy, err := service.ParseConflictPolicy(value, fileService.ConflictPolicy())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return policy, true
+}
+
+// writeFileError 将文件操作的错误映射为 HTTP 状态码
+func writeFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+	case errors.Is(err, service.ErrNameConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidName), errors.Is(err, service.ErrInvalidMove),
+		errors.Is(err, service.ErrInvalidCopy), errors.Is(err, service.ErrNotAFolder),
+		errors.Is(err, service.ErrNoVersions), errors.Is(err, service.ErrUnsupportedArchive),
+		errors.Is(err, service.ErrInvalidGrant), errors.Is(err, service.ErrInvalidSearch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
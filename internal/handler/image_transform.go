@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageTransformHandler struct {
+	fileService *service.FileService
+}
+
+func NewImageTransformHandler(fileService *service.FileService) *ImageTransformHandler {
+	return &ImageTransformHandler{fileService: fileService}
+}
+
+// Transform 返回按参数缩放、裁剪并转换格式后的图片。
+// 结果随内容与参数确定，ETag 由内容哈希与参数摘要组成，客户端可以长期缓存并用 If-None-Match 校验
+func (h *ImageTransformHandler) Transform(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	opts := service.ImageTransformOptions{
+		Fit:    c.Query("fit"),
+		Crop:   c.Query("crop"),
+		Format: c.Query("format"),
+	}
+	if opts.Width, ok = intQuery(c, "w"); !ok {
+		return
+	}
+	if opts.Height, ok = intQuery(c, "h"); !ok {
+		return
+	}
+	if opts.Quality, ok = intQuery(c, "quality"); !ok {
+		return
+	}
+
+	variant, data, file, err := h.fileService.TransformImage(uid, fileID, opts)
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	if etag := contentETag(file.SHA256, file.Hash); etag != "" {
+		c.Header("ETag", `"`+etag+"-"+variant.Digest+`"`)
+	}
+	c.Header("Content-Type", variant.MimeType)
+	c.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", file.UpdatedAt, bytes.NewReader(data))
+}
+
+// intQuery 读取可选的整数查询参数，未指定时为 0，非法时直接返回 400
+func intQuery(c *gin.Context, name string) (int, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return n, true
+}
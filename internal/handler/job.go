@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobService *service.JobService
+}
+
+func NewJobHandler(jobService *service.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// Get 查询后台任务状态与进度
+func (h *JobHandler) Get(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	jobID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := h.jobService.Get(uid, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
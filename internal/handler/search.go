@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	fileService *service.FileService
+}
+
+func NewSearchHandler(fileService *service.FileService) *SearchHandler {
+	return &SearchHandler{fileService: fileService}
+}
+
+// Search 按名称、类型、扩展名、大小、时间、文件夹范围和媒体元数据搜索文件，支持排序与分页
+func (h *SearchHandler) Search(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	opts := service.SearchOptions{
+		Query:      c.Query("q"),
+		Match:      c.Query("match"),
+		MimeTypes:  listQuery(c, "mime"),
+		Extensions: listQuery(c, "ext"),
+		Type:       c.Query("type"),
+		Sort:       c.Query("sort"),
+		Order:      c.Query("order"),
+		Media:      c.Query("media"),
+		Camera:     c.Query("camera"),
+		Artist:     c.Query("artist"),
+		Album:      c.Query("album"),
+	}
+	if opts.MinSize, ok = int64Query(c, "min_size"); !ok {
+		return
+	}
+	if opts.MaxSize, ok = int64Query(c, "max_size"); !ok {
+		return
+	}
+	if opts.CreatedAfter, ok = timeQuery(c, "created_after"); !ok {
+		return
+	}
+	if opts.CreatedBefore, ok = timeQuery(c, "created_before"); !ok {
+		return
+	}
+	if opts.UpdatedAfter, ok = timeQuery(c, "modified_after"); !ok {
+		return
+	}
+	if opts.UpdatedBefore, ok = timeQuery(c, "modified_before"); !ok {
+		return
+	}
+	if opts.TakenAfter, ok = timeQuery(c, "taken_after"); !ok {
+		return
+	}
+	if opts.TakenBefore, ok = timeQuery(c, "taken_before"); !ok {
+		return
+	}
+	if opts.HasLocation, ok = boolQuery(c, "has_location"); !ok {
+		return
+	}
+	if opts.FolderID, ok = folderQuery(c); !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	files, total, err := h.fileService.Search(uid, opts, page, limit)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"files": files,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// Content 按文件内容全文搜索，返回命中的文件及标出检索词的摘要
+func (h *SearchHandler) Content(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	folderID, ok := folderQuery(c)
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	results, total, err := h.fileService.SearchContent(uid, c.Query("q"), folderID, page, limit)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// folderQuery 读取可选的 folder_id 查询参数，未指定时为 0，非法时直接返回 400
+func folderQuery(c *gin.Context) (uint, bool) {
+	folder := c.Query("folder_id")
+	if folder == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(folder, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder_id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// listQuery 读取可重复、也可用逗号分隔的查询参数
+func listQuery(c *gin.Context, name string) []string {
+	var values []string
+	for _, v := range c.QueryArray(name) {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// int64Query 读取可选的整数查询参数，非法时直接返回 400
+func int64Query(c *gin.Context, name string) (*int64, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return nil, false
+	}
+	return &n, true
+}
+
+// boolQuery 读取可选的布尔查询参数，非法时直接返回 400
+func boolQuery(c *gin.Context, name string) (*bool, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return nil, false
+	}
+	return &b, true
+}
+
+// timeQuery 读取可选的时间查询参数，支持 RFC 3339 和 YYYY-MM-DD（UTC 零点），非法时直接返回 400
+func timeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+			return nil, false
+		}
+	}
+	return &t, true
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveContent 输出文件内容。由 http.ServeContent 处理 Range（单段与多段，206/416）、If-Range
+// 以及 If-None-Match/If-Modified-Since 条件请求；etag 取内容哈希，为空时只按修改时间判断
+func serveContent(c *gin.Context, name, mimeType, etag string, modTime time.Time, content io.ReadSeeker) {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if etag != "" {
+		c.Header("ETag", `"`+etag+`"`)
+	}
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	http.ServeContent(c.Writer, c.Request, name, modTime, content)
+}
+
+// contentETag 选择用作 ETag 的内容哈希，优先 SHA-256
+func contentETag(sha256, md5 string) string {
+	if sha256 != "" {
+		return sha256
+	}
+	return md5
+}
@@ -0,0 +1,440 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/auth"
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareAccessTTL 输入分享密码后签发的访问凭证有效期
+const shareAccessTTL = time.Hour
+
+// shareDownloadTTL 计入下载后签发的下载凭证有效期，期间同一文件的续传不再计数
+const shareDownloadTTL = 6 * time.Hour
+
+type ShareHandler struct {
+	shareService *service.ShareService
+	fileService  *service.FileService
+	jwtm         *auth.JWTManager
+}
+
+func NewShareHandler(shareService *service.ShareService, fileService *service.FileService, jwtm *auth.JWTManager) *ShareHandler {
+	return &ShareHandler{shareService: shareService, fileService: fileService, jwtm: jwtm}
+}
+
+type createShareRequest struct {
+	FileID       uint       `json:"file_id" binding:"required"`
+	Password     string     `json:"password"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	MaxDownloads int        `json:"max_downloads"`
+	Permission   string     `json:"permission"`
+}
+
+// Create 为文件或文件夹创建分享链接
+func (h *ShareHandler) Create(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req createShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := h.shareService.Create(uid, req.FileID, service.ShareOptions{
+		Password:     req.Password,
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+		Permission:   req.Permission,
+	})
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, share)
+}
+
+// List 列出当前用户创建的分享链接
+func (h *ShareHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	shares, total, err := h.shareService.List(uid, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"shares": shares,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// Revoke 撤销分享链接
+func (h *ShareHandler) Revoke(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	shareID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.shareService.Revoke(uid, shareID); err != nil {
+		writeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "share revoked"})
+}
+
+// PublicInfo 查看分享信息，无需登录。设置了密码且未解锁时只返回分享本身的限制，不返回文件信息
+func (h *ShareHandler) PublicInfo(c *gin.Context) {
+	share, root, err := h.shareService.Open(c.Param("token"))
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	unlocked := h.unlocked(c, share)
+	info := gin.H{
+		"permission":        share.Permission,
+		"expires_at":        share.ExpiresAt,
+		"max_downloads":     share.MaxDownloads,
+		"download_count":    share.DownloadCount,
+		"password_required": share.HasPassword,
+		"unlocked":          unlocked,
+	}
+	if unlocked {
+		info["file"] = service.SharedView(root, root)
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+type unlockShareRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// PublicUnlock 校验分享密码，返回后续请求使用的访问凭证（X-Share-Access 请求头或 access 查询参数）
+func (h *ShareHandler) PublicUnlock(c *gin.Context) {
+	share, _, err := h.shareService.Open(c.Param("token"))
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	var req unlockShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if wait, err := h.shareService.CheckPassword(share, req.Password, c.ClientIP()); err != nil {
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		writeShareError(c, err)
+		return
+	}
+	access, err := h.jwtm.GenerateShareAccess(share.ID, shareAccessTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"access_token": access, "expires_in": int(shareAccessTTL.Seconds())})
+}
+
+// PublicList 浏览分享的文件夹，parent_id 为空时列出分享的根
+func (h *ShareHandler) PublicList(c *gin.Context) {
+	share, root, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	folderID, ok := shareFileID(c, "parent_id", c.Query("parent_id"))
+	if !ok {
+		return
+	}
+	page, limit := pagination(c)
+
+	files, total, err := h.shareService.ListFolder(share, root, folderID, page, limit)
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"files": files,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// PublicDownload 下载分享的文件，file_id 为空时下载分享的根；文件夹打包为 ZIP。
+// 每次 GET 都计入下载次数，计入后签发该文件的下载凭证；只有携带凭证、不含第 0 字节的续传不计入，HEAD 不计入
+func (h *ShareHandler) PublicDownload(c *gin.Context) {
+	share, root, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	fileID, ok := shareFileID(c, "file_id", c.Query("file_id"))
+	if !ok {
+		return
+	}
+	file, err := h.shareService.Resolve(share, root, fileID)
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+
+	if file.IsDir {
+		// 打包下载不支持 Range，每次都是完整下载
+		if c.Request.Method == http.MethodGet {
+			if err := h.shareService.CountDownload(share); err != nil {
+				writeShareError(c, err)
+				return
+			}
+		}
+		streamArchive(c, h.fileService, share.UserID, []uint{file.ID})
+		return
+	}
+	reader, file, err := h.fileService.DownloadFile(share.UserID, file.ID)
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	resumable := h.downloadStarted(c, share, file)
+	if c.Request.Method == http.MethodGet && countsAsDownload(c.Request.Header, file.Size, contentETag(file.SHA256, file.Hash), file.UpdatedAt, resumable) {
+		if err := h.shareService.CountDownload(share); err != nil {
+			writeShareError(c, err)
+			return
+		}
+		if err := h.issueDownload(c, share, file); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	serveContent(c, file.Name, file.MimeType, contentETag(file.SHA256, file.Hash), file.UpdatedAt, reader)
+}
+
+// PublicUpload 通过允许上传的分享向其中的文件夹上传文件，parent_id 为空时上传到分享的根
+func (h *ShareHandler) PublicUpload(c *gin.Context) {
+	share, root, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	if share.Permission != model.SharePermissionUpload {
+		writeShareError(c, service.ErrShareNotPermitted)
+		return
+	}
+	parentID, ok := shareFileID(c, "parent_id", c.DefaultPostForm("parent_id", c.Query("parent_id")))
+	if !ok {
+		return
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+
+	file, err := h.shareService.Upload(share, root, parentID, fh)
+	if err != nil {
+		writeShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, service.SharedView(root, file))
+}
+
+// openShare 查找有效的分享并确认已通过密码校验，失败时直接写出错误响应
+func (h *ShareHandler) openShare(c *gin.Context) (*model.Share, *model.File, bool) {
+	share, root, err := h.shareService.Open(c.Param("token"))
+	if err != nil {
+		writeShareError(c, err)
+		return nil, nil, false
+	}
+	if !h.unlocked(c, share) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": service.ErrSharePasswordMissing.Error(), "password_required": true})
+		return nil, nil, false
+	}
+	return share, root, true
+}
+
+// unlocked 判断请求能否访问分享内容：未设置密码，或携带了该分享有效的访问凭证
+func (h *ShareHandler) unlocked(c *gin.Context, share *model.Share) bool {
+	if !share.HasPassword {
+		return true
+	}
+	access := c.GetHeader("X-Share-Access")
+	if access == "" {
+		access = c.Query("access")
+	}
+	if access == "" {
+		return false
+	}
+	shareID, err := h.jwtm.ParseShareAccess(access)
+	return err == nil && shareID == share.ID
+}
+
+// shareDownloadCookie 保存文件下载凭证的 Cookie 名称，浏览器续传时会自动带上
+func shareDownloadCookie(fileID uint) string {
+	return "share_download_" + strconv.FormatUint(uint64(fileID), 10)
+}
+
+// downloadStarted 判断请求是否携带该分享中该文件有效的下载凭证（X-Share-Download 头或 Cookie），
+// 即此前已计入过一次下载
+func (h *ShareHandler) downloadStarted(c *gin.Context, share *model.Share, file *model.File) bool {
+	ticket := c.GetHeader("X-Share-Download")
+	if ticket == "" {
+		ticket, _ = c.Cookie(shareDownloadCookie(file.ID))
+	}
+	if ticket == "" {
+		return false
+	}
+	shareID, fileID, err := h.jwtm.ParseShareDownload(ticket)
+	return err == nil && shareID == share.ID && fileID == file.ID
+}
+
+// issueDownload 为计入的下载签发凭证，通过 X-Share-Download 头与 Cookie 返回
+func (h *ShareHandler) issueDownload(c *gin.Context, share *model.Share, file *model.File) error {
+	ticket, err := h.jwtm.GenerateShareDownload(share.ID, file.ID, shareDownloadTTL)
+	if err != nil {
+		return err
+	}
+	c.Header("X-Share-Download", ticket)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(shareDownloadCookie(file.ID), ticket, int(shareDownloadTTL.Seconds()), c.Request.URL.Path, "", c.Request.TLS != nil, true)
+	return nil
+}
+
+// shareFileID 解析可选的文件ID参数，为空时返回 0（分享的根）；非法时直接返回 400
+func shareFileID(c *gin.Context, name, value string) (uint, bool) {
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// countsAsDownload 判断 GET 请求是否计为一次新的下载。resumable 为 false（没有此前计入下载时签发的凭证）时总是计入，
+// 否则按 http.ServeContent 的规则解析 Range：没有 Range、If-Range 与当前内容不一致、各段总长超过文件大小
+// （以上均返回完整内容），或任一段包含第 0 字节时计入；无法解析或无法满足（416，不返回内容）以及只请求后续字节的续传不计入
+func countsAsDownload(header http.Header, size int64, etag string, modTime time.Time, resumable bool) bool {
+	if !resumable {
+		return true
+	}
+	rangeHeader := header.Get("Range")
+	if rangeHeader == "" || !ifRangeMatches(header.Get("If-Range"), etag, modTime) {
+		return true
+	}
+	const prefix = "bytes="
+	if !strings.HasPrefix(rangeHeader, prefix) {
+		return false
+	}
+	var total int64
+	ranges, noOverlap := 0, false
+	fromStart := false
+	for _, ra := range strings.Split(rangeHeader[len(prefix):], ",") {
+		ra = textproto.TrimString(ra)
+		if ra == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(ra, "-")
+		if !ok {
+			return false
+		}
+		startStr, endStr = textproto.TrimString(startStr), textproto.TrimString(endStr)
+		var start, length int64
+		if startStr == "" {
+			// 后缀范围 bytes=-n，n 不小于文件大小时从第 0 字节开始
+			if endStr == "" || endStr[0] == '-' {
+				return false
+			}
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return false
+			}
+			start = size - min(n, size)
+			length = size - start
+		} else {
+			i, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || i < 0 {
+				return false
+			}
+			if i >= size {
+				noOverlap = true
+				continue
+			}
+			start = i
+			if endStr == "" {
+				length = size - start
+			} else {
+				end, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || start > end {
+					return false
+				}
+				length = min(end, size-1) - start + 1
+			}
+		}
+		ranges++
+		total += length
+		if start == 0 {
+			fromStart = true
+		}
+	}
+	if ranges == 0 {
+		// 全部越界时返回 416，否则（如 "bytes="）忽略 Range 返回完整内容
+		return !noOverlap
+	}
+	return fromStart || total > size
+}
+
+// ifRangeMatches 按 http.ServeContent 的规则判断 If-Range 是否与当前内容一致（一致时才使用 Range）。
+// ETag 使用强比较，日期精确到秒
+func ifRangeMatches(ifRange, etag string, modTime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etag != "" && ifRange == `"`+etag+`"`
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
+
+// writeShareError 将分享相关的错误映射为 HTTP 状态码，其余交给 writeFileError
+func writeShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "share or file not found"})
+	case errors.Is(err, service.ErrShareExpired), errors.Is(err, service.ErrShareExhausted):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSharePassword), errors.Is(err, service.ErrSharePasswordMissing):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "password_required": true})
+	case errors.Is(err, service.ErrShareNotPermitted), errors.Is(err, service.ErrOutsideShare):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidShare):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrShareUnlockThrottled):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		writeFileError(c, err)
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCountsAsDownload(t *testing.T) {
+	const size = 1000
+	const etag = "abc123"
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rng     string
+		ifRange string
+		want    bool
+	}{
+		{name: "no range", want: true},
+		{name: "from zero", rng: "bytes=0-", want: true},
+		{name: "first byte", rng: "bytes=0-0", want: true},
+		{name: "leading zeros", rng: "bytes=00-", want: true},
+		{name: "spaces", rng: "bytes= 0 - 99", want: true},
+		{name: "suffix covering whole file", rng: "bytes=-1000", want: true},
+		{name: "suffix longer than file", rng: "bytes=-5000", want: true},
+		{name: "zero in later range", rng: "bytes=1-,0-0", want: true},
+		{name: "overlapping ranges exceed size", rng: "bytes=1-,1-", want: true},
+		{name: "empty range set", rng: "bytes=", want: true},
+		{name: "resume", rng: "bytes=500-", want: false},
+		{name: "suffix tail", rng: "bytes=-100", want: false},
+		{name: "several later ranges", rng: "bytes=10-19,30-39", want: false},
+		{name: "unsatisfiable", rng: "bytes=1000-", want: false},
+		{name: "invalid unit", rng: "items=0-", want: false},
+		{name: "invalid syntax", rng: "bytes=abc", want: false},
+		{name: "reversed", rng: "bytes=50-10", want: false},
+		{name: "matching etag", rng: "bytes=500-", ifRange: `"abc123"`, want: false},
+		{name: "stale etag", rng: "bytes=500-", ifRange: `"other"`, want: true},
+		{name: "weak etag", rng: "bytes=500-", ifRange: `W/"abc123"`, want: true},
+		{name: "matching date", rng: "bytes=500-", ifRange: modTime.Format(http.TimeFormat), want: false},
+		{name: "stale date", rng: "bytes=500-", ifRange: modTime.Add(-time.Hour).Format(http.TimeFormat), want: true},
+		{name: "garbage if-range", rng: "bytes=500-", ifRange: "yesterday", want: true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.rng != "" {
+			header.Set("Range", tt.rng)
+		}
+		if tt.ifRange != "" {
+			header.Set("If-Range", tt.ifRange)
+		}
+		if got := countsAsDownload(header, size, etag, modTime, true); got != tt.want {
+			t.Errorf("%s: countsAsDownload(Range=%q, If-Range=%q) = %v, want %v", tt.name, tt.rng, tt.ifRange, got, tt.want)
+		}
+		// 没有下载凭证时任何请求都计入，否则只请求后续字节即可绕过下载次数限制
+		if !countsAsDownload(header, size, etag, modTime, false) {
+			t.Errorf("%s: countsAsDownload(Range=%q, If-Range=%q) without ticket = false, want true", tt.name, tt.rng, tt.ifRange)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ThumbnailHandler struct {
+	fileService *service.FileService
+}
+
+func NewThumbnailHandler(fileService *service.FileService) *ThumbnailHandler {
+	return &ThumbnailHandler{fileService: fileService}
+}
+
+// Get 返回图片文件的缩略图，size 为 small、medium（默认）或 large。
+// 缩略图随内容变化，ETag 由内容哈希与尺寸组成，客户端可以长期缓存并用 If-None-Match 校验
+func (h *ThumbnailHandler) Get(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	thumb, data, file, err := h.fileService.Thumbnail(uid, fileID, c.DefaultQuery("size", service.ThumbnailMedium))
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	if etag := contentETag(file.SHA256, file.Hash); etag != "" {
+		c.Header("ETag", `"`+etag+"-"+thumb.Size+`"`)
+	}
+	c.Header("Content-Type", thumb.MimeType)
+	c.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", file.UpdatedAt, bytes.NewReader(data))
+}
+
+// writeImageError 将缩略图与图片变换相关的错误映射为 HTTP 状态码，其余交给 writeFileError
+func writeImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidImage):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidThumbnailSize), errors.Is(err, service.ErrInvalidTransform):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeFileError(c, err)
+	}
+}
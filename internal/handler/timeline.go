@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimelineHandler struct {
+	fileService *service.FileService
+}
+
+func NewTimelineHandler(fileService *service.FileService) *TimelineHandler {
+	return &TimelineHandler{fileService: fileService}
+}
+
+// timelineOptions 读取时间线的过滤与分组参数
+func timelineOptions(c *gin.Context) service.TimelineOptions {
+	return service.TimelineOptions{
+		Media:    c.Query("media"),
+		Group:    c.Query("group"),
+		TimeZone: c.Query("tz"),
+	}
+}
+
+// List 按拍摄时间倒序浏览照片与视频，按月或日分组，使用游标分页
+func (h *TimelineHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	_, limit := pagination(c)
+
+	buckets, next, err := h.fileService.Timeline(uid, timelineOptions(c), c.Query("cursor"), limit)
+	if err != nil {
+		writeTimelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"buckets":     buckets,
+		"next_cursor": next,
+		"limit":       limit,
+	})
+}
+
+// Buckets 统计每月或每天的照片与视频数量，用于时间轴导航
+func (h *TimelineHandler) Buckets(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	buckets, err := h.fileService.TimelineBuckets(uid, timelineOptions(c))
+	if err != nil {
+		writeTimelineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"buckets": buckets})
+}
+
+func writeTimelineError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidTimeline) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
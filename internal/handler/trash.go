@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	trashService *service.TrashService
+}
+
+func NewTrashHandler(trashService *service.TrashService) *TrashHandler {
+	return &TrashHandler{trashService: trashService}
+}
+
+// List 回收站列表
+func (h *TrashHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	page, limit := pagination(c)
+
+	files, total, err := h.trashService.List(uid, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"files": files,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// Restore 恢复回收站条目
+func (h *TrashHandler) Restore(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	file, err := h.trashService.Restore(uid, fileID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// Delete 彻底删除回收站条目
+func (h *TrashHandler) Delete(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.trashService.DeletePermanently(uid, fileID); err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "file deleted permanently"})
+}
+
+// Empty 清空回收站
+func (h *TrashHandler) Empty(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	n, err := h.trashService.Empty(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "trash emptied", "count": n})
+}
@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,checksum,expiration"
+)
+
+// TusHandler tus 1.0 可续传上传协议（https://tus.io/protocols/resumable-upload）
+type TusHandler struct {
+	uploadService *service.UploadService
+	fileService   *service.FileService
+	basePath      string
+}
+
+func NewTusHandler(uploadService *service.UploadService, fileService *service.FileService, basePath string) *TusHandler {
+	return &TusHandler{uploadService: uploadService, fileService: fileService, basePath: strings.TrimSuffix(basePath, "/")}
+}
+
+// Options 协议能力发现，无需认证
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", strings.Join(service.TusChecksumAlgorithms, ","))
+	c.Status(http.StatusNoContent)
+}
+
+// Create 创建上传（creation 扩展）
+func (h *TusHandler) Create(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.String(http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	meta, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid Upload-Metadata")
+		return
+	}
+
+	name := meta["filename"]
+	if name == "" {
+		name = meta["name"]
+	}
+	if name == "" {
+		c.String(http.StatusBadRequest, "filename metadata is required")
+		return
+	}
+	mimeType := meta["filetype"]
+	if mimeType == "" {
+		mimeType = meta["type"]
+	}
+
+	// 目标目录：优先 path，其次 parent_id
+	parentID := uint(0)
+	if path := meta["path"]; path != "" {
+		id, err := h.fileService.EnsurePath(uid, 0, path)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "create/find folder failed: "+err.Error())
+			return
+		}
+		parentID = id
+	} else if pid := meta["parent_id"]; pid != "" && pid != "0" {
+		id, err := strconv.ParseUint(pid, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid parent_id")
+			return
+		}
+		parentID = uint(id)
+	}
+
+	policy, err := service.ParseConflictPolicy(meta["on_conflict"], h.fileService.ConflictPolicy())
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session, err := h.uploadService.CreateTusSession(uid, parentID, name, mimeType, size, policy)
+	if err != nil {
+		if errors.Is(err, service.ErrNameConflict) {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			c.String(http.StatusInsufficientStorage, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrPermissionDenied) {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if size == 0 {
+		file, err := h.uploadService.FinishEmptyTusSession(session)
+		if err != nil {
+			h.writeError(c, err)
+			return
+		}
+		c.Header("Upload-File-Id", strconv.FormatUint(uint64(file.ID), 10))
+	}
+
+	c.Header("Location", h.basePath+"/"+session.ID)
+	c.Header("Upload-Offset", "0")
+	h.setExpires(c, session)
+	c.Status(http.StatusCreated)
+}
+
+// Head 查询当前偏移量
+func (h *TusHandler) Head(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	session, _, err := h.uploadService.GetSession(uid, c.Param("id"))
+	if err != nil || session.Protocol != model.UploadProtocolTus {
+		h.writeError(c, err)
+		return
+	}
+	offset := session.Offset
+	if session.Status == model.UploadStatusCompleted {
+		offset = session.Size
+		c.Header("Upload-File-Id", strconv.FormatUint(uint64(session.FileID), 10))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	h.setExpires(c, session)
+	c.Status(http.StatusOK)
+}
+
+// Patch 追加数据
+func (h *TusHandler) Patch(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.String(http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.String(http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	var alg string
+	var checksum []byte
+	if v := c.GetHeader("Upload-Checksum"); v != "" {
+		parts := strings.SplitN(v, " ", 2)
+		if len(parts) != 2 {
+			c.String(http.StatusBadRequest, "invalid Upload-Checksum")
+			return
+		}
+		alg = strings.ToLower(parts[0])
+		if checksum, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+			c.String(http.StatusBadRequest, "invalid Upload-Checksum")
+			return
+		}
+	}
+
+	session, file, err := h.uploadService.AppendChunk(uid, c.Param("id"), offset, c.Request.Body, c.Request.ContentLength, alg, checksum)
+	if errors.Is(err, service.ErrSessionCompleted) && offset == session.Size {
+		// 已完成的上传再次提交末尾偏移量，视为成功
+		file = &model.File{ID: session.FileID}
+		err = nil
+	}
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	if file != nil {
+		c.Header("Upload-File-Id", strconv.FormatUint(uint64(file.ID), 10))
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	h.setExpires(c, session)
+	c.Status(http.StatusNoContent)
+}
+
+// Delete 终止上传（termination 扩展）
+func (h *TusHandler) Delete(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.Abort(uid, c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Override 支持 X-HTTP-Method-Override，供无法发送 PATCH/DELETE 的客户端使用
+func (h *TusHandler) Override(c *gin.Context) {
+	switch strings.ToUpper(c.GetHeader("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		h.Patch(c)
+	case http.MethodDelete:
+		h.Delete(c)
+	case http.MethodHead:
+		h.Head(c)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TusHandler) checkVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (h *TusHandler) setExpires(c *gin.Context, session *model.UploadSession) {
+	if session.Status != model.UploadStatusCompleted {
+		c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *TusHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case err == nil, errors.Is(err, service.ErrSessionNotFound):
+		c.Status(http.StatusNotFound)
+	case errors.Is(err, service.ErrSessionExpired):
+		c.Status(http.StatusGone)
+	case errors.Is(err, service.ErrOffsetMismatch), errors.Is(err, service.ErrSessionCompleted), errors.Is(err, service.ErrNameConflict):
+		c.Status(http.StatusConflict)
+	case errors.Is(err, service.ErrUploadLocked):
+		c.Status(http.StatusLocked)
+	case errors.Is(err, service.ErrUploadTooLarge):
+		c.Status(http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.String(http.StatusInsufficientStorage, err.Error())
+	case errors.Is(err, service.ErrUnsupportedChecksum):
+		c.Status(http.StatusBadRequest)
+	case errors.Is(err, service.ErrPermissionDenied):
+		c.String(http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrChunkChecksum):
+		// 460 Checksum Mismatch（tus checksum 扩展定义）
+		c.Status(460)
+	default:
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// parseTusMetadata 解析 Upload-Metadata：以逗号分隔的 "key base64(value)" 列表
+func parseTusMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
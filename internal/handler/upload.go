@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UploadHandler struct {
+	uploadService *service.UploadService
+	fileService   *service.FileService
+}
+
+func NewUploadHandler(uploadService *service.UploadService, fileService *service.FileService) *UploadHandler {
+	return &UploadHandler{uploadService: uploadService, fileService: fileService}
+}
+
+type initUploadReq struct {
+	FileName  string `json:"file_name" binding:"required,max=255"`
+	Size      int64  `json:"size" binding:"min=0"`
+	ChunkSize int64  `json:"chunk_size"`
+	MimeType  string `json:"mime_type"`
+	ParentID  uint   `json:"parent_id"`
+	Path      string `json:"path"`
+	// 完成时目标目录已有同名文件的处理方式
+	OnConflict string `json:"on_conflict"`
+}
+
+// Init 创建分片上传会话
+func (h *UploadHandler) Init(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req initUploadReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parentID := req.ParentID
+	if req.Path != "" {
+		id, err := h.fileService.EnsurePath(uid, 0, req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
+		}
+		parentID = id
+	}
+
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	session, err := h.uploadService.InitSession(uid, parentID, req.FileName, req.MimeType, req.Size, req.ChunkSize, policy)
+	if err != nil {
+		if errors.Is(err, service.ErrNameConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPermissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Status 查询会话状态及已接收的分片，用于断点续传
+func (h *UploadHandler) Status(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	session, chunks, err := h.uploadService.GetSession(uid, c.Param("id"))
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	received := make([]int, len(chunks))
+	var receivedBytes int64
+	for i, chunk := range chunks {
+		received[i] = chunk.ChunkIndex
+		receivedBytes += chunk.Size
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session":        session,
+		"received":       received,
+		"received_bytes": receivedBytes,
+	})
+}
+
+// PutChunk 上传单个分片，请求体为分片原始内容
+func (h *UploadHandler) PutChunk(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	chunk, err := h.uploadService.PutChunk(uid, c.Param("id"), index, c.Request.Body, c.Request.ContentLength, c.GetHeader("Content-MD5"))
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, chunk)
+}
+
+// Complete 合并分片，生成文件
+func (h *UploadHandler) Complete(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.uploadService.Complete(uid, c.Param("id"))
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// Abort 取消上传
+func (h *UploadHandler) Abort(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.Abort(uid, c.Param("id")); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "upload aborted"})
+}
+
+type instantCheckReq struct {
+	Size   int64  `json:"size" binding:"min=0"`
+	SHA256 string `json:"sha256" binding:"required,len=64"`
+}
+
+// InstantCheck 秒传预检，返回需要证明的字节区间（不透露服务器上是否已有相同内容）
+func (h *UploadHandler) InstantCheck(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req instantCheckReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := h.uploadService.InstantCheck(uid, req.Size, req.SHA256)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challenge.ID,
+		"offset":       challenge.Offset,
+		"length":       challenge.Length,
+		"expires_at":   challenge.ExpiresAt,
+	})
+}
+
+type instantUploadReq struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Proof       string `json:"proof" binding:"required"`
+	Name        string `json:"name" binding:"required,max=255"`
+	ParentID    uint   `json:"parent_id"`
+	Path        string `json:"path"`
+	OnConflict  string `json:"on_conflict"`
+}
+
+// InstantUpload 提交区间证明完成秒传
+func (h *UploadHandler) InstantUpload(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req instantUploadReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parentID := req.ParentID
+	if req.Path != "" {
+		id, err := h.fileService.EnsurePath(uid, 0, req.Path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "create/find folder failed: " + err.Error()})
+			return
+		}
+		parentID = id
+	}
+
+	policy, ok := conflictPolicy(c, h.fileService, req.OnConflict)
+	if !ok {
+		return
+	}
+
+	file, err := h.uploadService.InstantUpload(uid, req.ChallengeID, req.Proof, req.Name, parentID, policy)
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+func writeUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound), errors.Is(err, service.ErrChallengeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSessionExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSessionCompleted), errors.Is(err, service.ErrChunksMissing), errors.Is(err, service.ErrNameConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProofMismatch), errors.Is(err, service.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidChunk), errors.Is(err, service.ErrChunkChecksum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageHandler struct {
+	fileService *service.FileService
+}
+
+func NewUsageHandler(fileService *service.FileService) *UsageHandler {
+	return &UsageHandler{fileService: fileService}
+}
+
+// Get 查看当前用户的存储配额与用量明细
+func (h *UsageHandler) Get(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	usage, err := h.fileService.Usage(uid)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"online-disk-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VersionHandler struct {
+	fileService *service.FileService
+}
+
+func NewVersionHandler(fileService *service.FileService) *VersionHandler {
+	return &VersionHandler{fileService: fileService}
+}
+
+// List 列出文件的历史版本
+func (h *VersionHandler) List(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	_, versions, err := h.fileService.ListVersions(uid, fileID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions, "total": len(versions)})
+}
+
+// Download 下载历史版本内容
+func (h *VersionHandler) Download(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	versionID, ok := uintParam(c, "vid")
+	if !ok {
+		return
+	}
+
+	reader, file, version, err := h.fileService.OpenVersion(uid, fileID, versionID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	serveContent(c, file.Name, version.MimeType, contentETag(version.SHA256, version.Hash), version.ModifiedAt, reader)
+}
+
+// Restore 将历史版本恢复为当前内容，当前内容成为新的历史版本
+func (h *VersionHandler) Restore(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	versionID, ok := uintParam(c, "vid")
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.RestoreVersion(uid, fileID, versionID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// Delete 删除一个历史版本
+func (h *VersionHandler) Delete(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+	versionID, ok := uintParam(c, "vid")
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.DeleteVersion(uid, fileID, versionID); err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "version deleted"})
+}
+
+// DeleteAll 删除文件的全部历史版本
+func (h *VersionHandler) DeleteAll(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	fileID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	count, err := h.fileService.DeleteAllVersions(uid, fileID)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "versions deleted", "deleted": count})
+}
+
+// GetPolicy 查询当前用户的版本保留策略
+func (h *VersionHandler) GetPolicy(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.fileService.VersionPolicy(uid))
+}
+
+type versionPolicyRequest struct {
+	MaxVersions *int `json:"max_versions"`
+	MaxAgeDays  *int `json:"max_age_days"`
+}
+
+// UpdatePolicy 修改当前用户的版本保留策略，0 表示不限制
+func (h *VersionHandler) UpdatePolicy(c *gin.Context) {
+	uid, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req versionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.fileService.SetVersionPolicy(uid, req.MaxVersions, req.MaxAgeDays)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVersionPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
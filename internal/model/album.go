@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Album 用户自建的相册。相册只引用文件而不移动它们，同一文件可以加入多个相册
+type Album struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint   `gorm:"not null;index" json:"user_id"`
+	Name        string `gorm:"size:255;not null" json:"name"`
+	Description string `gorm:"size:1024" json:"description,omitempty"`
+	CoverFileID uint   `gorm:"index" json:"cover_file_id"` // 指定的封面文件，0 表示使用最近加入的文件
+
+	ItemCount int64 `gorm:"-" json:"item_count"` // 相册中的文件数，不含回收站中的文件
+}
+
+// AlbumItem 相册中的一个文件。文件移入回收站时保留，恢复后重新出现在相册中；永久删除时随之删除
+type AlbumItem struct {
+	AlbumID   uint      `gorm:"primaryKey;autoIncrement:false" json:"album_id"`
+	FileID    uint      `gorm:"primaryKey;autoIncrement:false;index" json:"file_id"`
+	CreatedAt time.Time `json:"added_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// Blob 内容寻址的存储对象，相同内容（SHA-256）在所有用户间只保存一份。
+// 每条引用它的文件记录（及后续的历史版本）计一次引用，引用归零时才从存储中删除。
+type Blob struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Hash        string `gorm:"size:64;not null;uniqueIndex" json:"hash"` // SHA-256
+	MD5         string `gorm:"column:md5;size:32" json:"md5"`
+	Size        int64  `json:"size"`
+	StoragePath string `gorm:"size:500;not null" json:"storage_path"`
+	RefCount    int64  `gorm:"not null;default:0" json:"ref_count"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+const (
+	ContentStatusIndexed = "indexed" // 已提取文本并建立索引
+	ContentStatusSkipped = "skipped" // 不支持的格式或超出大小限制
+	ContentStatusFailed  = "failed"  // 提取失败，如文档已损坏
+)
+
+// ContentIndex 存储对象的全文索引状态。索引按内容（Blob）建立，相同内容的文件共用一份；
+// 对象引用归零被删除时索引随之删除
+type ContentIndex struct {
+	BlobID    uint      `gorm:"primaryKey;autoIncrement:false" json:"blob_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Status    string `gorm:"size:16;not null" json:"status"`
+	Format    string `gorm:"size:16" json:"format"` // text、html、docx 等
+	TextSize  int64  `json:"text_size"`             // 提取到的文本字节数
+	Truncated bool   `json:"truncated"`             // 文本超出上限，只索引了前面部分
+	Error     string `gorm:"size:255" json:"error,omitempty"`
+}
+
+// ContentTerm 倒排索引中的一项：词在某个对象文本中的出现次数及首次出现位置
+type ContentTerm struct {
+	Term   string `gorm:"primaryKey;size:128"`
+	BlobID uint   `gorm:"primaryKey;autoIncrement:false;index"`
+	Freq   int    `gorm:"not null"`
+	Pos    int    `gorm:"not null"` // 首次出现处在文本中的字节偏移
+}
+
+// ContentChunk 提取出的文本按段保存，用于生成搜索结果摘要
+type ContentChunk struct {
+	BlobID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Start  int    `gorm:"primaryKey;autoIncrement:false"` // 本段在文本中的字节偏移
+	Text   string `gorm:"type:text;not null"`
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type File struct {
@@ -11,19 +13,34 @@ type File struct {
 
 	// 基本信息
 	Name     string `gorm:"size:255;not null" json:"name"`
-	Path     string `gorm:"size:500;not null" json:"path"`
-	Size     int64  `json:"size"`
+	Path     string `gorm:"size:500;not null;index:idx_files_user_path,priority:2" json:"path"`
+	Size     int64  `json:"size"` // 文件夹为其下全部文件的总大小
 	MimeType string `gorm:"size:100" json:"mime_type"`
 	Hash     string `gorm:"size:64;index" json:"hash"` // MD5 or SHA256
+	SHA256   string `gorm:"column:sha256;size:64;index" json:"sha256"`
 
 	// 关联
-	UserID   uint `gorm:"not null;index" json:"user_id"`
+	UserID   uint `gorm:"not null;index;index:idx_files_user_path,priority:1" json:"user_id"`
 	ParentID uint `gorm:"index" json:"parent_id"` // 0 表示根目录
 
 	// 存储信息
 	StoragePath string `gorm:"size:500" json:"storage_path"` // S3 key or local path
+	BlobID      uint   `gorm:"index" json:"blob_id"`         // 0 表示早于内容寻址存储的旧记录
 
 	// 状态
 	IsDir    bool `gorm:"default:false" json:"is_dir"`
 	IsPublic bool `gorm:"default:false" json:"is_public"`
+
+	// 文件夹统计（递归，不含回收站中的子项），随写入增量更新；文件恒为 0
+	FileCount   int64 `gorm:"not null;default:0" json:"file_count"`
+	FolderCount int64 `gorm:"not null;default:0" json:"folder_count"`
+
+	// 回收站（软删除），默认查询自动排除已删除记录
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	DeletedBy        uint           `json:"deleted_by,omitempty"`
+	OriginalParentID uint           `json:"original_parent_id,omitempty"`         // 删除前所在目录
+	TrashRootID      uint           `gorm:"index" json:"trash_root_id,omitempty"` // 所属回收站条目（被删除的文件自身或其被删除的上级目录）
+
+	// 媒体元数据，存储在 media_metadata 表中，只在获取文件信息时返回
+	Metadata *MediaMetadata `gorm:"-" json:"metadata,omitempty"`
 }
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// FileRequest 文件收集链接：持有 token 的任何人都可以向目标文件夹上传文件，但不能查看或下载其中的内容。
+// 上传的文件归文件夹所有者
+type FileRequest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Token    string `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	UserID   uint   `gorm:"not null;index" json:"user_id"`   // 创建者，即文件夹所有者
+	FolderID uint   `gorm:"not null;index" json:"folder_id"` // 上传的目标文件夹
+	Title    string `gorm:"size:255" json:"title"`
+	Message  string `gorm:"size:1024" json:"message,omitempty"` // 展示给上传者的说明
+
+	// 限制，0 或空表示不限制
+	MaxFileSize       int64      `json:"max_file_size"` // 单个文件的最大字节数
+	MaxFiles          int        `json:"max_files"`     // 最多接收的文件数
+	AllowedExtensions []string   `gorm:"serializer:json;size:1024" json:"allowed_extensions"`
+	Deadline          *time.Time `json:"deadline,omitempty"`
+
+	UploadCount int `gorm:"not null;default:0" json:"upload_count"`
+}
+
+// FileRequestUpload 通过文件收集链接上传的一个文件及上传者自愿填写的信息
+type FileRequestUpload struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RequestID     uint   `gorm:"not null;index" json:"request_id"`
+	FileID        uint   `gorm:"not null;index" json:"file_id"`
+	FileName      string `gorm:"size:255" json:"file_name"`
+	Size          int64  `json:"size"`
+	UploaderName  string `gorm:"size:100" json:"uploader_name,omitempty"`
+	UploaderEmail string `gorm:"size:255" json:"uploader_email,omitempty"`
+}
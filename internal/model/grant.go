@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	GrantRoleViewer = "viewer" // 可以浏览和下载
+	GrantRoleEditor = "editor" // 还可以向文件夹上传文件、创建子文件夹
+)
+
+// FileGrant 将文件或文件夹（含整个子树）授权给指定用户
+type FileGrant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	FileID    uint   `gorm:"not null;uniqueIndex:idx_file_grants_file_grantee" json:"file_id"`
+	GranteeID uint   `gorm:"not null;uniqueIndex:idx_file_grants_file_grantee;index" json:"grantee_id"` // 被授权的用户
+	OwnerID   uint   `gorm:"not null;index" json:"owner_id"`                                            // 文件所有者
+	Role      string `gorm:"size:16;not null" json:"role"`
+
+	// 列表展示用，不落库
+	GranteeName string `gorm:"-" json:"grantee_name,omitempty"`
+	OwnerName   string `gorm:"-" json:"owner_name,omitempty"`
+	File        *File  `gorm:"-" json:"file,omitempty"`
+}
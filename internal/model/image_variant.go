@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ImageVariant 按请求参数变换后的图片（缩放、裁剪、格式转换），作为派生数据缓存。
+// 按内容（Blob）与参数生成，相同内容的文件共用；缓存超出容量时淘汰最久未使用的，
+// 对象引用归零被删除时随之删除
+type ImageVariant struct {
+	BlobID     uint      `gorm:"primaryKey;autoIncrement:false" json:"blob_id"`
+	Digest     string    `gorm:"primaryKey;size:16" json:"digest"` // 规范化参数的哈希
+	CreatedAt  time.Time `json:"created_at"`
+	AccessedAt time.Time `gorm:"index" json:"accessed_at"`
+
+	Params      string `gorm:"size:128;not null" json:"params"` // 规范化的变换参数
+	StoragePath string `gorm:"size:500;not null" json:"-"`
+	MimeType    string `gorm:"size:32;not null" json:"mime_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Bytes       int64  `json:"bytes"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+const (
+	JobTypeCopy    = "copy"
+	JobTypeExtract = "extract"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job 后台异步任务，客户端通过轮询查询进度
+type Job struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	Type     string `gorm:"size:32;not null" json:"type"`
+	Status   string `gorm:"size:16;not null;index" json:"status"`
+	SourceID uint   `json:"source_id"`           // 操作对象的文件ID
+	ResultID uint   `json:"result_id,omitempty"` // 成功后生成的文件ID
+	Total    int    `json:"total"`               // 需处理的条目数
+	Done     int    `json:"done"`                // 已处理的条目数
+	Error    string `gorm:"size:1024" json:"error,omitempty"`
+
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
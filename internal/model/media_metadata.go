@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// 媒体类型
+const (
+	MediaImage    = "image"
+	MediaAudio    = "audio"
+	MediaVideo    = "video"
+	MediaDocument = "document"
+)
+
+// MediaMetadata 从文件内容中提取的媒体元数据：照片的 EXIF、音视频的标签与时长、PDF 的页数与标题。
+// 按内容（Blob）提取，相同内容的文件共用一份；对象引用归零被删除时随之删除
+type MediaMetadata struct {
+	BlobID    uint      `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	CreatedAt time.Time `json:"-"`
+
+	Kind  string `gorm:"size:16;index" json:"kind"` // image、audio、video、document，空表示不支持的格式
+	Error string `gorm:"size:255" json:"-"`         // 提取失败的原因
+
+	// 图片与视频。宽高为按 EXIF 方向摆正后的尺寸
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	CameraMake  string     `gorm:"size:64" json:"camera_make,omitempty"`
+	CameraModel string     `gorm:"size:64" json:"camera_model,omitempty"`
+	TakenAt     *time.Time `gorm:"index" json:"taken_at,omitempty"` // 拍摄时间（UTC）
+	// TakenAt 为照片未记录时区的当地时间（以 UTC 的形式保存），按查看者所选的时区解释。
+	// 为空表示升级前提取、尚未区分，会重新提取
+	TakenAtLocal *bool    `json:"taken_at_local,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+
+	// 音视频与文档
+	Title     string  `gorm:"size:255" json:"title,omitempty"`
+	Artist    string  `gorm:"size:255" json:"artist,omitempty"`
+	Album     string  `gorm:"size:255" json:"album,omitempty"`
+	Duration  float64 `json:"duration,omitempty"` // 秒
+	PageCount int     `json:"page_count,omitempty"`
+}
+
+func (MediaMetadata) TableName() string {
+	return "media_metadata"
+}
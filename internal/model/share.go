@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	SharePermissionRead   = "read"   // 只能查看和下载
+	SharePermissionUpload = "upload" // 还可以向共享的文件夹上传文件
+)
+
+// Share 分享链接：持有随机 token 的任何人都可以在有效期内访问分享的文件或文件夹
+type Share struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Token  string `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	UserID uint   `gorm:"not null;index" json:"user_id"` // 分享者
+	FileID uint   `gorm:"not null;index" json:"file_id"` // 分享的文件或文件夹
+
+	PasswordHash  string     `gorm:"size:255" json:"-"`
+	HasPassword   bool       `json:"has_password"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // 为空表示永不过期
+	MaxDownloads  int        `json:"max_downloads"`        // 0 表示不限制
+	DownloadCount int        `gorm:"not null;default:0" json:"download_count"`
+	Permission    string     `gorm:"size:16;not null" json:"permission"`
+
+	// 列表展示用，不落库
+	FileName string `gorm:"-" json:"file_name,omitempty"`
+	IsDir    bool   `gorm:"-" json:"is_dir"`
+}
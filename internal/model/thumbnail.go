@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Thumbnail 图片的一种尺寸的缩略图。按内容（Blob）生成，相同内容的文件共用；
+// 对象引用归零被删除时缩略图随之删除。图片无法解码时每个尺寸保存一条只有 Error 的记录，不再重试
+type Thumbnail struct {
+	BlobID    uint      `gorm:"primaryKey;autoIncrement:false" json:"blob_id"`
+	Size      string    `gorm:"primaryKey;size:16" json:"size"` // small、medium 或 large
+	CreatedAt time.Time `json:"created_at"`
+
+	StoragePath string `gorm:"size:500;not null" json:"-"`
+	MimeType    string `gorm:"size:32;not null" json:"mime_type"` // 不透明的图片为 JPEG，否则为 PNG
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Bytes       int64  `json:"bytes"`
+	Error       string `gorm:"size:255" json:"-"` // 生成失败的原因，失败记录没有存储对象
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+)
+
+// 上传会话状态
+const (
+	UploadStatusUploading = "uploading"
+	UploadStatusCompleted = "completed"
+)
+
+// 上传协议
+const (
+	UploadProtocolChunked = "chunked" // 按固定大小编号分片
+	UploadProtocolTus     = "tus"     // tus 1.0，按偏移量追加
+)
+
+// UploadSession 分片上传会话，完成前不会生成 File 记录
+type UploadSession struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	ParentID uint   `json:"parent_id"`
+	FileName string `gorm:"size:255;not null" json:"file_name"`
+	MimeType string `gorm:"size:100" json:"mime_type"`
+	// 完成时目标目录已有同名文件的处理方式
+	OnConflict string `gorm:"size:16" json:"on_conflict"`
+
+	// 分片信息
+	Protocol    string `gorm:"size:16;default:chunked" json:"protocol"`
+	Size        int64  `json:"size"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	Offset      int64  `gorm:"column:upload_offset" json:"offset"` // tus 已接收的字节数
+
+	// 创建时在文件所有者名下为声明的大小预留的配额，完成时转为文件的用量，取消或过期时归还
+	QuotaUserID   uint  `json:"-"`
+	ReservedBytes int64 `gorm:"not null;default:0" json:"-"`
+
+	Status    string    `gorm:"size:16;index" json:"status"`
+	FileID    uint      `json:"file_id"` // 完成后生成的文件ID
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
+
+// UploadChunk 已接收的分片
+type UploadChunk struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SessionID  string `gorm:"size:32;not null;uniqueIndex:idx_upload_chunk" json:"-"`
+	ChunkIndex int    `gorm:"not null;uniqueIndex:idx_upload_chunk" json:"index"`
+	Offset     int64  `json:"offset"`
+	Size       int64  `json:"size"`
+	Hash       string `gorm:"size:64" json:"hash"` // 分片 MD5
+}
+
+// InstantChallenge 秒传校验挑战：客户端需证明持有内容中服务器随机指定的一段字节
+type InstantChallenge struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"challenge_id"`
+	CreatedAt time.Time `json:"-"`
+
+	UserID    uint      `gorm:"not null;index" json:"-"`
+	SHA256    string    `gorm:"column:sha256;size:64;not null" json:"-"`
+	Size      int64     `json:"-"`
+	BlobID    uint      `json:"-"` // 内容相同的已有存储对象
+	Offset    int64     `gorm:"column:range_offset" json:"offset"`
+	Length    int64     `gorm:"column:range_length" json:"length"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
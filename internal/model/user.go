@@ -13,4 +13,8 @@ type User struct {
 	Email    string `gorm:"uniqueIndex;size:128" json:"email"`
 	Password string `json:"-"` // hashed
 	Nickname string `gorm:"size:64" json:"nickname"`
+
+	// 存储配额
+	QuotaBytes *int64 `json:"quota_bytes,omitempty"`                // 为空时使用服务端默认配额，小于等于 0 表示不限制
+	UsedBytes  int64  `gorm:"not null;default:0" json:"used_bytes"` // 文件（含回收站）与历史版本占用的字节数
 }
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// FileVersion 文件的历史版本：覆盖同名文件时保存被替换的内容
+type FileVersion struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"` // 成为历史版本的时间
+
+	FileID   uint   `gorm:"not null;index" json:"file_id"`
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	Size     int64  `json:"size"`
+	MimeType string `gorm:"size:100" json:"mime_type"`
+	Hash     string `gorm:"size:64" json:"hash"`
+	SHA256   string `gorm:"column:sha256;size:64" json:"sha256"`
+
+	// 存储信息，与 File 相同：BlobID 为 0 表示早于内容寻址存储的旧内容
+	StoragePath string `gorm:"size:500" json:"-"`
+	BlobID      uint   `gorm:"index" json:"blob_id"`
+
+	ModifiedAt time.Time `json:"modified_at"` // 该版本内容的最后修改时间
+}
+
+// VersionPolicy 用户的历史版本保留策略，未设置时使用服务端默认值；字段为 0 表示不限制
+type VersionPolicy struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	MaxVersions int `json:"max_versions"` // 每个文件最多保留的历史版本数
+	MaxAgeDays  int `json:"max_age_days"` // 历史版本最长保留天数
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AlbumRepository struct {
+	db *gorm.DB
+}
+
+func NewAlbumRepository(db *gorm.DB) *AlbumRepository {
+	return &AlbumRepository{db: db}
+}
+
+func (r *AlbumRepository) Create(album *model.Album) error {
+	return r.db.Create(album).Error
+}
+
+func (r *AlbumRepository) FindByIDAndUser(albumID, userID uint) (*model.Album, error) {
+	var album model.Album
+	if err := r.db.Where("id = ? AND user_id = ?", albumID, userID).First(&album).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// FindByUser 分页列出用户的相册，按修改时间倒序
+func (r *AlbumRepository) FindByUser(userID uint, offset, limit int) ([]*model.Album, int64, error) {
+	var albums []*model.Album
+	var total int64
+	query := r.db.Model(&model.Album{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("updated_at DESC, id DESC").Offset(offset).Limit(limit).Find(&albums).Error; err != nil {
+		return nil, 0, err
+	}
+	return albums, total, nil
+}
+
+// UpdateFields 更新相册的字段并刷新修改时间
+func (r *AlbumRepository) UpdateFields(albumID uint, fields map[string]interface{}) error {
+	return r.db.Model(&model.Album{ID: albumID}).Updates(fields).Error
+}
+
+// Delete 删除相册及其中的条目，文件本身保留
+func (r *AlbumRepository) Delete(albumID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", albumID).Delete(&model.AlbumItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Album{}, albumID).Error
+	})
+}
+
+// itemFiles 返回相册中未删除的文件
+func (r *AlbumRepository) itemFiles(albumID uint) *gorm.DB {
+	return r.db.Model(&model.File{}).
+		Joins("JOIN album_items ON album_items.file_id = files.id").
+		Where("album_items.album_id = ?", albumID)
+}
+
+// FillStats 填充相册的文件数。未指定封面或封面文件在回收站中时，以最近加入的文件作为封面
+func (r *AlbumRepository) FillStats(albums []*model.Album) error {
+	if len(albums) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(albums))
+	for i, a := range albums {
+		ids[i] = a.ID
+	}
+	var counts []struct {
+		AlbumID uint
+		Count   int64
+	}
+	err := r.db.Model(&model.File{}).
+		Select("album_items.album_id, COUNT(*) AS count").
+		Joins("JOIN album_items ON album_items.file_id = files.id").
+		Where("album_items.album_id IN ?", ids).
+		Group("album_items.album_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	byAlbum := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		byAlbum[c.AlbumID] = c.Count
+	}
+	for _, a := range albums {
+		a.ItemCount = byAlbum[a.ID]
+		if a.ItemCount == 0 {
+			a.CoverFileID = 0
+			continue
+		}
+		var cover []uint
+		err := r.itemFiles(a.ID).
+			Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "CASE WHEN files.id = ? THEN 0 ELSE 1 END, album_items.created_at DESC, files.id DESC", Vars: []interface{}{a.CoverFileID}}}).
+			Limit(1).Pluck("files.id", &cover).Error
+		if err != nil {
+			return err
+		}
+		if len(cover) > 0 {
+			a.CoverFileID = cover[0]
+		}
+	}
+	return nil
+}
+
+// FindFiles 分页列出相册中未删除的文件，按加入时间倒序
+func (r *AlbumRepository) FindFiles(albumID uint, offset, limit int) ([]*model.File, int64, error) {
+	var files []*model.File
+	var total int64
+	if err := r.itemFiles(albumID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := r.itemFiles(albumID).Order("album_items.created_at DESC, files.id DESC").Offset(offset).Limit(limit).Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// HasFile 判断文件是否在相册中（不论是否已移入回收站）
+func (r *AlbumRepository) HasFile(albumID, fileID uint) (bool, error) {
+	var n int64
+	err := r.db.Model(&model.AlbumItem{}).Where("album_id = ? AND file_id = ?", albumID, fileID).Count(&n).Error
+	return n > 0, err
+}
+
+// AddItems 将文件加入相册，已在相册中的忽略，返回新加入的数量
+func (r *AlbumRepository) AddItems(items []*model.AlbumItem) (int64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(items)
+	return res.RowsAffected, res.Error
+}
+
+// RemoveItem 从相册中移除文件，文件不在相册中时返回 false；移除的是封面时恢复为默认封面
+func (r *AlbumRepository) RemoveItem(albumID, fileID uint) (bool, error) {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("album_id = ? AND file_id = ?", albumID, fileID).Delete(&model.AlbumItem{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		removed = true
+		return tx.Model(&model.Album{}).Where("id = ? AND cover_file_id = ?", albumID, fileID).
+			UpdateColumn("cover_file_id", 0).Error
+	})
+	return removed, err
+}
+
+// DeleteByFileIDs 从所有相册中移除这些文件，并清除以它们为封面的设置
+func (r *AlbumRepository) DeleteByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	if err := r.db.Where("file_id IN ?", fileIDs).Delete(&model.AlbumItem{}).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&model.Album{}).Where("cover_file_id IN ?", fileIDs).UpdateColumn("cover_file_id", 0).Error
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type BlobRepository struct {
+	db *gorm.DB
+}
+
+func NewBlobRepository(db *gorm.DB) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+func (r *BlobRepository) Create(blob *model.Blob) error {
+	return r.db.Create(blob).Error
+}
+
+func (r *BlobRepository) FindByID(blobID uint) (*model.Blob, error) {
+	var blob model.Blob
+	if err := r.db.First(&blob, blobID).Error; err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+func (r *BlobRepository) FindByHash(hash string) (*model.Blob, error) {
+	var blob model.Blob
+	if err := r.db.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// IncRef 增加引用计数。已归零（正在删除）的对象不能再被引用，此时返回 gorm.ErrRecordNotFound
+func (r *BlobRepository) IncRef(blobID uint, delta int64) error {
+	res := r.db.Model(&model.Blob{}).
+		Where("id = ? AND ref_count > 0", blobID).
+		UpdateColumn("ref_count", gorm.Expr("ref_count + ?", delta))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DecRef 减少引用计数，返回更新后的记录
+func (r *BlobRepository) DecRef(blobID uint, delta int64) (*model.Blob, error) {
+	res := r.db.Model(&model.Blob{}).
+		Where("id = ?", blobID).
+		UpdateColumn("ref_count", gorm.Expr("ref_count - ?", delta))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return r.FindByID(blobID)
+}
+
+// DeleteIfUnreferenced 删除引用计数已归零的记录，返回是否删除
+func (r *BlobRepository) DeleteIfUnreferenced(blobID uint) (bool, error) {
+	res := r.db.Where("id = ? AND ref_count <= 0", blobID).Delete(&model.Blob{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"strings"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ContentIndexRepository struct {
+	db *gorm.DB
+}
+
+func NewContentIndexRepository(db *gorm.DB) *ContentIndexRepository {
+	return &ContentIndexRepository{db: db}
+}
+
+// ContentScope 全文搜索的范围：UserID 的全部文件，或其中的单个文件（FileID）或文件夹（UnderPath，以 / 结尾）
+type ContentScope struct {
+	UserID    uint
+	FileID    uint
+	UnderPath string
+}
+
+// ContentMatch 全文搜索命中的文件及其得分（各检索词出现次数之和）
+type ContentMatch struct {
+	FileID uint
+	BlobID uint
+	Score  int64
+}
+
+// FindPendingBlobs 按ID顺序查找 afterID 之后被未删除的文件引用、但尚未建立索引的对象
+func (r *ContentIndexRepository) FindPendingBlobs(afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.File{}).
+		Distinct("files.blob_id").
+		Joins("LEFT JOIN content_indices ON content_indices.blob_id = files.blob_id").
+		Where("files.blob_id > ? AND files.is_dir = ? AND content_indices.blob_id IS NULL", afterID, false).
+		Order("files.blob_id ASC").
+		Limit(limit).
+		Pluck("files.blob_id", &ids).Error
+	return ids, err
+}
+
+// Save 保存对象的索引状态、词项与文本。对象已被删除时不保存，返回 false
+func (r *ContentIndexRepository) Save(index *model.ContentIndex, terms []*model.ContentTerm, chunks []*model.ContentChunk) (bool, error) {
+	saved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var blobs int64
+		if err := tx.Model(&model.Blob{}).Where("id = ?", index.BlobID).Count(&blobs).Error; err != nil || blobs == 0 {
+			return err
+		}
+		repo := NewContentIndexRepository(tx)
+		if err := repo.DeleteByBlob(index.BlobID); err != nil {
+			return err
+		}
+		if err := tx.Create(index).Error; err != nil {
+			return err
+		}
+		// MySQL 默认的排序规则不区分大小写和重音，不同的词可能被视为重复主键，此时只保留一个
+		if len(terms) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(terms, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(chunks) > 0 {
+			if err := tx.CreateInBatches(chunks, 50).Error; err != nil {
+				return err
+			}
+		}
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+// DeleteByBlob 删除对象的全部索引数据
+func (r *ContentIndexRepository) DeleteByBlob(blobID uint) error {
+	if err := r.db.Where("blob_id = ?", blobID).Delete(&model.ContentTerm{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("blob_id = ?", blobID).Delete(&model.ContentChunk{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("blob_id = ?", blobID).Delete(&model.ContentIndex{}).Error
+}
+
+// DeleteOrphans 删除对象已不存在的索引数据，返回删除的对象数
+func (r *ContentIndexRepository) DeleteOrphans() (int64, error) {
+	orphans := r.db.Model(&model.Blob{}).Select("id")
+	if err := r.db.Where("blob_id NOT IN (?)", orphans).Delete(&model.ContentTerm{}).Error; err != nil {
+		return 0, err
+	}
+	if err := r.db.Where("blob_id NOT IN (?)", orphans).Delete(&model.ContentChunk{}).Error; err != nil {
+		return 0, err
+	}
+	res := r.db.Where("blob_id NOT IN (?)", orphans).Delete(&model.ContentIndex{})
+	return res.RowsAffected, res.Error
+}
+
+// Search 在 scopes 范围内未删除的文件中查找包含全部 terms 的文件，按得分从高到低分页返回
+func (r *ContentIndexRepository) Search(terms []string, scopes []ContentScope, offset, limit int) ([]*ContentMatch, int64, error) {
+	var matches []*ContentMatch
+	if len(terms) == 0 || len(scopes) == 0 {
+		return matches, 0, nil
+	}
+	hits := r.db.Model(&model.ContentTerm{}).
+		Select("blob_id, SUM(freq) AS score").
+		Where("term IN ?", terms).
+		Group("blob_id").
+		Having("COUNT(*) = ?", len(terms))
+
+	clauses := make([]string, 0, len(scopes))
+	args := make([]interface{}, 0, len(scopes)*3)
+	for _, scope := range scopes {
+		switch {
+		case scope.FileID != 0:
+			clauses = append(clauses, "(files.user_id = ? AND files.id = ?)")
+			args = append(args, scope.UserID, scope.FileID)
+		case scope.UnderPath != "":
+			clauses = append(clauses, "(files.user_id = ? AND SUBSTR(files.path, 1, ?) = ?)")
+			args = append(args, scope.UserID, len([]rune(scope.UnderPath)), scope.UnderPath)
+		default:
+			clauses = append(clauses, "files.user_id = ?")
+			args = append(args, scope.UserID)
+		}
+	}
+	query := r.db.Model(&model.File{}).
+		Joins("JOIN (?) AS hits ON hits.blob_id = files.blob_id", hits).
+		Where("files.is_dir = ?", false).
+		Where("("+strings.Join(clauses, " OR ")+")", args...)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Select("files.id AS file_id, files.blob_id, hits.score").
+		Order("hits.score DESC, files.updated_at DESC, files.id DESC").
+		Offset(offset).Limit(limit).
+		Scan(&matches).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return matches, total, nil
+}
+
+// FindTerms 查找这些对象中的指定词项，用于定位摘要
+func (r *ContentIndexRepository) FindTerms(blobIDs []uint, terms []string) ([]*model.ContentTerm, error) {
+	var found []*model.ContentTerm
+	if len(blobIDs) == 0 || len(terms) == 0 {
+		return found, nil
+	}
+	if err := r.db.Where("blob_id IN ? AND term IN ?", blobIDs, terms).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// FindChunks 按顺序返回对象文本中起始偏移位于 [from, to] 的段
+func (r *ContentIndexRepository) FindChunks(blobID uint, from, to int) ([]*model.ContentChunk, error) {
+	var chunks []*model.ContentChunk
+	err := r.db.Where("blob_id = ? AND start >= ? AND start <= ?", blobID, from, to).
+		Order("start ASC").Find(&chunks).Error
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
@@ -4,8 +4,10 @@ import (
 	"online-disk-server/internal/model"
 
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type FileRepository struct {
@@ -20,6 +22,25 @@ func (r *FileRepository) Create(file *model.File) error {
 	return r.db.Create(file).Error
 }
 
+// FindByID 按ID查找文件，不限制所有者，由调用方检查访问权限
+func (r *FileRepository) FindByID(fileID uint) (*model.File, error) {
+	var file model.File
+	if err := r.db.Where("id = ?", fileID).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindByIDForUpdate 在事务中重新读取未删除的记录并加行锁（数据库不支持时忽略），
+// 供按当前值计算文件夹统计增量，避免使用事务开始前读到的旧数据
+func (r *FileRepository) FindByIDForUpdate(fileID uint) (*model.File, error) {
+	var file model.File
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", fileID).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
 func (r *FileRepository) FindByIDAndUser(fileID, userID uint) (*model.File, error) {
 	var file model.File
 	if err := r.db.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
@@ -28,6 +49,27 @@ func (r *FileRepository) FindByIDAndUser(fileID, userID uint) (*model.File, erro
 	return &file, nil
 }
 
+// FindByIDs 按ID批量查找未删除的文件，不限制所有者，由调用方检查访问权限
+func (r *FileRepository) FindByIDs(ids []uint) ([]*model.File, error) {
+	var files []*model.File
+	if len(ids) == 0 {
+		return files, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindAnyByBlob 查找引用该存储对象的一个未删除文件，用于按文件名和类型判断内容格式
+func (r *FileRepository) FindAnyByBlob(blobID uint) (*model.File, error) {
+	var file model.File
+	if err := r.db.Where("blob_id = ? AND is_dir = ?", blobID, false).Order("id ASC").First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
 func (r *FileRepository) FindByUserAndParent(userID, parentID uint, offset, limit int) ([]*model.File, int64, error) {
 	var files []*model.File
 	var total int64
@@ -45,8 +87,17 @@ func (r *FileRepository) FindByUserAndParent(userID, parentID uint, offset, limi
 	return files, total, nil
 }
 
+// Delete 永久删除文件记录（包括回收站中的记录）
 func (r *FileRepository) Delete(fileID, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", fileID, userID).Delete(&model.File{}).Error
+	return r.db.Unscoped().Where("id = ? AND user_id = ?", fileID, userID).Delete(&model.File{}).Error
+}
+
+// DeleteByIDs 永久删除多条记录
+func (r *FileRepository) DeleteByIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Unscoped().Where("id IN ?", ids).Delete(&model.File{}).Error
 }
 
 func (r *FileRepository) FindByHash(hash string, userID uint) (*model.File, error) {
@@ -57,6 +108,13 @@ func (r *FileRepository) FindByHash(hash string, userID uint) (*model.File, erro
 	return &file, nil
 }
 
+// CountByStoragePath 统计引用同一存储对象的文件记录数
+func (r *FileRepository) CountByStoragePath(storagePath string) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.File{}).Where("storage_path = ?", storagePath).Count(&count).Error
+	return count, err
+}
+
 // FindChildFolder 查找指定父目录下的子文件夹
 func (r *FileRepository) FindChildFolder(userID, parentID uint, name string) (*model.File, error) {
 	var folder model.File
@@ -66,27 +124,6 @@ func (r *FileRepository) FindChildFolder(userID, parentID uint, name string) (*m
 	return &folder, nil
 }
 
-// FindOrCreateFolder 在父目录下查找文件夹，不存在则创建
-func (r *FileRepository) FindOrCreateFolder(userID, parentID uint, name, fullPath string) (*model.File, error) {
-	// 先查
-	if f, err := r.FindChildFolder(userID, parentID, name); err == nil {
-		return f, nil
-	}
-	// 创建
-	folder := &model.File{
-		Name:     name,
-		Path:     fullPath,
-		Size:     0,
-		UserID:   userID,
-		ParentID: parentID,
-		IsDir:    true,
-	}
-	if err := r.db.Create(folder).Error; err != nil {
-		return nil, err
-	}
-	return folder, nil
-}
-
 // FindDirByPath 递归查找用户某 path 下的目录节点（path 以 / 开头，根为 /，不含文件名）
 func (r *FileRepository) FindDirByPath(userID uint, path string) (*model.File, error) {
 	path = strings.TrimPrefix(path, "/")
@@ -113,3 +150,249 @@ func (r *FileRepository) FindDirByPath(userID uint, path string) (*model.File, e
 	}
 	return folder, nil
 }
+
+// FindChildren 查找多个目录下的直接子项
+func (r *FileRepository) FindChildren(userID uint, parentIDs []uint) ([]*model.File, error) {
+	var files []*model.File
+	if len(parentIDs) == 0 {
+		return files, nil
+	}
+	if err := r.db.Where("user_id = ? AND parent_id IN ?", userID, parentIDs).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// MoveToTrash 将一批记录移入回收站，rootID 为所属回收站条目
+func (r *FileRepository) MoveToTrash(ids []uint, rootID, deletedBy uint, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.File{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"deleted_at":         now,
+		"deleted_by":         deletedBy,
+		"original_parent_id": gorm.Expr("parent_id"),
+		"trash_root_id":      rootID,
+	}).Error
+}
+
+// FindTrashRoots 分页列出用户回收站中的条目（不含随上级目录一起删除的子项）
+func (r *FileRepository) FindTrashRoots(userID uint, offset, limit int) ([]*model.File, int64, error) {
+	var files []*model.File
+	var total int64
+
+	query := r.db.Unscoped().Model(&model.File{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL AND trash_root_id = id", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Offset(offset).Limit(limit).Order("deleted_at DESC").Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// FindTrashRoot 查找用户回收站中的条目
+func (r *FileRepository) FindTrashRoot(fileID, userID uint) (*model.File, error) {
+	var file model.File
+	if err := r.db.Unscoped().
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL AND trash_root_id = id", fileID, userID).
+		First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindExpiredTrashRoots 查找删除时间早于 before 的回收站条目
+func (r *FileRepository) FindExpiredTrashRoots(before time.Time, limit int) ([]*model.File, error) {
+	var files []*model.File
+	if err := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND trash_root_id = id", before).
+		Limit(limit).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindByTrashRoot 查找属于同一回收站条目的全部记录
+func (r *FileRepository) FindByTrashRoot(rootID uint) ([]*model.File, error) {
+	var files []*model.File
+	if err := r.db.Unscoped().Where("trash_root_id = ? AND deleted_at IS NOT NULL", rootID).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// RestoreTrash 恢复属于同一回收站条目的全部记录
+func (r *FileRepository) RestoreTrash(rootID uint) error {
+	return r.db.Unscoped().Model(&model.File{}).
+		Where("trash_root_id = ? AND deleted_at IS NOT NULL", rootID).
+		Updates(map[string]interface{}{
+			"deleted_at":         nil,
+			"deleted_by":         0,
+			"original_parent_id": 0,
+			"trash_root_id":      0,
+		}).Error
+}
+
+// FindChildByName 查找目录下指定名称的文件或文件夹
+func (r *FileRepository) FindChildByName(userID, parentID uint, name string) (*model.File, error) {
+	var file model.File
+	if err := r.db.Where("user_id = ? AND parent_id = ? AND name = ?", userID, parentID, name).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// UpdateFields 更新文件记录的指定字段（包括回收站中的记录）
+func (r *FileRepository) UpdateFields(fileID uint, fields map[string]interface{}) error {
+	return r.db.Unscoped().Model(&model.File{}).Where("id = ?", fileID).Updates(fields).Error
+}
+
+// DuplicateName 同一目录下重名的一组记录
+type DuplicateName struct {
+	UserID      uint
+	ParentID    uint
+	Name        string
+	TrashRootID uint
+}
+
+// FindDuplicateNames 查找同一目录下名称重复的记录组（包括回收站中的记录）
+func (r *FileRepository) FindDuplicateNames() ([]DuplicateName, error) {
+	var dups []DuplicateName
+	err := r.db.Unscoped().Model(&model.File{}).
+		Select("user_id, parent_id, name, trash_root_id").
+		Group("user_id, parent_id, name, trash_root_id").
+		Having("COUNT(*) > 1").
+		Scan(&dups).Error
+	return dups, err
+}
+
+// FindByName 按目录与名称查找记录（包括回收站中的记录），按ID升序
+func (r *FileRepository) FindByName(dup DuplicateName) ([]*model.File, error) {
+	var files []*model.File
+	err := r.db.Unscoped().
+		Where("user_id = ? AND parent_id = ? AND name = ? AND trash_root_id = ?", dup.UserID, dup.ParentID, dup.Name, dup.TrashRootID).
+		Order("id ASC").Find(&files).Error
+	return files, err
+}
+
+// SetPublic 更新文件是否被公开分享，不改变修改时间
+func (r *FileRepository) SetPublic(fileID uint, public bool) error {
+	return r.db.Unscoped().Model(&model.File{}).Where("id = ?", fileID).UpdateColumn("is_public", public).Error
+}
+
+// SizeSum 一组文件的数量与总字节数
+type SizeSum struct {
+	Files int64
+	Bytes int64
+}
+
+// MimeSizeSum 某一 MIME 类型的文件数量与总字节数
+type MimeSizeSum struct {
+	MimeType string
+	Files    int64
+	Bytes    int64
+}
+
+const sizeSumColumns = "COUNT(*) AS files, COALESCE(SUM(size), 0) AS bytes"
+
+// SumByMimeType 按 MIME 类型统计用户未删除的文件
+func (r *FileRepository) SumByMimeType(userID uint) ([]MimeSizeSum, error) {
+	var sums []MimeSizeSum
+	err := r.db.Model(&model.File{}).
+		Select("mime_type, "+sizeSumColumns).
+		Where("user_id = ? AND is_dir = ?", userID, false).
+		Group("mime_type").
+		Scan(&sums).Error
+	return sums, err
+}
+
+// SumTrash 统计用户回收站中的文件
+func (r *FileRepository) SumTrash(userID uint) (SizeSum, error) {
+	var sum SizeSum
+	err := r.db.Unscoped().Model(&model.File{}).
+		Select(sizeSumColumns).
+		Where("user_id = ? AND is_dir = ? AND deleted_at IS NOT NULL", userID, false).
+		Scan(&sum).Error
+	return sum, err
+}
+
+// SumInParent 统计目录下直接包含的未删除文件（不含子目录中的文件）
+func (r *FileRepository) SumInParent(userID, parentID uint) (SizeSum, error) {
+	var sum SizeSum
+	err := r.db.Model(&model.File{}).
+		Select(sizeSumColumns).
+		Where("user_id = ? AND parent_id = ? AND is_dir = ?", userID, parentID, false).
+		Scan(&sum).Error
+	return sum, err
+}
+
+// FindRootFolders 列出用户根目录下未删除的文件夹
+func (r *FileRepository) FindRootFolders(userID uint) ([]*model.File, error) {
+	var folders []*model.File
+	if err := r.db.Where("user_id = ? AND parent_id = 0 AND is_dir = ?", userID, true).Order("name ASC").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// AddFolderStats 原子地调整用户若干文件夹（按路径，不含回收站中的项）的递归大小与文件、文件夹数量
+func (r *FileRepository) AddFolderStats(userID uint, paths []string, size, files, folders int64) error {
+	if len(paths) == 0 || (size == 0 && files == 0 && folders == 0) {
+		return nil
+	}
+	return r.db.Model(&model.File{}).
+		Where("user_id = ? AND is_dir = ? AND path IN ?", userID, true, paths).
+		UpdateColumns(map[string]interface{}{
+			"size":         gorm.Expr("size + ?", size),
+			"file_count":   gorm.Expr("file_count + ?", files),
+			"folder_count": gorm.Expr("folder_count + ?", folders),
+		}).Error
+}
+
+// SetFolderStats 直接写入文件夹的统计值，用于重建
+func (r *FileRepository) SetFolderStats(folderID uint, size, files, folders int64) error {
+	return r.db.Unscoped().Model(&model.File{}).Where("id = ?", folderID).UpdateColumns(map[string]interface{}{
+		"size":         size,
+		"file_count":   files,
+		"folder_count": folders,
+	}).Error
+}
+
+// HasStaleFolderStats 判断是否存在统计为空却有未删除子项的文件夹（升级前创建的数据）
+func (r *FileRepository) HasStaleFolderStats() (bool, error) {
+	var ids []uint
+	err := r.db.Model(&model.File{}).
+		Where("is_dir = ? AND file_count = 0 AND folder_count = 0", true).
+		Where("EXISTS (SELECT 1 FROM files AS c WHERE c.parent_id = files.id AND c.user_id = files.user_id AND c.deleted_at IS NULL)").
+		Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
+
+// FindFolderOwnerIDs 列出拥有文件夹的用户ID（包括回收站中的文件夹）
+func (r *FileRepository) FindFolderOwnerIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&model.File{}).Where("is_dir = ?", true).Distinct().Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// FindLegacyPathOwnerIDs 返回存在旧版本路径的用户。旧版本上传到子文件夹的文件仍以 "/名称" 作为路径，
+// 子文件夹中的项正确的路径至少包含两个 "/"
+func (r *FileRepository) FindLegacyPathOwnerIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&model.File{}).
+		Where("parent_id <> 0 AND path NOT LIKE ?", "/%/%").
+		Distinct().Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// FindTreeByUser 读取用户全部记录（包括回收站中的记录）中修复路径与计算文件夹统计所需的字段
+func (r *FileRepository) FindTreeByUser(userID uint) ([]*model.File, error) {
+	var files []*model.File
+	err := r.db.Unscoped().
+		Select("id, name, path, parent_id, is_dir, size, file_count, folder_count, trash_root_id").
+		Where("user_id = ?", userID).
+		Find(&files).Error
+	return files, err
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type FileRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewFileRequestRepository(db *gorm.DB) *FileRequestRepository {
+	return &FileRequestRepository{db: db}
+}
+
+func (r *FileRequestRepository) Create(req *model.FileRequest) error {
+	return r.db.Create(req).Error
+}
+
+func (r *FileRequestRepository) FindByToken(token string) (*model.FileRequest, error) {
+	var req model.FileRequest
+	if err := r.db.Where("token = ?", token).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *FileRequestRepository) FindByIDAndUser(requestID, userID uint) (*model.FileRequest, error) {
+	var req model.FileRequest
+	if err := r.db.Where("id = ? AND user_id = ?", requestID, userID).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindByUser 分页列出用户创建的文件收集链接，按创建时间倒序
+func (r *FileRequestRepository) FindByUser(userID uint, offset, limit int) ([]*model.FileRequest, int64, error) {
+	var reqs []*model.FileRequest
+	var total int64
+	query := r.db.Model(&model.FileRequest{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&reqs).Error; err != nil {
+		return nil, 0, err
+	}
+	return reqs, total, nil
+}
+
+// Reserve 占用一个上传名额；已达到文件数上限时不更新并返回 false。条件更新保证并发上传不会超出上限
+func (r *FileRequestRepository) Reserve(requestID uint) (bool, error) {
+	res := r.db.Model(&model.FileRequest{}).
+		Where("id = ? AND (max_files = 0 OR upload_count < max_files)", requestID).
+		UpdateColumn("upload_count", gorm.Expr("upload_count + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+// Release 归还上传失败时占用的名额
+func (r *FileRequestRepository) Release(requestID uint) error {
+	return r.db.Model(&model.FileRequest{}).
+		Where("id = ? AND upload_count > 0", requestID).
+		UpdateColumn("upload_count", gorm.Expr("upload_count - 1")).Error
+}
+
+// Delete 删除文件收集链接及其上传记录，已上传的文件保留
+func (r *FileRequestRepository) Delete(requestID uint) error {
+	if err := r.db.Where("request_id = ?", requestID).Delete(&model.FileRequestUpload{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&model.FileRequest{}, requestID).Error
+}
+
+// DeleteByFolderIDs 删除以这些文件夹为目标的文件收集链接及其上传记录
+func (r *FileRequestRepository) DeleteByFolderIDs(folderIDs []uint) error {
+	if len(folderIDs) == 0 {
+		return nil
+	}
+	requestIDs := r.db.Model(&model.FileRequest{}).Select("id").Where("folder_id IN ?", folderIDs)
+	if err := r.db.Where("request_id IN (?)", requestIDs).Delete(&model.FileRequestUpload{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("folder_id IN ?", folderIDs).Delete(&model.FileRequest{}).Error
+}
+
+func (r *FileRequestRepository) CreateUpload(upload *model.FileRequestUpload) error {
+	return r.db.Create(upload).Error
+}
+
+// FindUploads 分页列出通过链接上传的文件，按上传时间倒序
+func (r *FileRequestRepository) FindUploads(requestID uint, offset, limit int) ([]*model.FileRequestUpload, int64, error) {
+	var uploads []*model.FileRequestUpload
+	var total int64
+	query := r.db.Model(&model.FileRequestUpload{}).Where("request_id = ?", requestID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&uploads).Error; err != nil {
+		return nil, 0, err
+	}
+	return uploads, total, nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// FileSearch 文件搜索条件，零值字段表示不限制。
+// NamePattern 与 Extensions 使用 LIKE 模式（% _ \ 已由调用方转义），不区分大小写
+type FileSearch struct {
+	NamePattern   string
+	MimeTypes     []string // 完整类型，如 image/png
+	MimePrefixes  []string // 类型前缀，如 image/
+	Extensions    []string // 小写扩展名，以 . 开头
+	MinSize       *int64
+	MaxSize       *int64
+	CreatedAfter  *time.Time // 包含
+	CreatedBefore *time.Time // 不包含
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	UnderPath     string // 仅搜索该路径的文件夹下（递归），以 / 结尾
+	IsDir         *bool
+	OrderBy       string // name、size、created_at 或 updated_at
+	Desc          bool
+
+	// 媒体元数据条件，按 media_metadata 表过滤。*Pattern 为 LIKE 模式，不区分大小写
+	MediaKind     string
+	CameraPattern string // 匹配相机厂商或型号
+	ArtistPattern string
+	AlbumPattern  string
+	TakenAfter    *time.Time // 包含
+	TakenBefore   *time.Time // 不包含
+	HasLocation   *bool
+}
+
+// nameLike 返回不区分大小写的名称 LIKE 条件，写法与各数据库上的 idx_files_user_name 索引对应，
+// 前缀匹配时可以走索引
+func (r *FileRepository) nameLike() string {
+	return r.likeCI("name")
+}
+
+// likeCI 返回 column 不区分大小写的 LIKE 条件
+func (r *FileRepository) likeCI(column string) string {
+	switch r.db.Dialector.Name() {
+	case "postgres":
+		return "lower(" + column + ") LIKE lower(?)"
+	case "sqlite":
+		return column + ` LIKE ? ESCAPE '\'`
+	default:
+		// MySQL 默认以 \ 转义，且 utf8mb4 的默认排序规则不区分大小写
+		return column + " LIKE ?"
+	}
+}
+
+// mediaQuery 返回满足媒体元数据条件的对象ID子查询，没有此类条件时返回 nil
+func (r *FileRepository) mediaQuery(f FileSearch) *gorm.DB {
+	if f.MediaKind == "" && f.CameraPattern == "" && f.ArtistPattern == "" && f.AlbumPattern == "" &&
+		f.TakenAfter == nil && f.TakenBefore == nil && f.HasLocation == nil {
+		return nil
+	}
+	query := r.db.Model(&model.MediaMetadata{}).Select("blob_id").Where("error = ?", "")
+	if f.MediaKind != "" {
+		query = query.Where("kind = ?", f.MediaKind)
+	}
+	if f.CameraPattern != "" {
+		query = query.Where("("+r.likeCI("camera_make")+" OR "+r.likeCI("camera_model")+")", f.CameraPattern, f.CameraPattern)
+	}
+	if f.ArtistPattern != "" {
+		query = query.Where(r.likeCI("artist"), f.ArtistPattern)
+	}
+	if f.AlbumPattern != "" {
+		query = query.Where(r.likeCI("album"), f.AlbumPattern)
+	}
+	if f.TakenAfter != nil {
+		query = query.Where("taken_at >= ?", *f.TakenAfter)
+	}
+	if f.TakenBefore != nil {
+		query = query.Where("taken_at < ?", *f.TakenBefore)
+	}
+	if f.HasLocation != nil {
+		if *f.HasLocation {
+			query = query.Where("latitude IS NOT NULL")
+		} else {
+			query = query.Where("latitude IS NULL")
+		}
+	}
+	return query
+}
+
+// Search 按条件分页搜索用户未删除的文件和文件夹
+func (r *FileRepository) Search(userID uint, f FileSearch, offset, limit int) ([]*model.File, int64, error) {
+	query := r.db.Model(&model.File{}).Where("user_id = ?", userID)
+	if f.NamePattern != "" {
+		query = query.Where(r.nameLike(), f.NamePattern)
+	}
+	if len(f.MimeTypes) > 0 || len(f.MimePrefixes) > 0 {
+		clauses := make([]string, 0, len(f.MimePrefixes)+1)
+		args := make([]interface{}, 0, len(f.MimePrefixes)+1)
+		if len(f.MimeTypes) > 0 {
+			clauses = append(clauses, "mime_type IN ?")
+			args = append(args, f.MimeTypes)
+		}
+		for _, prefix := range f.MimePrefixes {
+			clauses = append(clauses, "mime_type LIKE ?")
+			args = append(args, prefix+"%")
+		}
+		query = query.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+	if len(f.Extensions) > 0 {
+		clauses := make([]string, len(f.Extensions))
+		args := make([]interface{}, len(f.Extensions))
+		for i, ext := range f.Extensions {
+			clauses[i] = r.nameLike()
+			args[i] = "%" + ext
+		}
+		query = query.Where("is_dir = ?", false).Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+	if f.MinSize != nil {
+		query = query.Where("size >= ?", *f.MinSize)
+	}
+	if f.MaxSize != nil {
+		query = query.Where("size <= ?", *f.MaxSize)
+	}
+	if f.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		query = query.Where("updated_at >= ?", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *f.UpdatedBefore)
+	}
+	if f.UnderPath != "" {
+		// 用 SUBSTR 比较前缀，避免路径中的 % 和 _ 被 LIKE 当作通配符
+		query = query.Where("SUBSTR(path, 1, ?) = ?", len([]rune(f.UnderPath)), f.UnderPath)
+	}
+	if f.IsDir != nil {
+		query = query.Where("is_dir = ?", *f.IsDir)
+	}
+	if media := r.mediaQuery(f); media != nil {
+		query = query.Where("is_dir = ? AND blob_id IN (?)", false, media)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	order := "updated_at"
+	switch f.OrderBy {
+	case "name", "size", "created_at":
+		order = f.OrderBy
+	}
+	if f.Desc {
+		order += " DESC, id DESC"
+	} else {
+		order += " ASC, id ASC"
+	}
+	var files []*model.File
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// searchIndexes 搜索常用的过滤与排序条件对应的索引，均以 user_id 开头
+var searchIndexes = map[string]string{
+	"idx_files_user_mime":    "user_id, mime_type",
+	"idx_files_user_size":    "user_id, size",
+	"idx_files_user_created": "user_id, created_at",
+	"idx_files_user_updated": "user_id, updated_at",
+}
+
+// EnsureSearchIndexes 创建搜索使用的索引。名称索引按数据库分别建立，使不区分大小写的前缀匹配也能使用索引
+func (r *FileRepository) EnsureSearchIndexes() error {
+	migrator := r.db.Migrator()
+	for name, columns := range searchIndexes {
+		if migrator.HasIndex(&model.File{}, name) {
+			continue
+		}
+		if err := r.db.Exec("CREATE INDEX " + name + " ON files (" + columns + ")").Error; err != nil {
+			return err
+		}
+	}
+	if migrator.HasIndex(&model.File{}, "idx_files_user_name") {
+		return nil
+	}
+	columns := "user_id, name"
+	switch r.db.Dialector.Name() {
+	case "postgres":
+		columns = "user_id, lower(name) text_pattern_ops"
+	case "sqlite":
+		columns = "user_id, name COLLATE NOCASE"
+	}
+	return r.db.Exec("CREATE INDEX idx_files_user_name ON files (" + columns + ")").Error
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// capturedAt 时间线使用的拍摄时间：媒体元数据中的拍摄时间，没有时为上传时间
+const capturedAt = "COALESCE(media_metadata.taken_at, files.created_at)"
+
+// capturedSlotMinutes 按日期统计时先按该长度的区间汇总。各时区与 UTC 的偏移都是 15 分钟的整数倍，
+// 任何时区的日期边界都与区间边界对齐
+const capturedSlotMinutes = 15
+
+// wallClockFrom 换算未记录时区的拍摄时间时，只采用此后的时区规则变化，更早的时间沿用此时的偏移
+var wallClockFrom = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// WallClock 将未记录时区的拍摄时间（以 UTC 形式保存的当地时间）按所选时区解释为时刻。
+// 数据库排序与 Instant 使用同一张偏移表，分页游标与日期统计保持一致
+type WallClock struct {
+	bounds  []time.Time // 偏移变化时的当地时间（以 UTC 形式表示），升序
+	offsets []int       // 各区间相对 UTC 的偏移秒数，比 bounds 多一个
+}
+
+// NewWallClock 按 loc 自 1970 年至一年后的时区规则建立偏移表
+func NewWallClock(loc *time.Location) *WallClock {
+	t := wallClockFrom.In(loc)
+	_, offset := t.Zone()
+	clock := &WallClock{offsets: []int{offset}}
+	until := time.Now().AddDate(1, 0, 0)
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(until) {
+			return clock
+		}
+		if _, next := end.Zone(); next != offset {
+			// 变化时刻按变化前的偏移表示为当地时间
+			clock.bounds = append(clock.bounds, end.Add(time.Duration(offset)*time.Second).UTC())
+			clock.offsets = append(clock.offsets, next)
+			offset = next
+		}
+		t = end
+	}
+}
+
+// Instant 返回当地时间 wall（以 UTC 形式表示）对应的时刻
+func (c *WallClock) Instant(wall time.Time) time.Time {
+	i := sort.Search(len(c.bounds), func(i int) bool { return c.bounds[i].After(wall) })
+	return wall.Add(-time.Duration(c.offsets[i]) * time.Second)
+}
+
+// capturedOrder 将时间表达式 expr（或参数占位符）转换为可排序、可比较的形式。
+// SQLite 以文本保存时间并保留写入时的时区（拍摄时间为 UTC，上传时间带服务器时区），
+// 直接按文本比较会出错，因此统一换算为 julianday 数值；其他数据库按时间类型比较
+func (r *FileRepository) capturedOrder(expr string) string {
+	if r.db.Dialector.Name() == "sqlite" {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// capturedKey 返回拍摄时间对应时刻的可排序表达式（经 capturedOrder 转换）及其参数。
+// 带时区的拍摄时间与上传时间本身就是时刻；未记录时区的拍摄时间按 clock 换算
+func (r *FileRepository) capturedKey(clock *WallClock) (string, []interface{}) {
+	if len(clock.bounds) == 0 && clock.offsets[0] == 0 {
+		return r.capturedOrder(capturedAt), nil
+	}
+	dialect := r.db.Dialector.Name()
+	// shift 为从当地时间减去偏移的写法：SQLite 为 julianday 的修饰符，在其内部按毫秒精确计算；其他为秒数
+	shift := func(offset int) string {
+		if dialect == "sqlite" {
+			return "'" + strconv.Itoa(-offset) + " seconds'"
+		}
+		return strconv.Itoa(offset)
+	}
+	offset := shift(clock.offsets[0])
+	var args []interface{}
+	if len(clock.bounds) > 0 {
+		var b strings.Builder
+		b.WriteString("CASE")
+		for i, bound := range clock.bounds {
+			b.WriteString(" WHEN " + r.capturedOrder("media_metadata.taken_at") + " < " + r.capturedOrder("?") + " THEN " + shift(clock.offsets[i]))
+			args = append(args, bound)
+		}
+		b.WriteString(" ELSE " + shift(clock.offsets[len(clock.bounds)]) + " END")
+		offset = b.String()
+	}
+	var local string
+	switch dialect {
+	case "sqlite":
+		local = "julianday(media_metadata.taken_at, " + offset + ")"
+	case "postgres":
+		local = "media_metadata.taken_at - (" + offset + ") * INTERVAL '1 second'"
+	default:
+		local = "media_metadata.taken_at - INTERVAL (" + offset + ") SECOND"
+	}
+	return "CASE WHEN media_metadata.taken_at_local THEN " + local + " ELSE " + r.capturedOrder(capturedAt) + " END", args
+}
+
+// capturedSlot 返回拍摄时刻所在区间起点的文本表达式（格式 2006-01-02 15:4）、参数及其所在时区。
+// SQLite 与 PostgreSQL 换算为 UTC；MySQL 的 DATETIME 不带时区，按连接参数 loc 保存
+func (r *FileRepository) capturedSlot(clock *WallClock) (string, []interface{}, *time.Location) {
+	key, keyArgs := r.capturedKey(clock)
+	args := append(append([]interface{}{}, keyArgs...), keyArgs...) // 表达式中 key 出现两次
+	step := strconv.Itoa(capturedSlotMinutes)
+	switch r.db.Dialector.Name() {
+	case "sqlite":
+		return "strftime('%Y-%m-%d %H:', " + key + ") || (CAST(strftime('%M', " + key + ") AS INTEGER) / " + step + " * " + step + ")", args, time.UTC
+	case "postgres":
+		utc := "((" + key + ") AT TIME ZONE 'UTC')"
+		return "to_char(" + utc + ", 'YYYY-MM-DD HH24:') || (EXTRACT(MINUTE FROM " + utc + ")::int / " + step + " * " + step + ")", args, time.UTC
+	default:
+		loc := time.UTC
+		if d, ok := r.db.Dialector.(*mysql.Dialector); ok && d.DSNConfig != nil && d.DSNConfig.Loc != nil {
+			loc = d.DSNConfig.Loc
+		}
+		return "CONCAT(DATE_FORMAT(" + key + ", '%Y-%m-%d %H:'), FLOOR(MINUTE(" + key + ") / " + step + ") * " + step + ")", args, loc
+	}
+}
+
+// TimelineCursor 时间线的分页位置，只返回排在它之后（拍摄时间更早，相同时 ID 更小）的文件
+type TimelineCursor struct {
+	CapturedAt time.Time
+	FileID     uint // 0 表示从早于 CapturedAt 的文件开始
+}
+
+// CapturedSlot 拍摄时间落在同一区间的文件数
+type CapturedSlot struct {
+	Start time.Time // 区间起点
+	Count int
+}
+
+// timelineQuery 返回用户未删除的照片与视频。已提取元数据的按提取到的类型判断，
+// 尚未提取或格式不支持的按 MIME 类型判断；kind 为空表示两者
+func (r *FileRepository) timelineQuery(userID uint, kind string) *gorm.DB {
+	kinds := []string{model.MediaImage, model.MediaVideo}
+	if kind != "" {
+		kinds = []string{kind}
+	}
+	mimes := make([]string, 0, 2)
+	args := make([]interface{}, 0, 3)
+	args = append(args, kinds)
+	for _, k := range kinds {
+		mimes = append(mimes, "files.mime_type LIKE ?")
+		args = append(args, k+"/%")
+	}
+	byMime := mimes[0]
+	if len(mimes) > 1 {
+		byMime = "(" + mimes[0] + " OR " + mimes[1] + ")"
+	}
+	return r.db.Model(&model.File{}).
+		Joins("LEFT JOIN media_metadata ON media_metadata.blob_id = files.blob_id").
+		Where("files.user_id = ? AND files.is_dir = ?", userID, false).
+		Where("(media_metadata.kind IN ? OR (media_metadata.kind IS NULL OR media_metadata.kind = '') AND "+byMime+")", args...)
+}
+
+// Timeline 按拍摄时间倒序列出用户的照片与视频，从 cursor 之后开始，cursor 为 nil 时从最新的开始。
+// 未记录时区的拍摄时间按 clock 换算为时刻后参与排序
+func (r *FileRepository) Timeline(userID uint, kind string, clock *WallClock, cursor *TimelineCursor, limit int) ([]*model.File, error) {
+	query := r.timelineQuery(userID, kind)
+	key, keyArgs := r.capturedKey(clock)
+	if cursor != nil {
+		param := r.capturedOrder("?")
+		args := append(append([]interface{}{}, keyArgs...), cursor.CapturedAt)
+		args = append(append(args, keyArgs...), cursor.CapturedAt, cursor.FileID)
+		query = query.Where("("+key+" < "+param+" OR "+key+" = "+param+" AND files.id < ?)", args...)
+	}
+	var files []*model.File
+	err := query.Select("files.*").
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: key + " DESC, files.id DESC", Vars: keyArgs, WithoutParentheses: true}}).
+		Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// CapturedSlots 按拍摄时刻所在的区间统计用户照片与视频的数量，由数据库汇总，不逐个读取文件。
+// 未记录时区的拍摄时间按 clock 换算为时刻
+func (r *FileRepository) CapturedSlots(userID uint, kind string, clock *WallClock) ([]CapturedSlot, error) {
+	slot, args, loc := r.capturedSlot(clock)
+	var rows []struct {
+		Slot  string
+		Count int
+	}
+	err := r.timelineQuery(userID, kind).
+		Select(slot+" AS slot, COUNT(*) AS count", args...).
+		Group("slot").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]CapturedSlot, 0, len(rows))
+	for _, row := range rows {
+		start, err := time.ParseInLocation("2006-01-02 15:4", row.Slot, loc)
+		if err != nil {
+			return nil, fmt.Errorf("parse captured time slot %q: %w", row.Slot, err)
+		}
+		slots = append(slots, CapturedSlot{Start: start, Count: row.Count})
+	}
+	return slots, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GrantRepository struct {
+	db *gorm.DB
+}
+
+func NewGrantRepository(db *gorm.DB) *GrantRepository {
+	return &GrantRepository{db: db}
+}
+
+func (r *GrantRepository) Create(grant *model.FileGrant) error {
+	return r.db.Create(grant).Error
+}
+
+func (r *GrantRepository) FindByFileAndGrantee(fileID, granteeID uint) (*model.FileGrant, error) {
+	var grant model.FileGrant
+	if err := r.db.Where("file_id = ? AND grantee_id = ?", fileID, granteeID).First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *GrantRepository) FindByIDAndFile(grantID, fileID uint) (*model.FileGrant, error) {
+	var grant model.FileGrant
+	if err := r.db.Where("id = ? AND file_id = ?", grantID, fileID).First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// FindByFile 列出文件上的全部授权
+func (r *GrantRepository) FindByFile(fileID uint) ([]*model.FileGrant, error) {
+	var grants []*model.FileGrant
+	if err := r.db.Where("file_id = ?", fileID).Order("id ASC").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// FindByGrantee 分页列出授权给用户的项，不含已在回收站中的文件
+func (r *GrantRepository) FindByGrantee(granteeID uint, offset, limit int) ([]*model.FileGrant, int64, error) {
+	var grants []*model.FileGrant
+	var total int64
+	query := r.db.Model(&model.FileGrant{}).
+		Joins("JOIN files ON files.id = file_grants.file_id AND files.deleted_at IS NULL").
+		Where("file_grants.grantee_id = ?", granteeID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Select("file_grants.*").Order("file_grants.id DESC").Offset(offset).Limit(limit).Find(&grants).Error; err != nil {
+		return nil, 0, err
+	}
+	return grants, total, nil
+}
+
+// FindFilesByGrantee 列出授权给用户的全部未删除文件和文件夹
+func (r *GrantRepository) FindFilesByGrantee(granteeID uint) ([]*model.File, error) {
+	var files []*model.File
+	err := r.db.Model(&model.File{}).
+		Joins("JOIN file_grants ON file_grants.file_id = files.id").
+		Where("file_grants.grantee_id = ?", granteeID).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindByGranteeAndFiles 查找用户在这些文件上的授权
+func (r *GrantRepository) FindByGranteeAndFiles(granteeID uint, fileIDs []uint) ([]*model.FileGrant, error) {
+	var grants []*model.FileGrant
+	if len(fileIDs) == 0 {
+		return grants, nil
+	}
+	if err := r.db.Where("grantee_id = ? AND file_id IN ?", granteeID, fileIDs).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// ExistsForOwner 判断 ownerID 是否向 granteeID 授权过任何文件
+func (r *GrantRepository) ExistsForOwner(ownerID, granteeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.FileGrant{}).Where("owner_id = ? AND grantee_id = ?", ownerID, granteeID).Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateRole 修改授权角色
+func (r *GrantRepository) UpdateRole(grantID uint, role string) error {
+	return r.db.Model(&model.FileGrant{}).Where("id = ?", grantID).Update("role", role).Error
+}
+
+func (r *GrantRepository) Delete(grantID uint) error {
+	return r.db.Delete(&model.FileGrant{}, grantID).Error
+}
+
+// DeleteByFileIDs 删除这些文件上的全部授权
+func (r *GrantRepository) DeleteByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("file_id IN ?", fileIDs).Delete(&model.FileGrant{}).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ImageVariantRepository struct {
+	db *gorm.DB
+}
+
+func NewImageVariantRepository(db *gorm.DB) *ImageVariantRepository {
+	return &ImageVariantRepository{db: db}
+}
+
+// Create 保存变换结果，并发生成的相同变换保留已有记录
+func (r *ImageVariantRepository) Create(variant *model.ImageVariant) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(variant).Error
+}
+
+func (r *ImageVariantRepository) FindByBlobAndDigest(blobID uint, digest string) (*model.ImageVariant, error) {
+	var variant model.ImageVariant
+	if err := r.db.Where("blob_id = ? AND digest = ?", blobID, digest).First(&variant).Error; err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Touch 更新最近使用时间
+func (r *ImageVariantRepository) Touch(variant *model.ImageVariant, at time.Time) error {
+	return r.db.Model(&model.ImageVariant{}).
+		Where("blob_id = ? AND digest = ?", variant.BlobID, variant.Digest).
+		Update("accessed_at", at).Error
+}
+
+// FindByBlobs 查找这些对象的全部变换结果
+func (r *ImageVariantRepository) FindByBlobs(blobIDs []uint) ([]*model.ImageVariant, error) {
+	var variants []*model.ImageVariant
+	if len(blobIDs) == 0 {
+		return variants, nil
+	}
+	if err := r.db.Where("blob_id IN ?", blobIDs).Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// FindOrphans 查找对象已不存在的变换结果
+func (r *ImageVariantRepository) FindOrphans(limit int) ([]*model.ImageVariant, error) {
+	var variants []*model.ImageVariant
+	err := r.db.Where("blob_id NOT IN (?)", r.db.Model(&model.Blob{}).Select("id")).
+		Limit(limit).Find(&variants).Error
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// FindLeastRecent 按最近使用时间从早到晚查找变换结果，用于淘汰
+func (r *ImageVariantRepository) FindLeastRecent(limit int) ([]*model.ImageVariant, error) {
+	var variants []*model.ImageVariant
+	if err := r.db.Order("accessed_at ASC").Limit(limit).Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// TotalBytes 缓存的变换结果占用的总字节数
+func (r *ImageVariantRepository) TotalBytes() (int64, error) {
+	var total int64
+	err := r.db.Model(&model.ImageVariant{}).Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
+
+// Delete 删除一条变换结果记录
+func (r *ImageVariantRepository) Delete(variant *model.ImageVariant) error {
+	return r.db.Where("blob_id = ? AND digest = ?", variant.BlobID, variant.Digest).Delete(&model.ImageVariant{}).Error
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func (r *JobRepository) Create(job *model.Job) error {
+	return r.db.Create(job).Error
+}
+
+func (r *JobRepository) FindByIDAndUser(jobID, userID uint) (*model.Job, error) {
+	var job model.Job
+	if err := r.db.Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateFields 更新任务的指定字段
+func (r *JobRepository) UpdateFields(jobID uint, fields map[string]interface{}) error {
+	return r.db.Model(&model.Job{}).Where("id = ?", jobID).Updates(fields).Error
+}
+
+// FailUnfinished 将所有未结束的任务标记为失败，返回受影响的数量
+func (r *JobRepository) FailUnfinished(reason string) (int64, error) {
+	res := r.db.Model(&model.Job{}).
+		Where("status IN ?", []string{model.JobStatusPending, model.JobStatusRunning}).
+		Updates(map[string]interface{}{"status": model.JobStatusFailed, "error": reason})
+	return res.RowsAffected, res.Error
+}
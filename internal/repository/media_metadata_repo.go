@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type MediaMetadataRepository struct {
+	db *gorm.DB
+}
+
+func NewMediaMetadataRepository(db *gorm.DB) *MediaMetadataRepository {
+	return &MediaMetadataRepository{db: db}
+}
+
+// FindPendingBlobs 按ID顺序查找 afterID 之后被未删除的文件引用、但尚未提取元数据的对象，
+// 以及升级前提取、未区分拍摄时间是否带时区的对象
+func (r *MediaMetadataRepository) FindPendingBlobs(afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.File{}).
+		Distinct("files.blob_id").
+		Joins("LEFT JOIN media_metadata ON media_metadata.blob_id = files.blob_id").
+		Where("files.blob_id > ? AND files.is_dir = ?", afterID, false).
+		Where("media_metadata.blob_id IS NULL OR media_metadata.taken_at IS NOT NULL AND media_metadata.taken_at_local IS NULL").
+		Order("files.blob_id ASC").
+		Limit(limit).
+		Pluck("files.blob_id", &ids).Error
+	return ids, err
+}
+
+// Save 保存对象的元数据。对象已被删除时不保存，返回 false
+func (r *MediaMetadataRepository) Save(meta *model.MediaMetadata) (bool, error) {
+	saved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var blobs int64
+		if err := tx.Model(&model.Blob{}).Where("id = ?", meta.BlobID).Count(&blobs).Error; err != nil || blobs == 0 {
+			return err
+		}
+		if err := tx.Where("blob_id = ?", meta.BlobID).Delete(&model.MediaMetadata{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(meta).Error; err != nil {
+			return err
+		}
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+func (r *MediaMetadataRepository) FindByBlob(blobID uint) (*model.MediaMetadata, error) {
+	var meta model.MediaMetadata
+	if err := r.db.Where("blob_id = ?", blobID).First(&meta).Error; err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// FindByBlobs 批量查找这些对象的元数据
+func (r *MediaMetadataRepository) FindByBlobs(blobIDs []uint) ([]*model.MediaMetadata, error) {
+	var metas []*model.MediaMetadata
+	if len(blobIDs) == 0 {
+		return metas, nil
+	}
+	if err := r.db.Where("blob_id IN ?", blobIDs).Find(&metas).Error; err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+// DeleteByBlob 删除对象的元数据
+func (r *MediaMetadataRepository) DeleteByBlob(blobID uint) error {
+	return r.db.Where("blob_id = ?", blobID).Delete(&model.MediaMetadata{}).Error
+}
+
+// DeleteOrphans 删除对象已不存在的元数据，返回删除的条数
+func (r *MediaMetadataRepository) DeleteOrphans() (int64, error) {
+	res := r.db.Where("blob_id NOT IN (?)", r.db.Model(&model.Blob{}).Select("id")).Delete(&model.MediaMetadata{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ShareRepository struct {
+	db *gorm.DB
+}
+
+func NewShareRepository(db *gorm.DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+func (r *ShareRepository) Create(share *model.Share) error {
+	return r.db.Create(share).Error
+}
+
+func (r *ShareRepository) FindByToken(token string) (*model.Share, error) {
+	var share model.Share
+	if err := r.db.Where("token = ?", token).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *ShareRepository) FindByIDAndUser(shareID, userID uint) (*model.Share, error) {
+	var share model.Share
+	if err := r.db.Where("id = ? AND user_id = ?", shareID, userID).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// FindByUser 分页列出用户创建的分享，按创建时间倒序
+func (r *ShareRepository) FindByUser(userID uint, offset, limit int) ([]*model.Share, int64, error) {
+	var shares []*model.Share
+	var total int64
+	query := r.db.Model(&model.Share{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&shares).Error; err != nil {
+		return nil, 0, err
+	}
+	return shares, total, nil
+}
+
+func (r *ShareRepository) Delete(shareID uint) error {
+	return r.db.Delete(&model.Share{}, shareID).Error
+}
+
+// DeleteByFileIDs 删除指向这些文件的全部分享
+func (r *ShareRepository) DeleteByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("file_id IN ?", fileIDs).Delete(&model.Share{}).Error
+}
+
+// CountByFile 统计指向某文件的分享数
+func (r *ShareRepository) CountByFile(fileID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Share{}).Where("file_id = ?", fileID).Count(&count).Error
+	return count, err
+}
+
+// IncrementDownloads 下载次数加一；已达到上限时不更新并返回 false。
+// 条件更新保证并发下载不会超出上限
+func (r *ShareRepository) IncrementDownloads(shareID uint) (bool, error) {
+	res := r.db.Model(&model.Share{}).
+		Where("id = ? AND (max_downloads = 0 OR download_count < max_downloads)", shareID).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1"))
+	return res.RowsAffected > 0, res.Error
+}
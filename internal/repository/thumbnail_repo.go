@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ThumbnailRepository struct {
+	db *gorm.DB
+}
+
+func NewThumbnailRepository(db *gorm.DB) *ThumbnailRepository {
+	return &ThumbnailRepository{db: db}
+}
+
+// CreateAll 保存一个对象的各尺寸缩略图，已存在的尺寸（并发生成）保留原记录
+func (r *ThumbnailRepository) CreateAll(thumbs []*model.Thumbnail) error {
+	if len(thumbs) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(thumbs).Error
+}
+
+func (r *ThumbnailRepository) FindByBlobAndSize(blobID uint, size string) (*model.Thumbnail, error) {
+	var thumb model.Thumbnail
+	if err := r.db.Where("blob_id = ? AND size = ?", blobID, size).First(&thumb).Error; err != nil {
+		return nil, err
+	}
+	return &thumb, nil
+}
+
+// FindByBlobs 查找这些对象的全部缩略图
+func (r *ThumbnailRepository) FindByBlobs(blobIDs []uint) ([]*model.Thumbnail, error) {
+	var thumbs []*model.Thumbnail
+	if len(blobIDs) == 0 {
+		return thumbs, nil
+	}
+	if err := r.db.Where("blob_id IN ?", blobIDs).Find(&thumbs).Error; err != nil {
+		return nil, err
+	}
+	return thumbs, nil
+}
+
+// FindOrphans 查找对象已不存在的缩略图
+func (r *ThumbnailRepository) FindOrphans(limit int) ([]*model.Thumbnail, error) {
+	var thumbs []*model.Thumbnail
+	err := r.db.Where("blob_id NOT IN (?)", r.db.Model(&model.Blob{}).Select("id")).
+		Limit(limit).Find(&thumbs).Error
+	if err != nil {
+		return nil, err
+	}
+	return thumbs, nil
+}
+
+// Delete 删除一条缩略图记录
+func (r *ThumbnailRepository) Delete(thumb *model.Thumbnail) error {
+	return r.db.Where("blob_id = ? AND size = ?", thumb.BlobID, thumb.Size).Delete(&model.Thumbnail{}).Error
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+func (r *UploadRepository) CreateSession(session *model.UploadSession) error {
+	return r.db.Create(session).Error
+}
+
+func (r *UploadRepository) UpdateSession(session *model.UploadSession) error {
+	return r.db.Save(session).Error
+}
+
+func (r *UploadRepository) FindSession(sessionID string, userID uint) (*model.UploadSession, error) {
+	var session model.UploadSession
+	if err := r.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindExpiredSessions 查找已过期的会话
+func (r *UploadRepository) FindExpiredSessions(now time.Time, limit int) ([]*model.UploadSession, error) {
+	var sessions []*model.UploadSession
+	if err := r.db.Where("expires_at < ?", now).Limit(limit).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession 删除会话及其分片记录
+func (r *UploadRepository) DeleteSession(sessionID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", sessionID).Delete(&model.UploadChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", sessionID).Delete(&model.UploadSession{}).Error
+	})
+}
+
+// SaveChunk 记录分片，同一分片重复上传时覆盖原记录
+func (r *UploadRepository) SaveChunk(chunk *model.UploadChunk) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}, {Name: "chunk_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"offset", "size", "hash", "created_at"}),
+	}).Create(chunk).Error
+}
+
+func (r *UploadRepository) FindChunks(sessionID string) ([]*model.UploadChunk, error) {
+	var chunks []*model.UploadChunk
+	if err := r.db.Where("session_id = ?", sessionID).Order("chunk_index ASC").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// DeleteChunks 删除会话的全部分片记录
+func (r *UploadRepository) DeleteChunks(sessionID string) error {
+	return r.db.Where("session_id = ?", sessionID).Delete(&model.UploadChunk{}).Error
+}
+
+func (r *UploadRepository) CreateChallenge(challenge *model.InstantChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+// TakeChallenge 取出并删除挑战，保证每个挑战只能使用一次
+func (r *UploadRepository) TakeChallenge(challengeID string, userID uint) (*model.InstantChallenge, error) {
+	var challenge model.InstantChallenge
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", challengeID, userID).First(&challenge).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", challengeID).Delete(&model.InstantChallenge{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// DeleteExpiredChallenges 删除过期的秒传挑战
+func (r *UploadRepository) DeleteExpiredChallenges(now time.Time) error {
+	return r.db.Where("expires_at < ?", now).Delete(&model.InstantChallenge{}).Error
+}
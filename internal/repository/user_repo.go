@@ -33,3 +33,46 @@ func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	}
 	return &u, nil
 }
+
+func (r *UserRepository) FindByIDs(ids []uint) ([]*model.User, error) {
+	var users []*model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepository) FindByID(userID uint) (*model.User, error) {
+	var u model.User
+	if err := r.db.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// AddUsage 原子地调整用户已用空间，delta 为负数时表示释放
+func (r *UserRepository) AddUsage(userID uint, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	return r.db.Model(&model.User{}).Where("id = ?", userID).
+		UpdateColumn("used_bytes", gorm.Expr("used_bytes + ?", delta)).Error
+}
+
+// ReserveUsage 仅在增加后不超过 quota 时原子地增加已用空间，空间不足时返回 false
+func (r *UserRepository) ReserveUsage(userID uint, delta, quota int64) (bool, error) {
+	res := r.db.Model(&model.User{}).Where("id = ? AND used_bytes + ? <= ?", userID, delta, quota).
+		UpdateColumn("used_bytes", gorm.Expr("used_bytes + ?", delta))
+	return res.RowsAffected > 0, res.Error
+}
+
+// RecalculateUsage 按文件（含回收站）、历史版本与上传会话中仍持有的预留重新统计所有用户的已用空间
+func (r *UserRepository) RecalculateUsage() error {
+	return r.db.Model(&model.User{}).Where("1 = 1").UpdateColumn("used_bytes", gorm.Expr(
+		"(SELECT COALESCE(SUM(size), 0) FROM files WHERE files.user_id = users.id AND files.is_dir = ?)"+
+			" + (SELECT COALESCE(SUM(size), 0) FROM file_versions WHERE file_versions.user_id = users.id)"+
+			" + (SELECT COALESCE(SUM(reserved_bytes), 0) FROM upload_sessions WHERE upload_sessions.quota_user_id = users.id)", false)).Error
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type VersionRepository struct {
+	db *gorm.DB
+}
+
+func NewVersionRepository(db *gorm.DB) *VersionRepository {
+	return &VersionRepository{db: db}
+}
+
+func (r *VersionRepository) Create(version *model.FileVersion) error {
+	return r.db.Create(version).Error
+}
+
+// FindByFileIDs 查找多个文件的全部历史版本
+func (r *VersionRepository) FindByFileIDs(fileIDs []uint) ([]*model.FileVersion, error) {
+	var versions []*model.FileVersion
+	if len(fileIDs) == 0 {
+		return versions, nil
+	}
+	if err := r.db.Where("file_id IN ?", fileIDs).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// DeleteByFileIDs 删除多个文件的全部历史版本记录
+func (r *VersionRepository) DeleteByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("file_id IN ?", fileIDs).Delete(&model.FileVersion{}).Error
+}
+
+// Reassign 将一个文件的历史版本转移给另一个文件
+func (r *VersionRepository) Reassign(fromFileID, toFileID uint) error {
+	return r.db.Model(&model.FileVersion{}).Where("file_id = ?", fromFileID).Update("file_id", toFileID).Error
+}
+
+// CountByStoragePath 统计引用同一存储对象的历史版本数
+func (r *VersionRepository) CountByStoragePath(storagePath string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.FileVersion{}).Where("storage_path = ?", storagePath).Count(&count).Error
+	return count, err
+}
+
+// FindByFile 列出文件的历史版本，最新的在前
+func (r *VersionRepository) FindByFile(fileID uint) ([]*model.FileVersion, error) {
+	var versions []*model.FileVersion
+	if err := r.db.Where("file_id = ?", fileID).Order("created_at DESC, id DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *VersionRepository) FindByIDAndFile(versionID, fileID uint) (*model.FileVersion, error) {
+	var version model.FileVersion
+	if err := r.db.Where("id = ? AND file_id = ?", versionID, fileID).First(&version).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// DeleteByIDs 删除多条历史版本记录
+func (r *VersionRepository) DeleteByIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Where("id IN ?", ids).Delete(&model.FileVersion{}).Error
+}
+
+// FindExpired 查找用户早于 before 的历史版本
+func (r *VersionRepository) FindExpired(userID uint, before time.Time, limit int) ([]*model.FileVersion, error) {
+	var versions []*model.FileVersion
+	if err := r.db.Where("user_id = ? AND created_at < ?", userID, before).Limit(limit).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// FindUserIDs 列出拥有历史版本的用户
+func (r *VersionRepository) FindUserIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.FileVersion{}).Distinct("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// FindPolicy 查找用户的版本保留策略
+func (r *VersionRepository) FindPolicy(userID uint) (*model.VersionPolicy, error) {
+	var policy model.VersionPolicy
+	if err := r.db.Where("user_id = ?", userID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SavePolicy 创建或更新用户的版本保留策略
+func (r *VersionRepository) SavePolicy(policy *model.VersionPolicy) error {
+	return r.db.Save(policy).Error
+}
+
+// FindFileIDsOverLimit 查找用户历史版本数超过 limit 的文件
+func (r *VersionRepository) FindFileIDsOverLimit(userID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.FileVersion{}).
+		Where("user_id = ?", userID).
+		Group("file_id").
+		Having("COUNT(*) > ?", limit).
+		Pluck("file_id", &ids).Error
+	return ids, err
+}
+
+// SumByUser 统计用户全部历史版本
+func (r *VersionRepository) SumByUser(userID uint) (SizeSum, error) {
+	var sum SizeSum
+	err := r.db.Model(&model.FileVersion{}).
+		Select(sizeSumColumns).
+		Where("user_id = ?", userID).
+		Scan(&sum).Error
+	return sum, err
+}
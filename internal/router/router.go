@@ -1,8 +1,10 @@
 package router
 
 import (
+	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"online-disk-server/internal/auth"
 	"online-disk-server/internal/config"
@@ -30,7 +32,7 @@ func Register(r *gin.Engine) {
 	cfg := config.LoadFromEnv()
 	db, err := database.Init(cfg)
 	if err == nil {
-		_ = db.AutoMigrate(&model.User{}, &model.File{})
+		_ = db.AutoMigrate(&model.User{}, &model.File{}, &model.UploadSession{}, &model.UploadChunk{}, &model.InstantChallenge{}, &model.Blob{}, &model.Job{}, &model.FileVersion{}, &model.VersionPolicy{}, &model.Share{}, &model.FileGrant{}, &model.FileRequest{}, &model.FileRequestUpload{}, &model.ContentIndex{}, &model.ContentTerm{}, &model.ContentChunk{}, &model.Thumbnail{}, &model.ImageVariant{}, &model.MediaMetadata{}, &model.Album{}, &model.AlbumItem{})
 	}
 
 	// Init storage
@@ -54,7 +56,136 @@ func Register(r *gin.Engine) {
 
 	// File service and handler
 	fileService := service.NewFileService(db, stor)
+	if policy, err := service.ParseConflictPolicy(cfg.ConflictPolicy, service.ConflictFail); err == nil {
+		fileService.SetConflictPolicy(policy)
+	}
+	if db != nil {
+		if err := fileService.EnsureNameIndex(); err != nil {
+			log.Printf("create unique file name index failed: %v", err)
+		}
+		if err := fileService.EnsureFolderStats(); err != nil {
+			log.Printf("rebuild folder statistics failed: %v", err)
+		}
+		if err := fileService.EnsureSearchIndexes(); err != nil {
+			log.Printf("create search indexes failed: %v", err)
+		}
+	}
 	fileHandler := handler.NewFileHandler(fileService)
+	searchHandler := handler.NewSearchHandler(fileService)
+
+	// Storage quota and usage accounting
+	quotaMB, _ := strconv.ParseInt(cfg.DefaultQuotaMB, 10, 64)
+	fileService.SetDefaultQuota(max(quotaMB, 0) << 20)
+	if db != nil {
+		if err := fileService.RecalculateUsage(); err != nil {
+			log.Printf("recalculate storage usage failed: %v", err)
+		}
+	}
+	usageHandler := handler.NewUsageHandler(fileService)
+
+	// Full-text content index
+	indexFileMB, _ := strconv.ParseInt(cfg.ContentIndexMaxFileMB, 10, 64)
+	if indexFileMB <= 0 {
+		indexFileMB = 50
+	}
+	indexTextKB, _ := strconv.Atoi(cfg.ContentIndexMaxTextKB)
+	if indexTextKB <= 0 {
+		indexTextKB = 1024
+	}
+	fileService.SetContentIndexLimits(service.ContentIndexLimits{
+		MaxFileBytes: indexFileMB << 20,
+		MaxTextBytes: indexTextKB << 10,
+	})
+	if db != nil {
+		fileService.StartContentIndexer(time.Minute)
+	}
+
+	// Media metadata (EXIF, audio/video tags, PDF info)
+	if db != nil {
+		fileService.StartMetadataExtractor(time.Minute)
+	}
+
+	// Image thumbnails
+	if db != nil {
+		fileService.StartThumbnailWorker()
+	}
+	thumbnailHandler := handler.NewThumbnailHandler(fileService)
+
+	// On-the-fly image transform
+	imageMaxDimension, _ := strconv.Atoi(cfg.ImageMaxDimension)
+	if imageMaxDimension <= 0 {
+		imageMaxDimension = 4096
+	}
+	imageCacheMB, _ := strconv.ParseInt(cfg.ImageCacheMaxMB, 10, 64)
+	if imageCacheMB <= 0 {
+		imageCacheMB = 1024
+	}
+	fileService.SetImageLimits(service.ImageLimits{
+		MaxDimension:  imageMaxDimension,
+		MaxCacheBytes: imageCacheMB << 20,
+	})
+	imageTransformHandler := handler.NewImageTransformHandler(fileService)
+
+	// Photo timeline and albums
+	timelineHandler := handler.NewTimelineHandler(fileService)
+	albumHandler := handler.NewAlbumHandler(service.NewAlbumService(db, fileService))
+
+	// File version history
+	maxVersions, _ := strconv.Atoi(cfg.VersionMaxCount)
+	maxVersionAge, _ := strconv.Atoi(cfg.VersionMaxAgeDays)
+	fileService.SetVersionDefaults(max(maxVersions, 0), max(maxVersionAge, 0))
+	fileService.StartVersionPrune(time.Hour)
+	versionHandler := handler.NewVersionHandler(fileService)
+
+	// Chunked upload service and handler
+	uploadTTL, _ := strconv.Atoi(cfg.UploadSessionTTLHours)
+	if uploadTTL <= 0 {
+		uploadTTL = 24
+	}
+	uploadService := service.NewUploadService(db, stor, fileService, time.Duration(uploadTTL)*time.Hour)
+	uploadService.StartCleanup(time.Hour)
+	uploadHandler := handler.NewUploadHandler(uploadService, fileService)
+	tusHandler := handler.NewTusHandler(uploadService, fileService, "/v1/tus")
+
+	// Background jobs and server-side copy
+	jobService := service.NewJobService(db)
+	jobService.FailInterrupted()
+	jobHandler := handler.NewJobHandler(jobService)
+	copyHandler := handler.NewCopyHandler(service.NewCopyService(fileService, jobService), fileService)
+
+	// Server-side archive extraction
+	extractEntries, _ := strconv.Atoi(cfg.ExtractMaxEntries)
+	if extractEntries <= 0 {
+		extractEntries = 10000
+	}
+	extractSizeMB, _ := strconv.ParseInt(cfg.ExtractMaxSizeMB, 10, 64)
+	if extractSizeMB <= 0 {
+		extractSizeMB = 10240
+	}
+	extractService := service.NewExtractService(fileService, jobService, service.ExtractLimits{
+		MaxEntries: extractEntries,
+		MaxBytes:   extractSizeMB << 20,
+	})
+	extractHandler := handler.NewExtractHandler(extractService, fileService)
+	archiveHandler := handler.NewArchiveHandler(fileService)
+
+	// Per-user grants
+	grantHandler := handler.NewGrantHandler(fileService)
+
+	// Share links
+	shareHandler := handler.NewShareHandler(service.NewShareService(db, fileService), fileService, jwtm)
+
+	// Upload-only file request links
+	fileRequestHandler := handler.NewFileRequestHandler(service.NewFileRequestService(db, fileService))
+
+	// Recycle bin
+	retentionDays, _ := strconv.Atoi(cfg.TrashRetentionDays)
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+	trashService := service.NewTrashService(db, fileService, time.Duration(retentionDays)*24*time.Hour)
+	trashService.StartPurge(time.Hour)
+	trashHandler := handler.NewTrashHandler(trashService)
 
 	// v1 api
 	v1 := r.Group("/v1")
@@ -63,19 +194,114 @@ func Register(r *gin.Engine) {
 		v1.POST("/auth/register", authHandler.Register)
 		v1.POST("/auth/login", authHandler.Login)
 
+		// tus capability discovery (preflight must not require auth)
+		v1.OPTIONS("/tus", tusHandler.Options)
+		v1.OPTIONS("/tus/:id", tusHandler.Options)
+
+		// share links (public, access controlled by token and optional password)
+		v1.GET("/public/shares/:token", shareHandler.PublicInfo)
+		v1.POST("/public/shares/:token/unlock", shareHandler.PublicUnlock)
+		v1.GET("/public/shares/:token/files", shareHandler.PublicList)
+		v1.GET("/public/shares/:token/download", shareHandler.PublicDownload)
+		v1.HEAD("/public/shares/:token/download", shareHandler.PublicDownload)
+		v1.POST("/public/shares/:token/upload", shareHandler.PublicUpload)
+
+		// file request links (public, upload only)
+		v1.GET("/public/file-requests/:token", fileRequestHandler.PublicInfo)
+		v1.POST("/public/file-requests/:token/upload", fileRequestHandler.PublicUpload)
+
 		// protected routes
 		v1auth := v1.Group("")
 		v1auth.Use(middleware.AuthRequired(jwtm))
 		{
 			v1auth.GET("/me", authHandler.Me)
+			v1auth.GET("/me/version-policy", versionHandler.GetPolicy)
+			v1auth.PUT("/me/version-policy", versionHandler.UpdatePolicy)
+			v1auth.GET("/me/usage", usageHandler.Get)
 
 			// file management
 			v1auth.POST("/files/upload", fileHandler.Upload)
 			v1auth.POST("/files/batch-upload", fileHandler.BatchUpload)
+			v1auth.POST("/files/instant/check", uploadHandler.InstantCheck)
+			v1auth.POST("/files/instant", uploadHandler.InstantUpload)
+			v1auth.POST("/files/archive", fileHandler.Archive)
 			v1auth.GET("/files", fileHandler.List)
+			v1auth.GET("/search", searchHandler.Search)
+			v1auth.GET("/search/content", searchHandler.Content)
 			v1auth.GET("/files/:id", fileHandler.GetInfo)
 			v1auth.GET("/files/:id/download", fileHandler.Download)
+			v1auth.HEAD("/files/:id/download", fileHandler.Download)
+			v1auth.GET("/files/:id/thumbnail", thumbnailHandler.Get)
+			v1auth.HEAD("/files/:id/thumbnail", thumbnailHandler.Get)
+			v1auth.GET("/files/:id/transform", imageTransformHandler.Transform)
+			v1auth.HEAD("/files/:id/transform", imageTransformHandler.Transform)
+			v1auth.PATCH("/files/:id", fileHandler.Update)
 			v1auth.DELETE("/files/:id", fileHandler.Delete)
+			v1auth.POST("/files/:id/copy", copyHandler.Copy)
+			v1auth.POST("/files/:id/extract", extractHandler.Extract)
+			v1auth.GET("/files/:id/archive/entries", archiveHandler.Entries)
+			v1auth.GET("/files/:id/archive/entry", archiveHandler.Entry)
+			v1auth.HEAD("/files/:id/archive/entry", archiveHandler.Entry)
+
+			// file version history
+			v1auth.GET("/files/:id/versions", versionHandler.List)
+			v1auth.GET("/files/:id/versions/:vid/download", versionHandler.Download)
+			v1auth.HEAD("/files/:id/versions/:vid/download", versionHandler.Download)
+			v1auth.POST("/files/:id/versions/:vid/restore", versionHandler.Restore)
+			v1auth.DELETE("/files/:id/versions/:vid", versionHandler.Delete)
+			v1auth.DELETE("/files/:id/versions", versionHandler.DeleteAll)
+
+			// background jobs
+			v1auth.GET("/jobs/:id", jobHandler.Get)
+
+			// per-user grants
+			v1auth.GET("/files/:id/grants", grantHandler.List)
+			v1auth.POST("/files/:id/grants", grantHandler.Grant)
+			v1auth.DELETE("/files/:id/grants/:gid", grantHandler.Revoke)
+			v1auth.GET("/shared-with-me", grantHandler.SharedWithMe)
+
+			// share links
+			v1auth.POST("/shares", shareHandler.Create)
+			v1auth.GET("/shares", shareHandler.List)
+			v1auth.DELETE("/shares/:id", shareHandler.Revoke)
+
+			// file request links
+			v1auth.POST("/file-requests", fileRequestHandler.Create)
+			v1auth.GET("/file-requests", fileRequestHandler.List)
+			v1auth.GET("/file-requests/:id/uploads", fileRequestHandler.Uploads)
+			v1auth.DELETE("/file-requests/:id", fileRequestHandler.Delete)
+
+			// photo timeline and albums
+			v1auth.GET("/timeline", timelineHandler.List)
+			v1auth.GET("/timeline/buckets", timelineHandler.Buckets)
+			v1auth.POST("/albums", albumHandler.Create)
+			v1auth.GET("/albums", albumHandler.List)
+			v1auth.GET("/albums/:id", albumHandler.Get)
+			v1auth.PATCH("/albums/:id", albumHandler.Update)
+			v1auth.DELETE("/albums/:id", albumHandler.Delete)
+			v1auth.GET("/albums/:id/files", albumHandler.Files)
+			v1auth.POST("/albums/:id/files", albumHandler.AddFiles)
+			v1auth.DELETE("/albums/:id/files/:file_id", albumHandler.RemoveFile)
+
+			// recycle bin
+			v1auth.GET("/trash", trashHandler.List)
+			v1auth.POST("/trash/:id/restore", trashHandler.Restore)
+			v1auth.DELETE("/trash/:id", trashHandler.Delete)
+			v1auth.DELETE("/trash", trashHandler.Empty)
+
+			// chunked (resumable) upload
+			v1auth.POST("/uploads", uploadHandler.Init)
+			v1auth.GET("/uploads/:id", uploadHandler.Status)
+			v1auth.PUT("/uploads/:id/chunks/:index", uploadHandler.PutChunk)
+			v1auth.POST("/uploads/:id/complete", uploadHandler.Complete)
+			v1auth.DELETE("/uploads/:id", uploadHandler.Abort)
+
+			// tus 1.0 resumable upload
+			v1auth.POST("/tus", tusHandler.Create)
+			v1auth.HEAD("/tus/:id", tusHandler.Head)
+			v1auth.PATCH("/tus/:id", tusHandler.Patch)
+			v1auth.DELETE("/tus/:id", tusHandler.Delete)
+			v1auth.POST("/tus/:id", tusHandler.Override)
 
 			// folder management
 			v1auth.POST("/folders", fileHandler.CreateFolder)
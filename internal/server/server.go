@@ -49,10 +49,19 @@ func requestLogger() gin.HandlerFunc {
 func cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Content-MD5, "+
+			"Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Checksum, Upload-Defer-Length, X-HTTP-Method-Override, "+
+			"Range, If-Range, If-None-Match, If-Modified-Since, X-Share-Access, X-Share-Download")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, "+
+			"Tus-Checksum-Algorithm, Upload-Offset, Upload-Length, Upload-Expires, Upload-File-Id, "+
+			"Accept-Ranges, Content-Range, Content-Length, Content-Disposition, ETag, Last-Modified, X-Share-Download")
 		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
+			// 允许路由自行响应 OPTIONS（如 tus 能力发现），否则直接返回 204
+			c.Next()
+			if !c.Writer.Written() {
+				c.AbortWithStatus(http.StatusNoContent)
+			}
 			return
 		}
 		c.Next()
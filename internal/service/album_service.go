@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// maxAlbumBatch 一次最多加入相册的文件数
+const maxAlbumBatch = 500
+
+var ErrInvalidAlbum = errors.New("invalid album")
+
+// AlbumService 管理用户自建的相册。相册中的文件只是引用，加入或移出相册不会移动或删除文件
+type AlbumService struct {
+	db        *gorm.DB
+	albumRepo *repository.AlbumRepository
+	files     *FileService
+}
+
+func NewAlbumService(db *gorm.DB, files *FileService) *AlbumService {
+	return &AlbumService{
+		db:        db,
+		albumRepo: repository.NewAlbumRepository(db),
+		files:     files,
+	}
+}
+
+// AlbumUpdate 修改相册时提供的字段，nil 表示不修改
+type AlbumUpdate struct {
+	Name        *string
+	Description *string
+	CoverFileID *uint // 0 表示恢复为默认封面
+}
+
+// albumText 校验并整理相册名称与说明
+func albumText(name, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+	switch {
+	case name == "":
+		return "", "", fmt.Errorf("%w: name is required", ErrInvalidAlbum)
+	case utf8.RuneCountInString(name) > 255 || utf8.RuneCountInString(description) > 1024:
+		return "", "", fmt.Errorf("%w: name or description too long", ErrInvalidAlbum)
+	}
+	return name, description, nil
+}
+
+// Create 创建空相册
+func (s *AlbumService) Create(userID uint, name, description string) (*model.Album, error) {
+	name, description, err := albumText(name, description)
+	if err != nil {
+		return nil, err
+	}
+	album := &model.Album{UserID: userID, Name: name, Description: description}
+	if err := s.albumRepo.Create(album); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// List 分页列出用户的相册
+func (s *AlbumService) List(userID uint, page, limit int) ([]*model.Album, int64, error) {
+	offset := (page - 1) * limit
+	albums, total, err := s.albumRepo.FindByUser(userID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.albumRepo.FillStats(albums); err != nil {
+		return nil, 0, err
+	}
+	return albums, total, nil
+}
+
+// Get 获取用户的相册
+func (s *AlbumService) Get(userID, albumID uint) (*model.Album, error) {
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.albumRepo.FillStats([]*model.Album{album}); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// Update 修改相册名称、说明或封面。封面必须是相册中的文件
+func (s *AlbumService) Update(userID, albumID uint, update AlbumUpdate) (*model.Album, error) {
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return nil, err
+	}
+	name, description := album.Name, album.Description
+	if update.Name != nil {
+		name = *update.Name
+	}
+	if update.Description != nil {
+		description = *update.Description
+	}
+	if name, description, err = albumText(name, description); err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{"name": name, "description": description}
+	if update.CoverFileID != nil {
+		if *update.CoverFileID != 0 {
+			ok, err := s.albumRepo.HasFile(album.ID, *update.CoverFileID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: cover must be a file in the album", ErrInvalidAlbum)
+			}
+		}
+		fields["cover_file_id"] = *update.CoverFileID
+	}
+	if err := s.albumRepo.UpdateFields(album.ID, fields); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, album.ID)
+}
+
+// Delete 删除相册，其中的文件保留
+func (s *AlbumService) Delete(userID, albumID uint) error {
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return err
+	}
+	return s.albumRepo.Delete(album.ID)
+}
+
+// ListFiles 分页列出相册中的文件（不含回收站中的），附带已提取的媒体元数据
+func (s *AlbumService) ListFiles(userID, albumID uint, page, limit int) ([]*model.File, int64, error) {
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * limit
+	files, total, err := s.albumRepo.FindFiles(album.ID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.files.attachMetadata(files); err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// AddFiles 将用户自己的文件加入相册，返回新加入的数量（已在相册中的不重复加入）。
+// 任一文件不存在或不属于该用户时整体失败
+func (s *AlbumService) AddFiles(userID, albumID uint, fileIDs []uint) (int64, error) {
+	if len(fileIDs) == 0 || len(fileIDs) > maxAlbumBatch {
+		return 0, fmt.Errorf("%w: file_ids must contain 1 to %d files", ErrInvalidAlbum, maxAlbumBatch)
+	}
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return 0, err
+	}
+	files, err := s.files.fileRepo.FindByIDs(fileIDs)
+	if err != nil {
+		return 0, err
+	}
+	owned := make(map[uint]*model.File, len(files))
+	for _, f := range files {
+		if f.UserID == userID {
+			owned[f.ID] = f
+		}
+	}
+	now := time.Now()
+	items := make([]*model.AlbumItem, 0, len(fileIDs))
+	seen := make(map[uint]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		f, ok := owned[id]
+		if !ok {
+			return 0, gorm.ErrRecordNotFound
+		}
+		if f.IsDir {
+			return 0, fmt.Errorf("%w: folders cannot be added to an album", ErrInvalidAlbum)
+		}
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, &model.AlbumItem{AlbumID: album.ID, FileID: id, CreatedAt: now})
+		}
+	}
+	var added int64
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewAlbumRepository(tx)
+		if added, err = repo.AddItems(items); err != nil {
+			return err
+		}
+		return repo.UpdateFields(album.ID, map[string]interface{}{"updated_at": now})
+	})
+	return added, err
+}
+
+// RemoveFile 从相册中移除文件，文件本身保留
+func (s *AlbumService) RemoveFile(userID, albumID, fileID uint) error {
+	album, err := s.albumRepo.FindByIDAndUser(albumID, userID)
+	if err != nil {
+		return err
+	}
+	removed, err := s.albumRepo.RemoveItem(album.ID, fileID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return gorm.ErrRecordNotFound
+	}
+	return s.albumRepo.UpdateFields(album.ID, map[string]interface{}{"updated_at": time.Now()})
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+)
+
+var ErrArchiveEntryNotFound = errors.New("archive entry not found")
+
+// ArchiveEntry 压缩包中的一项
+type ArchiveEntry struct {
+	Name           string    `json:"name"` // 压缩包内的路径，文件夹以 / 结尾
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressed_size,omitempty"` // 仅 ZIP
+	Modified       time.Time `json:"modified"`
+	IsDir          bool      `json:"is_dir"`
+}
+
+// ListArchiveEntries 列出压缩包中的文件和文件夹（不含符号链接等特殊条目），按压缩包内顺序分页返回。
+// ZIP 只通过范围读取中央目录；tar 需要顺序读取整个压缩包
+func (s *FileService) ListArchiveEntries(userID, fileID uint, offset, limit int) ([]*ArchiveEntry, int, error) {
+	file, format, err := s.archiveFile(userID, fileID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []*ArchiveEntry
+	if format == "zip" {
+		reader := storage.NewRangeReader(s.storage, file.StoragePath, file.Size)
+		defer reader.Close()
+		zr, err := openZip(reader, file.Size)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, f := range zr.File {
+			if entry := zipEntry(f); entry.IsDir || f.Mode().IsRegular() {
+				entries = append(entries, entry)
+			}
+		}
+	} else {
+		err = s.walkTarHeaders(file, format == "tgz", func(hdr *tar.Header) error {
+			if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeDir {
+				entries = append(entries, tarEntry(hdr))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	total := len(entries)
+	if offset >= total {
+		return []*ArchiveEntry{}, total, nil
+	}
+	return entries[offset:min(offset+limit, total)], total, nil
+}
+
+// OpenArchiveEntry 打开压缩包中名为 name 的文件。ZIP 中未压缩（Store）的条目返回的读取器
+// 同时实现 io.Seeker，可按范围读取；其余条目只能顺序读取
+func (s *FileService) OpenArchiveEntry(userID, fileID uint, name string) (io.ReadCloser, *ArchiveEntry, error) {
+	file, format, err := s.archiveFile(userID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if format == "zip" {
+		return s.openZipEntry(file, name)
+	}
+	return s.openTarEntry(file, format == "tgz", name)
+}
+
+func (s *FileService) archiveFile(userID, fileID uint) (*model.File, string, error) {
+	file, err := s.accessFile(userID, fileID, false)
+	if err != nil {
+		return nil, "", err
+	}
+	format := archiveFormat(file)
+	if format == "" {
+		return nil, "", ErrUnsupportedArchive
+	}
+	return file, format, nil
+}
+
+func (s *FileService) openZipEntry(file *model.File, name string) (io.ReadCloser, *ArchiveEntry, error) {
+	reader := storage.NewRangeReader(s.storage, file.StoragePath, file.Size)
+	zr, err := openZip(reader, file.Size)
+	if err != nil {
+		reader.Close()
+		return nil, nil, err
+	}
+	for _, f := range zr.File {
+		entry := zipEntry(f)
+		if entry.Name != name || entry.IsDir || !f.Mode().IsRegular() {
+			continue
+		}
+		if f.Method == zip.Store {
+			// 未压缩的条目在压缩包中是一段连续字节，可直接按范围读取
+			offset, err := f.DataOffset()
+			if err != nil {
+				reader.Close()
+				return nil, nil, err
+			}
+			return &seekableEntry{SectionReader: io.NewSectionReader(reader, offset, entry.Size), closer: reader}, entry, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			reader.Close()
+			return nil, nil, err
+		}
+		return &archiveEntryReader{Reader: rc, closers: []io.Closer{rc, reader}}, entry, nil
+	}
+	reader.Close()
+	return nil, nil, ErrArchiveEntryNotFound
+}
+
+func (s *FileService) openTarEntry(file *model.File, gzipped bool, name string) (io.ReadCloser, *ArchiveEntry, error) {
+	// 找到条目后保持压缩包打开，由返回的读取器负责关闭
+	tr, closer, err := s.openTar(file, gzipped)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		hdr, err := nextTarHeader(tr)
+		if err == io.EOF {
+			err = ErrArchiveEntryNotFound
+		}
+		if err != nil {
+			closer.Close()
+			return nil, nil, err
+		}
+		entry := tarEntry(hdr)
+		if entry.Name == name && hdr.Typeflag == tar.TypeReg {
+			return &archiveEntryReader{Reader: tr, closers: []io.Closer{closer}}, entry, nil
+		}
+	}
+}
+
+// walkTarHeaders 顺序访问 tar 中的每个条目
+func (s *FileService) walkTarHeaders(file *model.File, gzipped bool, fn func(hdr *tar.Header) error) error {
+	tr, closer, err := s.openTar(file, gzipped)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		hdr, err := nextTarHeader(tr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr); err != nil {
+			return err
+		}
+	}
+}
+
+// openTar 打开存储中的 tar（可为 gzip 压缩）用于顺序读取
+func (s *FileService) openTar(file *model.File, gzipped bool) (*tar.Reader, io.Closer, error) {
+	body, err := s.storage.Download(file.StoragePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !gzipped {
+		return tar.NewReader(body), body, nil
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, nil, fmt.Errorf("read gzip: %w", err)
+	}
+	return tar.NewReader(gz), &archiveEntryReader{closers: []io.Closer{gz, body}}, nil
+}
+
+// nextTarHeader 读取下一个条目头。路径是否安全由调用方判断，因此忽略 ErrInsecurePath
+func nextTarHeader(tr *tar.Reader) (*tar.Header, error) {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+		return nil, fmt.Errorf("read tar: %w", err)
+	}
+	return hdr, nil
+}
+
+func openZip(reader *storage.RangeReader, size int64) (*zip.Reader, error) {
+	zr, err := zip.NewReader(reader, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, fmt.Errorf("read zip: %w", err)
+	}
+	return zr, nil
+}
+
+func zipEntry(f *zip.File) *ArchiveEntry {
+	name := strings.ReplaceAll(f.Name, "\\", "/")
+	isDir := f.Mode().IsDir() || strings.HasSuffix(name, "/")
+	entry := &ArchiveEntry{Name: name, Modified: f.Modified, IsDir: isDir}
+	if !isDir {
+		entry.Size = int64(f.UncompressedSize64)
+		entry.CompressedSize = int64(f.CompressedSize64)
+	}
+	return entry
+}
+
+func tarEntry(hdr *tar.Header) *ArchiveEntry {
+	name := hdr.Name
+	isDir := hdr.Typeflag == tar.TypeDir
+	if isDir && !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+	entry := &ArchiveEntry{Name: name, Modified: hdr.ModTime, IsDir: isDir}
+	if !isDir {
+		entry.Size = hdr.Size
+	}
+	return entry
+}
+
+// archiveEntryReader 读取压缩包条目，关闭时依次关闭条目和压缩包
+type archiveEntryReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *archiveEntryReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// seekableEntry 可按范围读取的未压缩 ZIP 条目
+type seekableEntry struct {
+	*io.SectionReader
+	closer io.Closer
+}
+
+func (e *seekableEntry) Close() error {
+	return e.closer.Close()
+}
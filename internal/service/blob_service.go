@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+	"online-disk-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// BlobService 管理内容寻址的存储对象及其引用计数
+type BlobService struct {
+	db        *gorm.DB
+	blobRepo  *repository.BlobRepository
+	thumbRepo *repository.ThumbnailRepository
+	imageRepo *repository.ImageVariantRepository
+	storage   storage.Storage
+}
+
+func NewBlobService(db *gorm.DB, storage storage.Storage) *BlobService {
+	return &BlobService{
+		db:        db,
+		blobRepo:  repository.NewBlobRepository(db),
+		thumbRepo: repository.NewThumbnailRepository(db),
+		imageRepo: repository.NewImageVariantRepository(db),
+		storage:   storage,
+	}
+}
+
+// blobStoragePath 按哈希前两位分目录，避免单目录下文件过多。
+// 路径带随机后缀：对象引用归零被删除的同时若有相同内容重新上传，两者不会写到同一路径
+func blobStoragePath(hash string) (string, error) {
+	suffix, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("blobs/%s/%s-%s", hash[:2], hash, suffix[:8]), nil
+}
+
+// Acquire 获取内容对应的存储对象并增加一次引用；内容尚不存在时调用 upload 写入存储
+func (s *BlobService) Acquire(hash, md5 string, size int64, upload func(storagePath string) error) (*model.Blob, error) {
+	if blob, err := s.Retain(hash); err == nil {
+		return blob, nil
+	}
+
+	storagePath, err := blobStoragePath(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := upload(storagePath); err != nil {
+		s.storage.Delete(storagePath)
+		return nil, err
+	}
+	blob := &model.Blob{Hash: hash, MD5: md5, Size: size, StoragePath: storagePath, RefCount: 1}
+	if err := s.blobRepo.Create(blob); err != nil {
+		// 并发上传相同内容时唯一索引冲突：丢弃自己写入的数据，引用对方创建的记录
+		s.storage.Delete(storagePath)
+		if existing, retryErr := s.Retain(hash); retryErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Retain 为已存在的内容增加一次引用
+func (s *BlobService) Retain(hash string) (*model.Blob, error) {
+	blob, err := s.blobRepo.FindByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.blobRepo.IncRef(blob.ID, 1); err != nil {
+		return nil, err
+	}
+	blob.RefCount++
+	return blob, nil
+}
+
+// RetainByID 为指定对象增加引用，tx 为空时使用默认连接
+func (s *BlobService) RetainByID(tx *gorm.DB, blobID uint) error {
+	return s.repo(tx).IncRef(blobID, 1)
+}
+
+// Release 在事务中释放一次引用。引用归零时删除记录及其全文索引、媒体元数据并返回该对象，
+// 调用方应在事务提交后调用 Purge 删除存储中的数据
+func (s *BlobService) Release(tx *gorm.DB, blobID uint) (*model.Blob, error) {
+	repo := s.repo(tx)
+	blob, err := repo.DecRef(blobID, 1)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if blob.RefCount > 0 {
+		return nil, nil
+	}
+	deleted, err := repo.DeleteIfUnreferenced(blobID)
+	if err != nil || !deleted {
+		return nil, err
+	}
+	db := s.db
+	if tx != nil {
+		db = tx
+	}
+	if err := repository.NewContentIndexRepository(db).DeleteByBlob(blobID); err != nil {
+		return nil, err
+	}
+	if err := repository.NewMediaMetadataRepository(db).DeleteByBlob(blobID); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Purge 删除已无引用的对象数据及其缩略图、图片变换结果，失败只记录日志
+func (s *BlobService) Purge(blobs ...*model.Blob) {
+	ids := make([]uint, 0, len(blobs))
+	for _, blob := range blobs {
+		if blob == nil {
+			continue
+		}
+		ids = append(ids, blob.ID)
+		if err := s.storage.Delete(blob.StoragePath); err != nil {
+			log.Printf("delete blob %s failed: %v", blob.StoragePath, err)
+		}
+	}
+	if thumbs, err := s.thumbRepo.FindByBlobs(ids); err != nil {
+		log.Printf("find thumbnails of deleted blobs failed: %v", err)
+	} else {
+		s.purgeThumbnails(thumbs)
+	}
+	if variants, err := s.imageRepo.FindByBlobs(ids); err != nil {
+		log.Printf("find image variants of deleted blobs failed: %v", err)
+	} else {
+		s.purgeImageVariants(variants)
+	}
+}
+
+// PurgeOrphanImages 删除对象已不存在的缩略图与图片变换结果，用于清理删除对象后未来得及清理的数据
+func (s *BlobService) PurgeOrphanImages() {
+	for {
+		thumbs, err := s.thumbRepo.FindOrphans(500)
+		if err != nil {
+			log.Printf("find orphan thumbnails failed: %v", err)
+			break
+		}
+		if len(thumbs) == 0 || !s.purgeThumbnails(thumbs) {
+			break
+		}
+	}
+	for {
+		variants, err := s.imageRepo.FindOrphans(500)
+		if err != nil {
+			log.Printf("find orphan image variants failed: %v", err)
+			return
+		}
+		if len(variants) == 0 || !s.purgeImageVariants(variants) {
+			return
+		}
+	}
+}
+
+// purgeThumbnails 删除缩略图的数据与记录，返回记录是否全部删除
+func (s *BlobService) purgeThumbnails(thumbs []*model.Thumbnail) bool {
+	ok := true
+	for _, thumb := range thumbs {
+		// 生成失败的记录没有存储对象
+		if thumb.StoragePath != "" {
+			if err := s.storage.Delete(thumb.StoragePath); err != nil {
+				log.Printf("delete thumbnail %s failed: %v", thumb.StoragePath, err)
+			}
+		}
+		if err := s.thumbRepo.Delete(thumb); err != nil {
+			log.Printf("delete thumbnail record of blob %d failed: %v", thumb.BlobID, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// purgeImageVariants 删除图片变换结果的数据与记录，返回记录是否全部删除
+func (s *BlobService) purgeImageVariants(variants []*model.ImageVariant) bool {
+	ok := true
+	for _, variant := range variants {
+		if err := s.storage.Delete(variant.StoragePath); err != nil {
+			log.Printf("delete image variant %s failed: %v", variant.StoragePath, err)
+		}
+		if err := s.imageRepo.Delete(variant); err != nil {
+			log.Printf("delete image variant record of blob %d failed: %v", variant.BlobID, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// FindByHash 按内容哈希查找对象
+func (s *BlobService) FindByHash(hash string) (*model.Blob, error) {
+	return s.blobRepo.FindByHash(hash)
+}
+
+// FindByID 按ID查找对象
+func (s *BlobService) FindByID(blobID uint) (*model.Blob, error) {
+	return s.blobRepo.FindByID(blobID)
+}
+
+func (s *BlobService) repo(tx *gorm.DB) *repository.BlobRepository {
+	if tx == nil {
+		return s.blobRepo
+	}
+	return repository.NewBlobRepository(tx)
+}
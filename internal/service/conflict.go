@@ -0,0 +1,294 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ConflictPolicy 目标位置已存在同名项时的处理方式
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"      // 返回错误
+	ConflictRename    ConflictPolicy = "rename"    // 自动重命名，如 "report (1).pdf"
+	ConflictOverwrite ConflictPolicy = "overwrite" // 文件替换内容并保留旧内容为历史版本；文件夹将已有项移入回收站
+	ConflictSkip      ConflictPolicy = "skip"      // 不做任何修改，返回已有项
+)
+
+var ErrNameConflict = errors.New("an item with the same name already exists")
+
+// ParseConflictPolicy 解析冲突策略，为空时返回 def
+func ParseConflictPolicy(s string, def ConflictPolicy) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return def, nil
+	case ConflictFail, ConflictRename, ConflictOverwrite, ConflictSkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid conflict policy %q", s)
+}
+
+// validName 检查文件名是否合法
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= 255 && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// conflictResolution 按冲突策略处理后的结果
+type conflictResolution struct {
+	name    string      // 最终使用的名称
+	replace *model.File // overwrite：需要替换内容的已有文件
+	skip    *model.File // skip：应直接返回的已有项
+	trash   *model.File // overwrite：需要移入回收站的已有文件夹，由调用方在写入的同一事务中调用 trashReplaced
+}
+
+// resolveConflict 检查 parentID 下是否已有名为 name 的项并按 policy 处理。
+// isDir 为待放入项是否为文件夹；self 为待放入项自身的ID（复制到原位置时已有项就是自身，
+// 无法覆盖，按 rename 处理）。overwrite 遇到文件夹时由调用方将已有项移入回收站
+func (s *FileService) resolveConflict(userID, parentID uint, name string, isDir bool, policy ConflictPolicy, self uint) (*conflictResolution, error) {
+	res := &conflictResolution{name: name}
+	existing, err := s.fileRepo.FindChildByName(userID, parentID, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, nil
+		}
+		return nil, err
+	}
+	if policy == ConflictOverwrite && self != 0 && existing.ID == self {
+		policy = ConflictRename
+	}
+
+	switch policy {
+	case ConflictRename:
+		if res.name, err = s.uniqueName(userID, parentID, name); err != nil {
+			return nil, err
+		}
+	case ConflictSkip:
+		res.skip = existing
+	case ConflictOverwrite:
+		if isDir != existing.IsDir {
+			return nil, ErrNameConflict
+		}
+		if !isDir {
+			res.replace = existing
+			break
+		}
+		res.trash = existing
+	default:
+		return nil, ErrNameConflict
+	}
+	return res, nil
+}
+
+// trashReplaced 在调用方写入新项的事务中将 res.trash 及其子孙移入回收站，
+// 写入失败时随事务一同回滚
+func trashReplaced(tx *gorm.DB, res *conflictResolution) error {
+	existing := res.trash
+	if existing == nil {
+		return nil
+	}
+	// 重新读取并锁定，按当前统计从上级文件夹扣除；恢复时使用同一份统计
+	repo := repository.NewFileRepository(tx)
+	existing, err := repo.FindByIDForUpdate(existing.ID)
+	if err != nil {
+		return err
+	}
+	res.trash = existing
+	files, err := collectSubtreeWith(repo, existing.UserID, existing)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	if err := addToAncestors(tx, existing.UserID, existing.Path, statsOf(existing).neg()); err != nil {
+		return err
+	}
+	for _, batch := range chunkIDs(ids, 500) {
+		if err := repo.MoveToTrash(batch, existing.ID, existing.UserID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// untrashReplaced 恢复 trashReplaced 已提交移入回收站的文件夹，用于分多个事务写入、
+// 后续事务失败的情况（如分批复制）
+func (s *FileService) untrashReplaced(res *conflictResolution) {
+	existing := res.trash
+	if existing == nil {
+		return
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewFileRepository(tx).RestoreTrash(existing.ID); err != nil {
+			return err
+		}
+		return addToAncestors(tx, existing.UserID, existing.Path, statsOf(existing))
+	})
+	if err != nil {
+		log.Printf("restore replaced folder %d failed: %v", existing.ID, err)
+	}
+}
+
+// commitFile 将已持有存储引用、已为其大小预留配额的内容 content 放到 parentID 下：
+// res.replace 不为空时替换其内容并把旧内容保留为历史版本，否则创建新记录。失败时释放引用并归还配额
+func (s *FileService) commitFile(userID, parentID uint, res *conflictResolution, content *model.File) (*model.File, error) {
+	var err error
+	if res.replace != nil {
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			return s.replaceContent(tx, res.replace, content)
+		})
+		if err == nil {
+			s.pruneVersions(userID, res.replace.ID)
+			updated, err := s.fileRepo.FindByIDAndUser(res.replace.ID, userID)
+			if err == nil {
+				s.contentStored(updated)
+			}
+			return updated, err
+		}
+	} else {
+		file := *content
+		file.Name = res.name
+		file.UserID = userID
+		file.ParentID = parentID
+		if file.Path, err = s.childPath(userID, parentID, res.name); err == nil {
+			err = s.db.Transaction(func(tx *gorm.DB) error {
+				if err := repository.NewFileRepository(tx).Create(&file); err != nil {
+					return err
+				}
+				return addToAncestors(tx, userID, file.Path, statsOf(&file))
+			})
+			if err == nil {
+				s.contentStored(&file)
+				return &file, nil
+			}
+		}
+	}
+
+	// 失败时释放为本次写入获取的引用和预留的配额
+	garbage := &storageGarbage{}
+	if releaseErr := s.releaseContent(nil, garbage, content.BlobID, content.StoragePath); releaseErr == nil {
+		s.purgeStorage(garbage)
+	}
+	s.releaseQuota(userID, content.Size)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 并发写入同名项，被唯一索引拦截
+		return nil, ErrNameConflict
+	}
+	return nil, err
+}
+
+// replaceContent 在事务中用 content 替换已有文件的内容，原内容保存为历史版本，并更新上级文件夹的大小。
+// 存储引用随之转移：原内容的引用归历史版本，content 的引用归文件
+func (s *FileService) replaceContent(tx *gorm.DB, target, content *model.File) error {
+	// 重新读取并锁定，历史版本与大小增量都以当前内容为准
+	existing, err := repository.NewFileRepository(tx).FindByIDForUpdate(target.ID)
+	if err != nil {
+		return err
+	}
+	version := &model.FileVersion{
+		FileID:      existing.ID,
+		UserID:      existing.UserID,
+		Size:        existing.Size,
+		MimeType:    existing.MimeType,
+		Hash:        existing.Hash,
+		SHA256:      existing.SHA256,
+		StoragePath: existing.StoragePath,
+		BlobID:      existing.BlobID,
+		ModifiedAt:  existing.UpdatedAt,
+	}
+	if err := repository.NewVersionRepository(tx).Create(version); err != nil {
+		return err
+	}
+	if err := addToAncestors(tx, existing.UserID, existing.Path, folderStats{Size: content.Size - existing.Size}); err != nil {
+		return err
+	}
+	mimeType := content.MimeType
+	if mimeType == "" {
+		mimeType = existing.MimeType
+	}
+	return repository.NewFileRepository(tx).UpdateFields(existing.ID, map[string]interface{}{
+		"size":         content.Size,
+		"mime_type":    mimeType,
+		"hash":         content.Hash,
+		"sha256":       content.SHA256,
+		"storage_path": content.StoragePath,
+		"blob_id":      content.BlobID,
+		"updated_at":   time.Now(),
+	})
+}
+
+// fileNameIndex 保证同一目录下名称唯一的索引。回收站中的记录 trash_root_id 不为 0，
+// 因此不会与目录下的现有项冲突
+const fileNameIndex = "idx_files_parent_name"
+
+// EnsureNameIndex 创建同名唯一索引。索引不存在时先将旧数据中的重名项按 "name (n).ext" 重命名
+func (s *FileService) EnsureNameIndex() error {
+	if s.db.Migrator().HasIndex(&model.File{}, fileNameIndex) {
+		return nil
+	}
+	dups, err := s.fileRepo.FindDuplicateNames()
+	if err != nil {
+		return err
+	}
+	for _, dup := range dups {
+		if err := s.renameDuplicates(dup); err != nil {
+			return err
+		}
+	}
+	return s.db.Exec("CREATE UNIQUE INDEX " + fileNameIndex + " ON files (user_id, parent_id, name, trash_root_id)").Error
+}
+
+// renameDuplicates 保留最早创建的一项，其余依次重命名
+func (s *FileService) renameDuplicates(dup repository.DuplicateName) error {
+	files, err := s.fileRepo.FindByName(dup)
+	if err != nil || len(files) < 2 {
+		return err
+	}
+	ext := filepath.Ext(dup.Name)
+	base := strings.TrimSuffix(dup.Name, ext)
+	n := 0
+	for _, f := range files[1:] {
+		var name string
+		for {
+			n++
+			name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+			taken, err := s.fileRepo.FindByName(repository.DuplicateName{UserID: dup.UserID, ParentID: dup.ParentID, Name: name, TrashRootID: dup.TrashRootID})
+			if err != nil {
+				return err
+			}
+			if len(taken) == 0 {
+				break
+			}
+		}
+
+		newPath := joinPath(path.Dir(f.Path), name)
+		var subtree []*model.File
+		if f.IsDir && !f.DeletedAt.Valid {
+			if subtree, err = s.collectSubtree(f.UserID, f); err != nil {
+				return err
+			}
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := repository.NewFileRepository(tx).UpdateFields(f.ID, map[string]interface{}{"name": name, "path": newPath}); err != nil {
+				return err
+			}
+			return rewriteSubtreePaths(tx, subtree, newPath)
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("renamed duplicate %q to %q (file %d)", f.Path, newPath, f.ID)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import "testing"
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		def     ConflictPolicy
+		want    ConflictPolicy
+		wantErr bool
+	}{
+		{in: "", def: ConflictFail, want: ConflictFail},
+		{in: "   ", def: ConflictRename, want: ConflictRename},
+		{in: "fail", def: ConflictRename, want: ConflictFail},
+		{in: "rename", def: ConflictFail, want: ConflictRename},
+		{in: "overwrite", def: ConflictFail, want: ConflictOverwrite},
+		{in: "skip", def: ConflictFail, want: ConflictSkip},
+		{in: " Rename ", def: ConflictFail, want: ConflictRename},
+		{in: "SKIP", def: ConflictFail, want: ConflictSkip},
+		{in: "replace", def: ConflictFail, wantErr: true},
+		{in: "fail,rename", def: ConflictFail, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseConflictPolicy(tt.in, tt.def)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseConflictPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseConflictPolicy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
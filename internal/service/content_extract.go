@@ -0,0 +1,355 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// 支持提取文本的格式
+const (
+	FormatText = "text" // 纯文本、Markdown、源代码等
+	FormatHTML = "html"
+	FormatDOCX = "docx"
+	FormatXLSX = "xlsx"
+	FormatPPTX = "pptx"
+	FormatODT  = "odt"
+	FormatODS  = "ods"
+	FormatODP  = "odp"
+)
+
+// maxXMLEntryBytes 从文档压缩包中读取单个 XML 条目的上限（解压后），防止压缩炸弹
+const maxXMLEntryBytes = 256 << 20
+
+// errTextFull 提取的文本达到上限，停止读取
+var errTextFull = errors.New("text limit reached")
+
+// textExtensions 按纯文本提取的扩展名
+var textExtensions = map[string]bool{
+	".txt": true, ".text": true, ".md": true, ".markdown": true, ".rst": true, ".tex": true, ".log": true,
+	".csv": true, ".tsv": true, ".json": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true,
+	".ini": true, ".cfg": true, ".conf": true, ".properties": true, ".env": true, ".sql": true,
+	".go": true, ".py": true, ".js": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true, ".jsx": true,
+	".java": true, ".kt": true, ".kts": true, ".scala": true, ".groovy": true, ".gradle": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".cxx": true, ".hpp": true, ".cs": true,
+	".rs": true, ".rb": true, ".php": true, ".swift": true, ".m": true, ".dart": true, ".lua": true,
+	".pl": true, ".r": true, ".sh": true, ".bash": true, ".zsh": true, ".ps1": true, ".bat": true,
+	".vue": true, ".svelte": true, ".css": true, ".scss": true, ".less": true, ".proto": true,
+}
+
+// textFileNames 没有扩展名但按纯文本提取的文件名
+var textFileNames = map[string]bool{
+	"makefile": true, "dockerfile": true, "readme": true, "license": true, "changelog": true,
+}
+
+// contentFormat 根据文件名和 MIME 类型判断可提取文本的格式，不支持时返回空字符串
+func contentFormat(name, mimeType string) string {
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	case ".docx", ".docm":
+		return FormatDOCX
+	case ".xlsx", ".xlsm":
+		return FormatXLSX
+	case ".pptx", ".pptm":
+		return FormatPPTX
+	case ".odt":
+		return FormatODT
+	case ".ods":
+		return FormatODS
+	case ".odp":
+		return FormatODP
+	}
+	if textExtensions[ext] || (ext == "" && textFileNames[strings.ToLower(name)]) {
+		return FormatText
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	switch {
+	case mimeType == "text/html":
+		return FormatHTML
+	case strings.HasPrefix(mimeType, "text/"), mimeType == "application/json", mimeType == "application/xml":
+		return FormatText
+	}
+	return ""
+}
+
+// textWriter 收集提取的文本，超出上限时截断并返回 errTextFull
+type textWriter struct {
+	buf       strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (w *textWriter) WriteString(s string) error {
+	if w.truncated {
+		return errTextFull
+	}
+	if remain := w.limit - w.buf.Len(); len(s) > remain {
+		// 在字符边界截断
+		for remain > 0 && !utf8.RuneStart(s[remain]) {
+			remain--
+		}
+		w.buf.WriteString(s[:remain])
+		w.truncated = true
+		return errTextFull
+	}
+	w.buf.WriteString(s)
+	return nil
+}
+
+// newline 换行，已在行首时不重复
+func (w *textWriter) newline() error {
+	if w.buf.Len() == 0 || strings.HasSuffix(w.buf.String(), "\n") {
+		return nil
+	}
+	return w.WriteString("\n")
+}
+
+// extractText 从 r 中提取 format 格式文档的文本，最多 limit 字节。
+// 文档格式（OOXML、ODF）需要随机读取，r 须同时实现 io.ReaderAt，size 为其大小
+func extractText(format string, r io.Reader, size int64, limit int) (string, bool, error) {
+	w := &textWriter{limit: limit}
+	var err error
+	switch format {
+	case FormatText:
+		err = extractPlain(w, r)
+	case FormatHTML:
+		err = extractHTML(w, r)
+	case FormatDOCX, FormatXLSX, FormatPPTX, FormatODT, FormatODS, FormatODP:
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			return "", false, fmt.Errorf("%s requires random access", format)
+		}
+		err = extractPackage(w, format, ra, size)
+	default:
+		return "", false, fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil && !errors.Is(err, errTextFull) {
+		return "", false, err
+	}
+	// HTML 与 XML 中可能夹带非 UTF-8 的字节，数据库的文本列不接受
+	return strings.ToValidUTF8(w.buf.String(), " "), w.truncated, nil
+}
+
+// extractPlain 读取纯文本。含 NUL 字符的内容视为二进制文件；非 UTF-8 的字节替换为空格
+func extractPlain(w *textWriter, r io.Reader) error {
+	// 多读一个字节用于判断是否超出上限，超出部分由 WriteString 在字符边界截断
+	data, err := io.ReadAll(io.LimitReader(r, int64(w.limit)+1))
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return errors.New("binary content")
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return w.WriteString(strings.ToValidUTF8(string(data), " "))
+}
+
+// htmlSkipped 内容不属于正文的 HTML 元素
+var htmlSkipped = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "svg": true}
+
+// htmlBlocks 前后需要换行的 HTML 块级元素
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "td": true, "th": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "pre": true, "blockquote": true,
+	"section": true, "article": true, "header": true, "footer": true, "title": true, "hr": true,
+}
+
+// extractHTML 提取 HTML 中可见的文本，字符实体已解码
+func extractHTML(w *textWriter, r io.Reader) error {
+	z := html.NewTokenizer(r)
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return nil
+			}
+			return z.Err()
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if htmlSkipped[string(name)] {
+				skip++
+			} else if htmlBlocks[string(name)] {
+				if err := w.newline(); err != nil {
+					return err
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if htmlSkipped[string(name)] {
+				skip = max(skip-1, 0)
+			} else if htmlBlocks[string(name)] {
+				if err := w.newline(); err != nil {
+					return err
+				}
+			}
+		case html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if htmlBlocks[string(name)] {
+				if err := w.newline(); err != nil {
+					return err
+				}
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			// 保留原有空白，行内元素（如 <b>）前后的文本不会被拆开或连在一起
+			if text := string(z.Text()); strings.TrimSpace(text) != "" {
+				if err := w.WriteString(text); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// packageParts 返回文档压缩包中需要提取文本的 XML 条目，按阅读顺序排列
+func packageParts(format string, zr *zip.Reader) []*zip.File {
+	var parts []*zip.File
+	rank := func(name string) (int, bool) {
+		switch format {
+		case FormatDOCX:
+			switch {
+			case name == "word/document.xml":
+				return 0, true
+			case strings.HasPrefix(name, "word/header"), strings.HasPrefix(name, "word/footer"),
+				name == "word/footnotes.xml", name == "word/endnotes.xml", name == "word/comments.xml":
+				return 1, true
+			}
+		case FormatXLSX:
+			switch {
+			case name == "xl/sharedStrings.xml":
+				return 0, true
+			case strings.HasPrefix(name, "xl/worksheets/sheet"):
+				return 1, true
+			}
+		case FormatPPTX:
+			switch {
+			case strings.HasPrefix(name, "ppt/slides/slide"):
+				return 0, true
+			case strings.HasPrefix(name, "ppt/notesSlides/notesSlide"):
+				return 1, true
+			}
+		default:
+			return 0, name == "content.xml"
+		}
+		return 0, false
+	}
+	ranks := make(map[*zip.File]int)
+	for _, f := range zr.File {
+		if r, ok := rank(f.Name); ok && strings.HasSuffix(f.Name, ".xml") {
+			ranks[f] = r
+			parts = append(parts, f)
+		}
+	}
+	// 同类条目按名称中的序号排序，如 slide2.xml 排在 slide10.xml 之前
+	sort.SliceStable(parts, func(i, j int) bool {
+		if ranks[parts[i]] != ranks[parts[j]] {
+			return ranks[parts[i]] < ranks[parts[j]]
+		}
+		return partNumber(parts[i].Name) < partNumber(parts[j].Name)
+	})
+	return parts
+}
+
+// partNumber 返回条目名称中扩展名前的数字，没有时为 0
+func partNumber(name string) int {
+	base := strings.TrimSuffix(path.Base(name), ".xml")
+	i := len(base)
+	for i > 0 && base[i-1] >= '0' && base[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(base[i:])
+	return n
+}
+
+// extractPackage 提取 OOXML 与 ODF 文档的文本。两者都是 ZIP 压缩包中的 XML：
+// OOXML 的文本位于 t 元素（w:t、a:t 等）中；ODF 的 content.xml 中全部字符数据都是正文
+func extractPackage(w *textWriter, format string, ra io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("read %s: %w", format, err)
+	}
+	parts := packageParts(format, zr)
+	if len(parts) == 0 {
+		return fmt.Errorf("read %s: no document content", format)
+	}
+	odf := format == FormatODT || format == FormatODS || format == FormatODP
+	for _, part := range parts {
+		rc, err := part.Open()
+		if err != nil {
+			return err
+		}
+		err = extractXML(w, io.LimitReader(rc, maxXMLEntryBytes), odf)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := w.newline(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractXML 提取文档 XML 中的文本。all 为 true 时收集全部字符数据，否则只收集 t 元素中的文本；
+// 段落、表格单元格等元素结束时换行
+func extractXML(w *textWriter, r io.Reader, all bool) error {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	inText := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText++
+			case "tab":
+				err = w.WriteString("\t")
+			case "br", "cr", "line-break":
+				err = w.WriteString("\n")
+			case "s":
+				if all {
+					// ODF 用 <text:s text:c="n"/> 表示连续空格
+					err = w.WriteString(" ")
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = max(inText-1, 0)
+			case "p", "h", "si", "row", "table-row":
+				err = w.newline()
+			case "c", "tc", "table-cell":
+				err = w.WriteString("\t")
+			}
+		case xml.CharData:
+			if all || inText > 0 {
+				err = w.WriteString(string(t))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
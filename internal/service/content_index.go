@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+)
+
+const (
+	maxTermRunes      = 32       // 超长的词只保留前面部分
+	contentChunkBytes = 16 << 10 // 文本分段保存的大小上限
+)
+
+// ContentIndexLimits 全文索引的大小限制
+type ContentIndexLimits struct {
+	MaxFileBytes int64 // 超过该大小的文件不提取文本
+	MaxTextBytes int   // 每个文件最多索引的文本字节数，超出部分不索引
+}
+
+// SetContentIndexLimits 设置全文索引的大小限制
+func (s *FileService) SetContentIndexLimits(limits ContentIndexLimits) {
+	s.contentLimits = limits
+}
+
+// notifyContentIndexer 有新内容写入时唤醒后台索引，已有待处理的唤醒时不重复
+func (s *FileService) notifyContentIndexer() {
+	select {
+	case s.contentWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartContentIndexer 启动后台全文索引：上传后立即被唤醒，另按 interval 定时检查遗漏的内容
+// （如升级前已有的文件、从回收站恢复的文件）。启动前先清理对象已不存在的索引数据
+func (s *FileService) StartContentIndexer(interval time.Duration) {
+	if n, err := s.contentRepo.DeleteOrphans(); err != nil {
+		log.Printf("clean content index failed: %v", err)
+	} else if n > 0 {
+		log.Printf("removed content index of %d deleted blobs", n)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.indexPendingContent()
+			select {
+			case <-s.contentWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// indexPendingContent 为尚未建立索引的对象逐个提取文本并建立索引
+func (s *FileService) indexPendingContent() {
+	var afterID uint
+	for {
+		ids, err := s.contentRepo.FindPendingBlobs(afterID, 100)
+		if err != nil {
+			log.Printf("find unindexed content failed: %v", err)
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			if err := s.indexBlob(id); err != nil {
+				log.Printf("index content of blob %d failed: %v", id, err)
+				return
+			}
+			afterID = id
+		}
+	}
+}
+
+// indexBlob 提取对象的文本并保存索引。不支持的格式和提取失败（含解析时 panic）也会记录状态，之后不再重试；
+// 只有数据库出错时返回错误
+func (s *FileService) indexBlob(blobID uint) error {
+	file, err := s.fileRepo.FindAnyByBlob(blobID)
+	if err != nil {
+		return nil // 引用它的文件已被删除
+	}
+	index := &model.ContentIndex{BlobID: blobID, Format: contentFormat(file.Name, file.MimeType)}
+	var text string
+	switch {
+	case index.Format == "":
+		index.Status = model.ContentStatusSkipped
+	case s.contentLimits.MaxFileBytes > 0 && file.Size > s.contentLimits.MaxFileBytes:
+		index.Status = model.ContentStatusSkipped
+		index.Error = "file too large"
+	default:
+		// 解析器处理的是用户上传的任意内容，异常输入导致的 panic 记为提取失败，不影响服务也不再重试
+		func() {
+			reader := storage.NewRangeReader(s.storage, file.StoragePath, file.Size)
+			defer reader.Close()
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("extract text panicked: %v", r)
+				}
+			}()
+			text, index.Truncated, err = extractText(index.Format, reader, file.Size, s.contentLimits.MaxTextBytes)
+		}()
+		if err != nil {
+			index.Status = model.ContentStatusFailed
+			index.Error = truncate(err.Error(), 255)
+		} else {
+			index.Status = model.ContentStatusIndexed
+			index.TextSize = int64(len(text))
+		}
+	}
+	_, err = s.contentRepo.Save(index, contentTerms(blobID, text), contentChunks(blobID, text))
+	return err
+}
+
+// contentTerms 统计文本中每个词的出现次数与首次出现位置
+func contentTerms(blobID uint, text string) []*model.ContentTerm {
+	index := make(map[string]*model.ContentTerm)
+	var terms []*model.ContentTerm
+	tokenize(text, true, func(term string, start, _ int) {
+		if t, ok := index[term]; ok {
+			t.Freq++
+			return
+		}
+		t := &model.ContentTerm{Term: term, BlobID: blobID, Freq: 1, Pos: start}
+		index[term] = t
+		terms = append(terms, t)
+	})
+	return terms
+}
+
+// contentChunks 将文本在字符边界处切分为不超过 contentChunkBytes 的段
+func contentChunks(blobID uint, text string) []*model.ContentChunk {
+	var chunks []*model.ContentChunk
+	for start := 0; start < len(text); {
+		end := min(start+contentChunkBytes, len(text))
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		chunks = append(chunks, &model.ContentChunk{BlobID: blobID, Start: start, Text: text[start:end]})
+		start = end
+	}
+	return chunks
+}
+
+// isCJK 判断是否为中日韩文字。这些文字的词之间没有空格，按相邻两字（bigram）切分
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenize 将文本切分为小写的词，emit 接收词及其在 text 中的字节范围。
+// 字母和数字组成的连续片段为一个词；中日韩文字连续片段切分为相邻两字的组合，
+// unigrams 为 true 时还输出每个单字（建立索引时使用，使单字查询也能命中）
+func tokenize(text string, unigrams bool, emit func(term string, start, end int)) {
+	wordStart := -1
+	type cjkRune struct{ start, end int }
+	var run []cjkRune
+
+	flushWord := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		word := strings.ToLower(text[wordStart:end])
+		if utf8.RuneCountInString(word) > maxTermRunes {
+			word = string([]rune(word)[:maxTermRunes])
+		}
+		emit(word, wordStart, end)
+		wordStart = -1
+	}
+	flushCJK := func() {
+		for i, r := range run {
+			if unigrams || len(run) == 1 {
+				emit(text[r.start:r.end], r.start, r.end)
+			}
+			if i+1 < len(run) {
+				emit(text[r.start:run[i+1].end], r.start, run[i+1].end)
+			}
+		}
+		run = run[:0]
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord(i)
+			run = append(run, cjkRune{start: i, end: i + utf8.RuneLen(r)})
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			flushCJK()
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			flushWord(i)
+			flushCJK()
+		}
+	}
+	flushWord(len(text))
+	flushCJK()
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text     string
+		unigrams bool
+		want     []string
+	}{
+		{text: "", want: nil},
+		{text: "Hello, World!", want: []string{"hello", "world"}},
+		{text: "  report_2024-final.PDF ", want: []string{"report", "2024", "final", "pdf"}},
+		{text: "café naïve", want: []string{"café", "naïve"}},
+		{text: "中文", want: []string{"中文"}},
+		{text: "中文", unigrams: true, want: []string{"中", "中文", "文"}},
+		{text: "全文搜索", want: []string{"全文", "文搜", "搜索"}},
+		{text: "字", want: []string{"字"}},
+		{text: "Go语言", want: []string{"go", "语言"}},
+		{text: "ひらがな", want: []string{"ひら", "らが", "がな"}},
+		{text: "한국어", unigrams: true, want: []string{"한", "한국", "국", "국어", "어"}},
+		{text: strings.Repeat("a", 40), want: []string{strings.Repeat("a", maxTermRunes)}},
+	}
+	for _, tt := range tests {
+		var got []string
+		tokenize(tt.text, tt.unigrams, func(term string, start, end int) {
+			got = append(got, term)
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q, %v) = %q, want %q", tt.text, tt.unigrams, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	text := "Ab 中文x"
+	type span struct{ start, end int }
+	var got []span
+	tokenize(text, false, func(term string, start, end int) {
+		if !strings.EqualFold(text[start:end], term) {
+			t.Errorf("term %q does not match text[%d:%d] = %q", term, start, end, text[start:end])
+		}
+		got = append(got, span{start, end})
+	})
+	want := []span{{0, 2}, {3, 9}, {9, 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize offsets = %v, want %v", got, want)
+	}
+}
+
+func TestSearchContentFindsRepairedLegacyFile(t *testing.T) {
+	s, user := newTestFileService(t)
+	a, err := s.CreateFolder(user.ID, "a", 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.CreateFolder(user.ID, "b", a.ID, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetContentIndexLimits(ContentIndexLimits{MaxFileBytes: 1 << 20, MaxTextBytes: 1 << 10})
+	file := storeText(t, s, user.ID, b.ID, "notes.txt", "quarterly numbers look fine")
+	if err := s.indexBlob(file.BlobID); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟旧版本上传的文件：位于子文件夹中，路径却是 "/名称"
+	if err := s.fileRepo.UpdateFields(file.ID, map[string]interface{}{"path": "/notes.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnsureFolderStats(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, folderID := range []uint{0, a.ID, b.ID} {
+		hits, total, err := s.SearchContent(user.ID, "quarterly", folderID, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(hits) != 1 || hits[0].File.ID != file.ID {
+			t.Errorf("content search in folder %d = %d results, want %s", folderID, total, file.Name)
+		}
+	}
+}
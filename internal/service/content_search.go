@@ -0,0 +1,235 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+)
+
+const (
+	maxQueryTerms = 16
+	snippetBefore = 60  // 摘要在首个命中词之前保留的字节数
+	snippetAfter  = 180 // 摘要在首个命中词之后保留的字节数
+)
+
+// ContentHit 全文搜索命中的文件。Snippet 为命中位置附近的文本，已做 HTML 转义，命中的词用 <mark> 标出
+type ContentHit struct {
+	File    *model.File `json:"file"`
+	Snippet string      `json:"snippet"`
+	Score   int64       `json:"score"` // 检索词在文件中出现的总次数
+}
+
+// SearchContent 在已建立索引的文件内容中搜索包含 query 中全部词的文件，按出现次数从高到低排列。
+// 范围为用户自己的文件和他人授权给该用户的文件；指定 folderID 时只搜索该文件夹下（递归），
+// 可以是他人授权的文件夹。回收站中的文件不会出现在结果中
+func (s *FileService) SearchContent(userID uint, query string, folderID uint, page, limit int) ([]*ContentHit, int64, error) {
+	terms := queryTerms(query)
+	switch {
+	case len(terms) == 0:
+		return nil, 0, fmt.Errorf("%w: query has no searchable words", ErrInvalidSearch)
+	case len(terms) > maxQueryTerms:
+		return nil, 0, fmt.Errorf("%w: query has more than %d words", ErrInvalidSearch, maxQueryTerms)
+	}
+	scopes, err := s.contentScopes(userID, folderID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	matches, total, err := s.contentRepo.Search(terms, scopes, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]*ContentHit, 0, len(matches))
+	if len(matches) == 0 {
+		return hits, total, nil
+	}
+
+	fileIDs := make([]uint, len(matches))
+	blobIDs := make([]uint, len(matches))
+	for i, m := range matches {
+		fileIDs[i] = m.FileID
+		blobIDs[i] = m.BlobID
+	}
+	files, err := s.fileRepo.FindByIDs(fileIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]*model.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+	positions, err := s.firstPositions(blobIDs, terms)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	termSet := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		termSet[t] = true
+	}
+	snippets := make(map[uint]string)
+	for _, m := range matches {
+		file, ok := byID[m.FileID]
+		if !ok {
+			continue // 查询后被删除
+		}
+		snippet, ok := snippets[m.BlobID]
+		if !ok {
+			if snippet, err = s.contentSnippet(m.BlobID, positions[m.BlobID], termSet); err != nil {
+				return nil, 0, err
+			}
+			snippets[m.BlobID] = snippet
+		}
+		hits = append(hits, &ContentHit{File: file, Snippet: snippet, Score: m.Score})
+	}
+	return hits, total, nil
+}
+
+// contentScopes 返回用户可以搜索的范围。文件夹按路径前缀匹配子孙，
+// 旧版本留下的错误路径在启动时已按 parent_id 修复（见 EnsureFolderStats）
+func (s *FileService) contentScopes(userID, folderID uint) ([]repository.ContentScope, error) {
+	if folderID != 0 {
+		folder, err := s.accessFile(userID, folderID, false)
+		if err != nil {
+			return nil, err
+		}
+		if !folder.IsDir {
+			return nil, ErrNotAFolder
+		}
+		return []repository.ContentScope{{UserID: folder.UserID, UnderPath: folder.Path + "/"}}, nil
+	}
+
+	scopes := []repository.ContentScope{{UserID: userID}}
+	shared, err := s.grantRepo.FindFilesByGrantee(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range shared {
+		if f.IsDir {
+			scopes = append(scopes, repository.ContentScope{UserID: f.UserID, UnderPath: f.Path + "/"})
+		} else {
+			scopes = append(scopes, repository.ContentScope{UserID: f.UserID, FileID: f.ID})
+		}
+	}
+	return scopes, nil
+}
+
+// queryTerms 按建立索引时的规则切分检索词并去重
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	tokenize(query, false, func(term string, _, _ int) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	})
+	return terms
+}
+
+// firstPositions 返回每个对象中任一检索词最早出现的位置
+func (s *FileService) firstPositions(blobIDs []uint, terms []string) (map[uint]int, error) {
+	found, err := s.contentRepo.FindTerms(blobIDs, terms)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[uint]int, len(blobIDs))
+	for _, t := range found {
+		if pos, ok := positions[t.BlobID]; !ok || t.Pos < pos {
+			positions[t.BlobID] = t.Pos
+		}
+	}
+	return positions, nil
+}
+
+// contentSnippet 截取对象文本中 pos 附近的一段作为摘要，标出其中的检索词
+func (s *FileService) contentSnippet(blobID uint, pos int, terms map[string]bool) (string, error) {
+	from := max(pos-snippetBefore, 0)
+	to := pos + snippetAfter
+	// 包含 from 的段起始于 (from-contentChunkBytes, from]
+	chunks, err := s.contentRepo.FindChunks(blobID, from-contentChunkBytes+1, to)
+	if err != nil || len(chunks) == 0 {
+		return "", err
+	}
+	var b strings.Builder
+	for _, c := range chunks {
+		b.WriteString(c.Text)
+	}
+	text := b.String()
+	base := chunks[0].Start
+
+	lo := max(from-base, 0)
+	hi := min(to-base, len(text))
+	for lo < hi && !utf8.RuneStart(text[lo]) {
+		lo++
+	}
+	for hi < len(text) && hi > lo && !utf8.RuneStart(text[hi]) {
+		hi--
+	}
+	snippet := highlight(text[lo:hi], terms)
+	if base+lo > 0 {
+		snippet = "…" + snippet
+	}
+	if hi < len(text) {
+		snippet += "…"
+	}
+	return snippet, nil
+}
+
+// highlight 对文本做 HTML 转义，用 <mark> 标出检索词，并将连续空白合并为一个空格
+func highlight(text string, terms map[string]bool) string {
+	type span struct{ start, end int }
+	var spans []span
+	tokenize(text, true, func(term string, start, end int) {
+		if terms[term] {
+			spans = append(spans, span{start, end})
+		}
+	})
+	// 中日韩文字的相邻两字组合会互相重叠，合并为连续的区间
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := spans[:0]
+	for _, sp := range spans {
+		if n := len(merged); n > 0 && sp.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, sp.end)
+			continue
+		}
+		merged = append(merged, sp)
+	}
+
+	var b strings.Builder
+	space := false // 有待输出的空白，遇到下一个可见字符时输出
+	write := func(s string, escape bool) {
+		for _, r := range s {
+			if escape && unicode.IsSpace(r) {
+				space = true
+				continue
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			if escape {
+				b.WriteString(html.EscapeString(string(r)))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	last := 0
+	for _, sp := range merged {
+		write(text[last:sp.start], true)
+		write("<mark>", false)
+		write(text[sp.start:sp.end], true)
+		b.WriteString("</mark>")
+		last = sp.end
+	}
+	write(text[last:], true)
+	return b.String()
+}
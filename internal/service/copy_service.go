@@ -0,0 +1,260 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+	"online-disk-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCopy = errors.New("cannot copy a folder into itself or its descendant")
+
+// CopySyncLimit 条目数不超过该值的复制在请求内直接完成，否则作为后台任务执行
+const CopySyncLimit = 200
+
+// copyBatchSize 每个事务复制的条目数
+const copyBatchSize = 200
+
+// CopyService 服务端复制文件和文件夹，无需客户端重新上传内容
+type CopyService struct {
+	files *FileService
+	jobs  *JobService
+}
+
+func NewCopyService(files *FileService, jobs *JobService) *CopyService {
+	return &CopyService{files: files, jobs: jobs}
+}
+
+// Copy 将文件或文件夹复制到 targetParentID 下（nil 表示源所在目录），newName 为空时沿用原名。
+// 条目较少时同步完成并返回新文件；条目较多或 async 为 true 时返回后台任务
+func (s *CopyService) Copy(userID, fileID uint, newName string, targetParentID *uint, policy ConflictPolicy, async bool) (*model.File, *model.Job, error) {
+	fileRepo := s.files.fileRepo
+	source, err := fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := source.Name
+	if newName != "" {
+		if !validName(newName) {
+			return nil, nil, ErrInvalidName
+		}
+		name = newName
+	}
+	parentID := source.ParentID
+	if targetParentID != nil {
+		parentID = *targetParentID
+	}
+	if parentID != 0 {
+		parent, err := fileRepo.FindByIDAndUser(parentID, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !parent.IsDir {
+			return nil, nil, ErrNotAFolder
+		}
+		if source.IsDir {
+			within, err := s.files.isWithin(userID, source.ID, parent)
+			if err != nil {
+				return nil, nil, err
+			}
+			if within {
+				return nil, nil, ErrInvalidCopy
+			}
+		}
+	}
+
+	subtree, err := s.files.collectSubtree(userID, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var size int64
+	for _, f := range subtree {
+		if !f.IsDir {
+			size += f.Size
+		}
+	}
+	if err := s.files.checkQuota(userID, size); err != nil {
+		return nil, nil, err
+	}
+
+	// 处理重名
+	res, err := s.files.resolveConflict(userID, parentID, name, source.IsDir, policy, source.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.skip != nil {
+		return res.skip, nil, nil
+	}
+	if res.replace != nil {
+		file, err := s.copyOnto(userID, source, res.replace)
+		return file, nil, err
+	}
+	name = res.name
+
+	if !async && len(subtree) <= CopySyncLimit {
+		file, err := s.copySubtree(userID, subtree, parentID, name, res, nil)
+		return file, nil, err
+	}
+	job, err := s.jobs.Start(userID, model.JobTypeCopy, source.ID, len(subtree), func(progress *JobProgress) (uint, error) {
+		file, err := s.copySubtree(userID, subtree, parentID, name, res, progress.Done)
+		if err != nil {
+			return 0, err
+		}
+		return file.ID, nil
+	})
+	return nil, job, err
+}
+
+// copyOnto 用文件 source 的内容覆盖同名文件 target，target 的旧内容保留为历史版本
+func (s *CopyService) copyOnto(userID uint, source, target *model.File) (*model.File, error) {
+	content := *source
+	if err := s.files.reserveQuota(userID, source.Size); err != nil {
+		return nil, err
+	}
+	if source.BlobID != 0 {
+		if err := s.files.blobs.RetainByID(nil, source.BlobID); err != nil {
+			s.files.releaseQuota(userID, source.Size)
+			return nil, err
+		}
+	} else if source.StoragePath != "" {
+		objectPath, err := s.copyObject(userID, source)
+		if err != nil {
+			s.files.releaseQuota(userID, source.Size)
+			return nil, err
+		}
+		content.StoragePath = objectPath
+	}
+	return s.files.commitFile(userID, target.ParentID, &conflictResolution{name: target.Name, replace: target}, &content)
+}
+
+// copySubtree 按层级顺序复制 subtree（collectSubtree 的结果），根节点放到 parentID 下并命名为 name。
+// 内容寻址的文件只增加引用计数；旧记录在存储支持时做服务端复制，否则与源记录共用存储对象。
+// 被覆盖的文件夹 res.trash 与根节点在同一事务中移入回收站。中途失败时删除已复制的部分并恢复被覆盖的文件夹
+func (s *CopyService) copySubtree(userID uint, subtree []*model.File, parentID uint, name string, res *conflictResolution, progress func(int)) (*model.File, error) {
+	rootPath, err := s.files.childPath(userID, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint]uint, len(subtree))
+	paths := make(map[uint]string, len(subtree))
+	rootID := uint(0)
+	for start := 0; start < len(subtree); start += copyBatchSize {
+		batch := subtree[start:min(start+copyBatchSize, len(subtree))]
+		var objects []string                   // 本批服务端复制产生的存储对象，事务回滚时删除
+		var size int64                         // 本批新增的已用空间，与记录在同一事务中预留
+		deltas := make(map[string]folderStats) // 本批新增项对各上级文件夹统计的增量
+		err := s.files.db.Transaction(func(tx *gorm.DB) error {
+			if start == 0 {
+				if err := trashReplaced(tx, res); err != nil {
+					return err
+				}
+			}
+			repo := repository.NewFileRepository(tx)
+			for _, src := range batch {
+				dst := &model.File{
+					Name:        src.Name,
+					Size:        src.Size,
+					MimeType:    src.MimeType,
+					Hash:        src.Hash,
+					SHA256:      src.SHA256,
+					UserID:      userID,
+					StoragePath: src.StoragePath,
+					BlobID:      src.BlobID,
+					IsDir:       src.IsDir,
+				}
+				if src.IsDir {
+					// 文件夹统计随子项复制逐批累加
+					dst.Size = 0
+				}
+				if src.ID == subtree[0].ID {
+					dst.ParentID = parentID
+					dst.Name = name
+					dst.Path = rootPath
+				} else {
+					dst.ParentID = ids[src.ParentID]
+					dst.Path = joinPath(paths[src.ParentID], src.Name)
+				}
+
+				if !src.IsDir {
+					if src.BlobID != 0 {
+						if err := s.files.blobs.RetainByID(tx, src.BlobID); err != nil {
+							return err
+						}
+					} else if src.StoragePath != "" {
+						objectPath, err := s.copyObject(userID, src)
+						if err != nil {
+							return err
+						}
+						if objectPath != src.StoragePath {
+							objects = append(objects, objectPath)
+						}
+						dst.StoragePath = objectPath
+					}
+				}
+
+				if err := repo.Create(dst); err != nil {
+					return err
+				}
+				ids[src.ID] = dst.ID
+				paths[src.ID] = dst.Path
+				if !src.IsDir {
+					size += src.Size
+				}
+				for _, p := range ancestorPaths(dst.Path) {
+					deltas[p] = deltas[p].add(statsOf(dst))
+				}
+			}
+			if err := addFolderStats(tx, userID, deltas); err != nil {
+				return err
+			}
+			if size == 0 {
+				return nil
+			}
+			// 复制期间其他写入可能已占用空间，逐批预留，空间不足时中止并撤销已复制的部分
+			return s.files.reserveQuotaTx(tx, userID, size)
+		})
+		if err != nil {
+			for _, p := range objects {
+				s.files.storage.Delete(p)
+			}
+			if rootID != 0 {
+				s.files.DeleteFile(userID, rootID, true)
+				s.files.untrashReplaced(res)
+			}
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				err = ErrNameConflict
+			}
+			return nil, fmt.Errorf("copy %s: %w", batch[0].Path, err)
+		}
+		rootID = ids[subtree[0].ID]
+		if progress != nil {
+			progress(start + len(batch))
+		}
+	}
+	return s.files.GetFile(userID, rootID)
+}
+
+// copyObject 为旧记录复制存储对象；存储不支持服务端复制时返回原路径，由 storage_path 引用计数共用
+func (s *CopyService) copyObject(userID uint, src *model.File) (string, error) {
+	copier, ok := s.files.storage.(storage.Copier)
+	if !ok {
+		return src.StoragePath, nil
+	}
+	suffix, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	dst := fmt.Sprintf("files/%d/%s-%s%s", userID, src.Hash, suffix[:8], filepath.Ext(src.Name))
+	if err := copier.Copy(src.StoragePath, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCopyReservesQuota(t *testing.T) {
+	files, user := newTestFileService(t)
+	c := NewCopyService(files, NewJobService(files.db))
+	src, err := files.CreateFolder(user.ID, "src", 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeText(t, files, user.ID, src.ID, "a.txt", "hello")
+	storeText(t, files, user.ID, src.ID, "b.txt", "world")
+	if err := files.db.Model(user).Update("quota_bytes", 15).Error; err != nil {
+		t.Fatal(err)
+	}
+	subtree, err := files.collectSubtree(user.ID, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 开始前的检查之后空间被占用：写入时预留失败，整个复制撤销
+	if _, err := c.copySubtree(user.ID, subtree, 0, "dst", &conflictResolution{name: "dst"}, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("copy: err = %v, want ErrQuotaExceeded", err)
+	}
+	wantUsedBytes(t, files, user.ID, 10)
+	if _, err := files.fileRepo.FindChildByName(user.ID, 0, "dst"); err == nil {
+		t.Error("partial copy left behind")
+	}
+
+	if err := files.db.Model(user).Update("quota_bytes", 20).Error; err != nil {
+		t.Fatal(err)
+	}
+	copied, _, err := c.Copy(user.ID, src.ID, "dst", nil, ConflictFail, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 20)
+	wantFolderStats(t, files, copied.ID, folderStats{Size: 10, Files: 2})
+}
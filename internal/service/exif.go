@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// EXIF 标签号
+const (
+	exifMake             = 0x010F
+	exifModel            = 0x0110
+	exifOrientation      = 0x0112
+	exifDateTime         = 0x0132
+	exifIFDPointer       = 0x8769
+	exifGPSPointer       = 0x8825
+	exifDateTimeOriginal = 0x9003
+	exifDateDigitized    = 0x9004
+	exifOffsetOriginal   = 0x9011
+	gpsLatitudeRef       = 0x0001
+	gpsLatitude          = 0x0002
+	gpsLongitudeRef      = 0x0003
+	gpsLongitude         = 0x0004
+)
+
+const maxIFDEntries = 512 // 单个 IFD 的条目数上限，避免损坏的数据导致大量循环
+
+// exifInfo 从 EXIF 中读取的信息，缺少的字段为零值
+type exifInfo struct {
+	orientation int // 1–8，没有时为 1
+	make, model string
+	takenAt     *time.Time
+	takenLocal  bool // takenAt 没有时区，为以 UTC 形式表示的当地时间
+	latitude    *float64
+	longitude   *float64
+}
+
+// tiffEntry IFD 中的一个条目，value 为值的原始字节
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffTypeSizes TIFF 各数据类型每个值的字节数
+var tiffTypeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// parseExif 解析 TIFF 格式的 EXIF 数据：第一个 IFD 中的方向、相机与时间，Exif IFD 中的拍摄时间，
+// GPS IFD 中的经纬度。数据为空或无法解析时返回只有默认方向的结果
+func parseExif(data []byte) *exifInfo {
+	info := &exifInfo{orientation: 1}
+	if len(data) < 8 {
+		return info
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return info
+	}
+	ifd0 := readIFD(data, order, order.Uint32(data[4:]))
+
+	if e, ok := ifd0[exifOrientation]; ok {
+		if v, ok := e.number(order); ok && v >= 1 && v <= 8 {
+			info.orientation = int(v)
+		}
+	}
+	info.make = ifd0[exifMake].text()
+	info.model = ifd0[exifModel].text()
+
+	var exif map[uint16]tiffEntry
+	if e, ok := ifd0[exifIFDPointer]; ok {
+		if offset, ok := e.number(order); ok {
+			exif = readIFD(data, order, offset)
+		}
+	}
+	offset := exif[exifOffsetOriginal].text()
+	for _, e := range []tiffEntry{exif[exifDateTimeOriginal], exif[exifDateDigitized], ifd0[exifDateTime]} {
+		if t, zoned, ok := exifTime(e.text(), offset); ok {
+			info.takenAt, info.takenLocal = &t, !zoned
+			break
+		}
+	}
+
+	if e, ok := ifd0[exifGPSPointer]; ok {
+		if offset, ok := e.number(order); ok {
+			gps := readIFD(data, order, offset)
+			lat, latOK := gpsCoordinate(gps[gpsLatitude], gps[gpsLatitudeRef].text(), "S", order)
+			lon, lonOK := gpsCoordinate(gps[gpsLongitude], gps[gpsLongitudeRef].text(), "W", order)
+			if latOK && lonOK && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && (lat != 0 || lon != 0) {
+				info.latitude, info.longitude = &lat, &lon
+			}
+		}
+	}
+	return info
+}
+
+// readIFD 读取 offset 处的 IFD，返回按标签号索引的条目；超出数据范围的条目被忽略
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) map[uint16]tiffEntry {
+	entries := make(map[uint16]tiffEntry)
+	if offset < 8 || uint64(offset)+2 > uint64(len(data)) {
+		return entries
+	}
+	count := int(order.Uint16(data[offset:]))
+	for i := 0; i < count && i < maxIFDEntries; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(data) {
+			break
+		}
+		e := tiffEntry{typ: order.Uint16(data[pos+2:]), count: order.Uint32(data[pos+4:])}
+		size, ok := tiffTypeSizes[e.typ]
+		if !ok || e.count == 0 || uint64(e.count)*uint64(size) > uint64(len(data)) {
+			continue
+		}
+		n := e.count * size
+		if n <= 4 {
+			e.value = data[pos+8 : pos+8+int(n)] // 不超过 4 字节的值直接存放在条目中
+		} else {
+			start := order.Uint32(data[pos+8:])
+			if uint64(start)+uint64(n) > uint64(len(data)) {
+				continue
+			}
+			e.value = data[start : start+n]
+		}
+		entries[order.Uint16(data[pos:])] = e
+	}
+	return entries
+}
+
+// number 读取 SHORT 或 LONG 类型条目的第一个值
+func (e tiffEntry) number(order binary.ByteOrder) (uint32, bool) {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return uint32(order.Uint16(e.value)), true
+	case e.typ == 4 && len(e.value) >= 4:
+		return order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// text 读取 ASCII 类型条目的文本，去掉结尾的 NUL 与空白
+func (e tiffEntry) text() string {
+	if e.typ != 2 {
+		return ""
+	}
+	if i := bytes.IndexByte(e.value, 0); i >= 0 {
+		e.value = e.value[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(e.value), ""))
+}
+
+// exifTime 解析 "2006:01:02 15:04:05" 格式的时间，offset 为 "+08:00" 形式的时区。
+// zoned 表示时间带有时区；没有时区时返回以 UTC 形式表示的当地时间
+func exifTime(value, offset string) (t time.Time, zoned, ok bool) {
+	if len(value) < 19 || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false, false
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value[:19]+offset); err == nil {
+			return t, true, true
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", value[:19])
+	return t, false, err == nil
+}
+
+// gpsCoordinate 将度、分、秒三个 RATIONAL 值换算为十进制度数，ref 为 negRef（S 或 W）时取负
+func gpsCoordinate(e tiffEntry, ref, negRef string, order binary.ByteOrder) (float64, bool) {
+	if e.typ != 5 || len(e.value) < 24 {
+		return 0, false
+	}
+	var parts [3]float64
+	for i := range parts {
+		num := order.Uint32(e.value[i*8:])
+		den := order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			if num != 0 {
+				return 0, false
+			}
+			continue
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	v := parts[0] + parts[1]/60 + parts[2]/3600
+	if ref == negRef {
+		v = -v
+	}
+	return v, true
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported archive format, expected .zip, .tar, .tar.gz or .tgz")
+	ErrUnsafeArchivePath  = errors.New("archive contains an unsafe path")
+	ErrArchiveTooLarge    = errors.New("archive exceeds the extraction limits")
+)
+
+// extractProgressStep 每处理多少项更新一次任务进度
+const extractProgressStep = 50
+
+// ExtractLimits 解压限制，防止压缩炸弹
+type ExtractLimits struct {
+	MaxEntries int   // 最多条目数（文件和文件夹）
+	MaxBytes   int64 // 解压后的总字节数上限
+}
+
+// ExtractService 在服务端解压已上传的压缩包
+type ExtractService struct {
+	files  *FileService
+	jobs   *JobService
+	limits ExtractLimits
+}
+
+func NewExtractService(files *FileService, jobs *JobService, limits ExtractLimits) *ExtractService {
+	return &ExtractService{files: files, jobs: jobs, limits: limits}
+}
+
+// archiveItem 压缩包中的一项
+type archiveItem struct {
+	parts []string // 清理后的相对路径分段
+	isDir bool
+	size  int64
+}
+
+// archiveWalker 依次访问压缩包中的每一项；open 为文件内容，文件夹为 nil
+type archiveWalker func(fn func(item archiveItem, open openFunc) error) error
+
+// Extract 将压缩包 fileID 解压到 targetParentID 下；targetParentID 为 nil 时在压缩包所在目录
+// 创建与压缩包同名的文件夹作为目标。解压作为后台任务执行，先整体检查路径与大小限制，
+// 通过后才开始创建文件；任务结果为目标文件夹ID
+func (s *ExtractService) Extract(userID, fileID uint, targetParentID *uint, policy ConflictPolicy) (*model.Job, error) {
+	fileRepo := s.files.fileRepo
+	source, err := fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	format := archiveFormat(source)
+	if format == "" {
+		return nil, ErrUnsupportedArchive
+	}
+	if targetParentID != nil && *targetParentID != 0 {
+		parent, err := fileRepo.FindByIDAndUser(*targetParentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !parent.IsDir {
+			return nil, ErrNotAFolder
+		}
+	}
+
+	return s.jobs.Start(userID, model.JobTypeExtract, source.ID, 0, func(progress *JobProgress) (uint, error) {
+		walk := s.walker(source, format)
+		total, size, err := s.scan(walk)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.files.checkQuota(userID, size); err != nil {
+			return 0, err
+		}
+		progress.Total(total)
+
+		var targetID uint
+		var created *model.File
+		if targetParentID != nil {
+			targetID = *targetParentID
+		} else {
+			if created, err = s.files.CreateFolder(userID, archiveBaseName(source.Name), source.ParentID, ConflictRename); err != nil {
+				return 0, err
+			}
+			targetID = created.ID
+		}
+
+		if err := s.extract(userID, targetID, policy, walk, progress); err != nil {
+			if created != nil {
+				s.files.DeleteFile(userID, created.ID, true)
+			}
+			return 0, err
+		}
+		return targetID, nil
+	})
+}
+
+// scan 检查所有路径是否安全并统计条目数与解压后大小，超出限制时拒绝解压
+func (s *ExtractService) scan(walk archiveWalker) (int, int64, error) {
+	count := 0
+	var size int64
+	err := walk(func(item archiveItem, _ openFunc) error {
+		count++
+		size += item.size
+		if count > s.limits.MaxEntries || size > s.limits.MaxBytes || item.size < 0 {
+			return ErrArchiveTooLarge
+		}
+		return nil
+	})
+	return count, size, err
+}
+
+// extract 按压缩包中的路径创建文件夹和文件，文件夹沿用已存在的同名文件夹
+func (s *ExtractService) extract(userID, targetID uint, policy ConflictPolicy, walk archiveWalker, progress *JobProgress) error {
+	folders := map[string]uint{"": targetID}
+	ensureDir := func(parts []string) (uint, error) {
+		dir := strings.Join(parts, "/")
+		if id, ok := folders[dir]; ok {
+			return id, nil
+		}
+		id, err := s.files.EnsurePath(userID, targetID, dir)
+		if err != nil {
+			return 0, fmt.Errorf("create folder %s: %w", dir, err)
+		}
+		folders[dir] = id
+		return id, nil
+	}
+
+	done := 0
+	err := walk(func(item archiveItem, open openFunc) error {
+		if item.isDir {
+			if _, err := ensureDir(item.parts); err != nil {
+				return err
+			}
+		} else {
+			parentID, err := ensureDir(item.parts[:len(item.parts)-1])
+			if err != nil {
+				return err
+			}
+			name := item.parts[len(item.parts)-1]
+			if _, err := s.files.storeFile(userID, parentID, name, mime.TypeByExtension(filepath.Ext(name)), policy, open); err != nil {
+				return fmt.Errorf("extract %s: %w", strings.Join(item.parts, "/"), err)
+			}
+		}
+		done++
+		if done%extractProgressStep == 0 {
+			progress.Done(done)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	progress.Done(done)
+	return nil
+}
+
+// walker 按格式返回压缩包的遍历函数，每次调用都从头读取压缩包
+func (s *ExtractService) walker(source *model.File, format string) archiveWalker {
+	if format == "zip" {
+		return func(fn func(archiveItem, openFunc) error) error {
+			return s.walkZip(source, fn)
+		}
+	}
+	return func(fn func(archiveItem, openFunc) error) error {
+		return s.walkTar(source, format == "tgz", fn)
+	}
+}
+
+// walkZip 通过范围读取访问 ZIP 的中央目录和各条目，无需下载整个压缩包。
+// 条目实际解压长度超过中央目录记录的大小时 archive/zip 返回 ErrFormat，因此声明的大小可信
+func (s *ExtractService) walkZip(source *model.File, fn func(archiveItem, openFunc) error) error {
+	reader := storage.NewRangeReader(s.files.storage, source.StoragePath, source.Size)
+	defer reader.Close()
+	zr, err := openZip(reader, source.Size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		isDir := mode.IsDir() || strings.HasSuffix(f.Name, "/")
+		if !isDir && !mode.IsRegular() {
+			continue // 跳过符号链接等特殊文件
+		}
+		parts, err := archiveEntryPath(f.Name)
+		if err != nil {
+			return err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		item := archiveItem{parts: parts, isDir: isDir}
+		var open openFunc
+		if !isDir {
+			if f.UncompressedSize64 > uint64(s.limits.MaxBytes) {
+				return ErrArchiveTooLarge
+			}
+			item.size = int64(f.UncompressedSize64)
+			open = f.Open
+		}
+		if err := fn(item, open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar 顺序读取 tar（可为 gzip 压缩）。条目内容只能读取一次，
+// 而 storeFile 需要读取两遍，因此先暂存到临时文件
+func (s *ExtractService) walkTar(source *model.File, gzipped bool, fn func(archiveItem, openFunc) error) error {
+	tr, closer, err := s.files.openTar(source, gzipped)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for {
+		hdr, err := nextTarHeader(tr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			continue // 跳过链接、设备文件和扩展头
+		}
+		parts, err := archiveEntryPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		item := archiveItem{parts: parts, isDir: hdr.Typeflag == tar.TypeDir, size: hdr.Size}
+		if item.isDir {
+			item.size = 0
+			if err := fn(item, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.withSpooledEntry(tr, hdr.Size, func(open openFunc) error { return fn(item, open) }); err != nil {
+			return err
+		}
+	}
+}
+
+// withSpooledEntry 将当前 tar 条目写入临时文件后调用 fn。fn 不读取内容时（如检查阶段）不写临时文件
+func (s *ExtractService) withSpooledEntry(r io.Reader, size int64, fn func(open openFunc) error) error {
+	var tmpPath string
+	defer func() {
+		if tmpPath != "" {
+			os.Remove(tmpPath)
+		}
+	}()
+	open := func() (io.ReadCloser, error) {
+		if tmpPath == "" {
+			if size > s.limits.MaxBytes {
+				return nil, ErrArchiveTooLarge
+			}
+			tmp, err := os.CreateTemp("", "litedrive-extract-*")
+			if err != nil {
+				return nil, err
+			}
+			tmpPath = tmp.Name()
+			_, err = io.Copy(tmp, r)
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return os.Open(tmpPath)
+	}
+	return fn(open)
+}
+
+// archiveEntryPath 清理压缩包内的路径并拆分为各级名称。
+// 绝对路径、盘符、".." 以及包含非法字符的名称都视为越界（zip-slip），拒绝整个压缩包
+func archiveEntryPath(name string) ([]string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return nil, fmt.Errorf("%w: %q", ErrUnsafeArchivePath, name)
+	}
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		if !validName(part) {
+			return nil, fmt.Errorf("%w: %q", ErrUnsafeArchivePath, name)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// archiveFormat 根据文件名判断压缩包格式，不支持时返回空字符串
+func archiveFormat(file *model.File) string {
+	if file.IsDir {
+		return ""
+	}
+	name := strings.ToLower(file.Name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// archiveBaseName 去掉压缩包扩展名，作为默认的解压目录名
+func archiveBaseName(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveEntryPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   []string
+		unsafe bool
+	}{
+		{name: "a.txt", want: []string{"a.txt"}},
+		{name: "dir/sub/a.txt", want: []string{"dir", "sub", "a.txt"}},
+		{name: "dir/", want: []string{"dir"}},
+		{name: "./dir//a.txt", want: []string{"dir", "a.txt"}},
+		{name: `dir\sub\a.txt`, want: []string{"dir", "sub", "a.txt"}},
+		{name: "", want: nil},
+		{name: "/etc/passwd", unsafe: true},
+		{name: `\windows\system32`, unsafe: true},
+		{name: "C:/evil.txt", unsafe: true},
+		{name: `c:\evil.txt`, unsafe: true},
+		{name: "../evil.txt", unsafe: true},
+		{name: "dir/../../evil.txt", unsafe: true},
+		{name: `dir\..\evil.txt`, unsafe: true},
+		{name: "dir/..", unsafe: true},
+		{name: "bad\x00name", unsafe: true},
+	}
+	for _, tt := range tests {
+		got, err := archiveEntryPath(tt.name)
+		if tt.unsafe {
+			if !errors.Is(err, ErrUnsafeArchivePath) {
+				t.Errorf("archiveEntryPath(%q) error = %v, want ErrUnsafeArchivePath", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("archiveEntryPath(%q) unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("archiveEntryPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
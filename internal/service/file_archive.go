@@ -0,0 +1,150 @@
+package service
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"online-disk-server/internal/model"
+)
+
+// ArchiveSelectionLimit 一次打包下载最多可选择的项数
+const ArchiveSelectionLimit = 1000
+
+var (
+	ErrEmptySelection    = errors.New("no files selected")
+	ErrSelectionTooLarge = fmt.Errorf("at most %d items can be archived at once", ArchiveSelectionLimit)
+)
+
+// Archive 待打包下载的文件集合，写出前已完成全部数据库查询，写出时只读取存储
+type Archive struct {
+	Name    string // 建议的下载文件名
+	files   *FileService
+	entries []archiveEntry
+}
+
+type archiveEntry struct {
+	path string // 压缩包内的相对路径，文件夹以 / 结尾
+	file *model.File
+}
+
+// PrepareArchive 收集所选文件和文件夹（含整个子树，可以是他人授权的）用于打包下载。
+// 每个所选项位于压缩包顶层，保留其下的相对路径；顶层重名时按 "name (n).ext" 区分
+func (s *FileService) PrepareArchive(userID uint, fileIDs []uint) (*Archive, error) {
+	if len(fileIDs) == 0 {
+		return nil, ErrEmptySelection
+	}
+	if len(fileIDs) > ArchiveSelectionLimit {
+		return nil, ErrSelectionTooLarge
+	}
+
+	archive := &Archive{files: s}
+	seen := make(map[uint]bool, len(fileIDs))
+	topNames := make(map[string]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		root, err := s.accessFile(userID, id, false)
+		if err != nil {
+			return nil, err
+		}
+		subtree, err := s.collectSubtree(root.UserID, root)
+		if err != nil {
+			return nil, err
+		}
+
+		paths := make(map[uint]string, len(subtree))
+		paths[root.ID] = archiveTopName(topNames, root.Name)
+		for _, f := range subtree {
+			if f.ID != root.ID {
+				paths[f.ID] = paths[f.ParentID] + "/" + f.Name
+			}
+			entry := archiveEntry{path: paths[f.ID], file: f}
+			if f.IsDir {
+				entry.path += "/"
+			}
+			archive.entries = append(archive.entries, entry)
+		}
+	}
+
+	if len(fileIDs) == 1 {
+		archive.Name = archive.entries[0].file.Name
+		if !archive.entries[0].file.IsDir {
+			archive.Name = strings.TrimSuffix(archive.Name, filepath.Ext(archive.Name))
+		}
+		archive.Name += ".zip"
+	} else {
+		archive.Name = "download.zip"
+	}
+	return archive, nil
+}
+
+// archiveTopName 为顶层项分配不重复的名称
+func archiveTopName(taken map[string]bool, name string) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; taken[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	taken[candidate] = true
+	return candidate
+}
+
+// WriteZip 将压缩包流式写入 w，不使用临时文件。超过 4GB 或 65535 项时自动使用 ZIP64
+func (a *Archive) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, e := range a.entries {
+		header := &zip.FileHeader{
+			Name:     e.path,
+			Modified: e.file.UpdatedAt,
+			Method:   zip.Deflate,
+		}
+		if e.file.IsDir || incompressible(e.file.MimeType) {
+			header.Method = zip.Store
+		}
+		dst, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if e.file.IsDir || e.file.StoragePath == "" {
+			continue
+		}
+		if err := a.copyEntry(dst, e.file); err != nil {
+			return fmt.Errorf("archive %s: %w", e.file.Path, err)
+		}
+	}
+	return zw.Close()
+}
+
+func (a *Archive) copyEntry(dst io.Writer, file *model.File) error {
+	reader, err := a.files.storage.Download(file.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(dst, reader)
+	return err
+}
+
+// incompressible 判断内容是否已压缩，这类文件直接存储以节省 CPU
+func incompressible(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml" && mimeType != "image/bmp",
+		strings.HasPrefix(mimeType, "video/"),
+		strings.HasPrefix(mimeType, "audio/") && mimeType != "audio/wav" && mimeType != "audio/x-wav":
+		return true
+	}
+	switch mimeType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/vnd.rar", "application/x-bzip2", "application/x-xz",
+		"application/zstd", "application/pdf":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidGrant     = errors.New("invalid grant")
+)
+
+// GrantAccess 将用户自己的文件或文件夹授权给 username 对应的用户；已授权时修改角色
+func (s *FileService) GrantAccess(userID, fileID uint, username, role string) (*model.FileGrant, error) {
+	if role != model.GrantRoleViewer && role != model.GrantRoleEditor {
+		return nil, fmt.Errorf("%w: role must be viewer or editor", ErrInvalidGrant)
+	}
+	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	grantee, err := repository.NewUserRepository(s.db).FindByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: user %q not found", ErrInvalidGrant, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if grantee.ID == userID {
+		return nil, fmt.Errorf("%w: cannot grant access to yourself", ErrInvalidGrant)
+	}
+
+	grant, err := s.grantRepo.FindByFileAndGrantee(file.ID, grantee.ID)
+	switch {
+	case err == nil:
+		if grant.Role != role {
+			if err := s.grantRepo.UpdateRole(grant.ID, role); err != nil {
+				return nil, err
+			}
+			grant.Role = role
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		grant = &model.FileGrant{FileID: file.ID, GranteeID: grantee.ID, OwnerID: userID, Role: role}
+		if err := s.grantRepo.Create(grant); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	grant.GranteeName = grantee.Username
+	return grant, nil
+}
+
+// ListGrants 列出用户自己的文件上的授权
+func (s *FileService) ListGrants(userID, fileID uint) ([]*model.FileGrant, error) {
+	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.grantRepo.FindByFile(file.ID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := s.usernames(grants, func(g *model.FileGrant) uint { return g.GranteeID })
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		g.GranteeName = names[g.GranteeID]
+	}
+	return grants, nil
+}
+
+// RevokeGrant 撤销用户自己的文件上的一项授权
+func (s *FileService) RevokeGrant(userID, fileID, grantID uint) error {
+	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return err
+	}
+	grant, err := s.grantRepo.FindByIDAndFile(grantID, file.ID)
+	if err != nil {
+		return err
+	}
+	return s.grantRepo.Delete(grant.ID)
+}
+
+// SharedWithMe 分页列出其他用户授权给 userID 的文件和文件夹
+func (s *FileService) SharedWithMe(userID uint, page, limit int) ([]*model.FileGrant, int64, error) {
+	offset := (page - 1) * limit
+	grants, total, err := s.grantRepo.FindByGrantee(userID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	names, err := s.usernames(grants, func(g *model.FileGrant) uint { return g.OwnerID })
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, g := range grants {
+		g.OwnerName = names[g.OwnerID]
+		if g.File, err = s.fileRepo.FindByIDAndUser(g.FileID, g.OwnerID); err != nil {
+			return nil, 0, err
+		}
+	}
+	return grants, total, nil
+}
+
+func (s *FileService) usernames(grants []*model.FileGrant, userOf func(*model.FileGrant) uint) (map[uint]string, error) {
+	ids := make([]uint, 0, len(grants))
+	for _, g := range grants {
+		ids = append(ids, userOf(g))
+	}
+	users, err := repository.NewUserRepository(s.db).FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+	return names, nil
+}
+
+// accessFile 查找 userID 可以访问的文件：自己的文件，或自身及上级文件夹被授权给该用户的文件。
+// write 为 true 时要求编辑者权限。无权访问时与文件不存在一样返回 gorm.ErrRecordNotFound
+func (s *FileService) accessFile(userID, fileID uint, write bool) (*model.File, error) {
+	file, err := s.fileRepo.FindByID(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.UserID == userID {
+		return file, nil
+	}
+	role, err := s.grantRole(userID, file)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case role == "":
+		return nil, gorm.ErrRecordNotFound
+	case write && role != model.GrantRoleEditor:
+		return nil, ErrPermissionDenied
+	}
+	return file, nil
+}
+
+// grantRole 返回 userID 对他人文件的授权角色，沿上级文件夹查找，多个授权时取最高权限；无授权时返回空字符串
+func (s *FileService) grantRole(userID uint, file *model.File) (string, error) {
+	// 多数用户之间没有授权关系，先确认所有者是否授权过该用户，避免逐级查找上级目录
+	granted, err := s.grantRepo.ExistsForOwner(file.UserID, userID)
+	if err != nil || !granted {
+		return "", err
+	}
+
+	ids := []uint{file.ID}
+	for current := file; current.ParentID != 0; {
+		parent, err := s.fileRepo.FindByIDAndUser(current.ParentID, file.UserID)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, parent.ID)
+		current = parent
+	}
+	grants, err := s.grantRepo.FindByGranteeAndFiles(userID, ids)
+	if err != nil {
+		return "", err
+	}
+	role := ""
+	for _, g := range grants {
+		if g.Role == model.GrantRoleEditor {
+			return g.Role, nil
+		}
+		role = g.Role
+	}
+	return role, nil
+}
+
+// folderOwner 返回向 parentID 写入时使用的所有者：根目录和自己的文件夹为 userID 本人，
+// 被授予编辑权限的文件夹为其所有者
+func (s *FileService) folderOwner(userID, parentID uint) (uint, error) {
+	if parentID == 0 {
+		return userID, nil
+	}
+	folder, err := s.accessFile(userID, parentID, true)
+	if err != nil {
+		return 0, err
+	}
+	return folder.UserID, nil
+}
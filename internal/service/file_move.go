@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid file name")
+	ErrInvalidMove = errors.New("cannot move a folder into itself or its descendant")
+	ErrNotAFolder  = errors.New("target parent is not a folder")
+)
+
+// MoveFile 重命名和/或移动文件或文件夹。newName 为空表示不改名，newParentID 为 nil 表示不移动。
+// 文件夹移动时同步更新整个子树的 Path；policy 为 skip 且目标已存在时原样返回 file
+func (s *FileService) MoveFile(userID, fileID uint, newName string, newParentID *uint, policy ConflictPolicy) (*model.File, error) {
+	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := file.Name
+	if newName != "" {
+		if !validName(newName) {
+			return nil, ErrInvalidName
+		}
+		name = newName
+	}
+	parentID := file.ParentID
+	if newParentID != nil {
+		parentID = *newParentID
+	}
+	if name == file.Name && parentID == file.ParentID {
+		return file, nil
+	}
+
+	if parentID != 0 {
+		parent, err := s.fileRepo.FindByIDAndUser(parentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !parent.IsDir {
+			return nil, ErrNotAFolder
+		}
+		if file.IsDir {
+			within, err := s.isWithin(userID, file.ID, parent)
+			if err != nil {
+				return nil, err
+			}
+			if within {
+				return nil, ErrInvalidMove
+			}
+		}
+	}
+
+	// 处理重名
+	res, err := s.resolveConflict(userID, parentID, name, file.IsDir, policy, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	if res.skip != nil {
+		return file, nil
+	}
+	if res.replace != nil {
+		return s.moveOnto(file, res.replace)
+	}
+	if res.trash != nil && file.IsDir {
+		// 不能用文件夹覆盖它自身所在的文件夹
+		within, err := s.isWithin(userID, res.trash.ID, file)
+		if err != nil {
+			return nil, err
+		}
+		if within {
+			return nil, ErrInvalidMove
+		}
+	}
+	name = res.name
+
+	newPath, err := s.childPath(userID, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := trashReplaced(tx, res); err != nil {
+			return err
+		}
+		// 在事务中重新读取并锁定，统计按当前值从原上级文件夹移到新上级文件夹，共同的上级一减一加后不变
+		repo := repository.NewFileRepository(tx)
+		current, err := repo.FindByIDForUpdate(file.ID)
+		if err != nil {
+			return err
+		}
+		stats := statsOf(current)
+		if err := addToAncestors(tx, userID, current.Path, stats.neg()); err != nil {
+			return err
+		}
+		subtree, err := collectSubtreeWith(repo, userID, current)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateFields(file.ID, map[string]interface{}{
+			"name":       name,
+			"parent_id":  parentID,
+			"path":       newPath,
+			"updated_at": time.Now(),
+		}); err != nil {
+			return err
+		}
+		if err := rewriteSubtreePaths(tx, subtree, newPath); err != nil {
+			return err
+		}
+		return addToAncestors(tx, userID, newPath, stats)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrNameConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.fileRepo.FindByIDAndUser(file.ID, userID)
+}
+
+// moveOnto 用 file 的内容覆盖同名文件 target：target 保留其ID并把旧内容存为历史版本，
+// file 的历史版本一并转给 target，file 记录随后删除（存储引用转移给 target，无需释放）
+func (s *FileService) moveOnto(file, target *model.File) (*model.File, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		current, err := repository.NewFileRepository(tx).FindByIDForUpdate(file.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.replaceContent(tx, target, current); err != nil {
+			return err
+		}
+		if err := repository.NewVersionRepository(tx).Reassign(file.ID, target.ID); err != nil {
+			return err
+		}
+		if err := addToAncestors(tx, file.UserID, current.Path, statsOf(current).neg()); err != nil {
+			return err
+		}
+		return repository.NewFileRepository(tx).DeleteByIDs([]uint{file.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.pruneVersions(target.UserID, target.ID)
+	return s.fileRepo.FindByIDAndUser(target.ID, target.UserID)
+}
+
+// isWithin 判断 target 是否为 folderID 自身或其子孙
+func (s *FileService) isWithin(userID, folderID uint, target *model.File) (bool, error) {
+	for current := target; ; {
+		if current.ID == folderID {
+			return true, nil
+		}
+		if current.ParentID == 0 {
+			return false, nil
+		}
+		parent, err := s.fileRepo.FindByIDAndUser(current.ParentID, userID)
+		if err != nil {
+			return false, err
+		}
+		current = parent
+	}
+}
+
+// rewriteSubtreePaths 文件夹路径变化后，按层级重新计算其全部子孙的 Path。
+// subtree 为 collectSubtree 的结果（首项为文件夹自身）
+func rewriteSubtreePaths(tx *gorm.DB, subtree []*model.File, newPath string) error {
+	if len(subtree) < 2 {
+		return nil
+	}
+	paths := map[uint]string{subtree[0].ID: newPath}
+	repo := repository.NewFileRepository(tx)
+	for _, f := range subtree[1:] {
+		p := joinPath(paths[f.ParentID], f.Name)
+		paths[f.ID] = p
+		if p == f.Path {
+			continue
+		}
+		if err := repo.UpdateFields(f.ID, map[string]interface{}{"path": p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
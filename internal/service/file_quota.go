@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// 用量按类型统计时的分类
+const (
+	UsageTypeImage    = "image"
+	UsageTypeVideo    = "video"
+	UsageTypeAudio    = "audio"
+	UsageTypeDocument = "document"
+	UsageTypeArchive  = "archive"
+	UsageTypeOther    = "other"
+)
+
+// TypeUsage 某一类文件的数量与占用空间
+type TypeUsage struct {
+	Type  string `json:"type"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// FolderUsage 根目录下一个文件夹（含子目录）的文件数量与占用空间；ID 为 0 表示直接位于根目录的文件
+type FolderUsage struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// StorageUsage 用户的配额与用量明细。used_bytes 按每个文件和历史版本的逻辑大小累计，
+// 内容去重存储不减少用量，进行中的上传预留的配额也计入其中。
+// by_type 与 by_folder 是未删除文件当前内容的两种划分，二者各自的合计相同；
+// 历史版本和回收站单独列出。没有进行中的上传时，by_type 合计 + versions_bytes + trash_bytes 等于 used_bytes
+type StorageUsage struct {
+	QuotaBytes     int64          `json:"quota_bytes"` // 0 表示不限制
+	UsedBytes      int64          `json:"used_bytes"`
+	AvailableBytes *int64         `json:"available_bytes,omitempty"` // 不限制时不返回
+	VersionsBytes  int64          `json:"versions_bytes"`
+	TrashBytes     int64          `json:"trash_bytes"`
+	ByType         []*TypeUsage   `json:"by_type"`
+	ByFolder       []*FolderUsage `json:"by_folder"`
+}
+
+// SetDefaultQuota 设置用户未单独设置配额时使用的默认配额，小于等于 0 表示不限制
+func (s *FileService) SetDefaultQuota(bytes int64) {
+	s.defaultQuota = max(bytes, 0)
+}
+
+// quotaOf 返回用户实际生效的配额，0 表示不限制
+func (s *FileService) quotaOf(user *model.User) int64 {
+	if user.QuotaBytes != nil {
+		return max(*user.QuotaBytes, 0)
+	}
+	return s.defaultQuota
+}
+
+// reserveQuota 在写入存储前为 size 字节预留配额，空间不足时返回 ErrQuotaExceeded。
+// 预留与已用空间的更新是同一条条件更新语句，并发上传不会超出配额；写入失败时调用方需 releaseQuota
+func (s *FileService) reserveQuota(userID uint, size int64) error {
+	return s.reserveQuotaTx(s.db, userID, size)
+}
+
+// reserveQuotaTx 在事务 tx 中预留配额，与同一事务写入的记录一起提交或回滚，无需 releaseQuota
+func (s *FileService) reserveQuotaTx(tx *gorm.DB, userID uint, size int64) error {
+	users := repository.NewUserRepository(tx)
+	user, err := users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	quota := s.quotaOf(user)
+	if quota == 0 {
+		return users.AddUsage(userID, size)
+	}
+	reserved, err := users.ReserveUsage(userID, size, quota)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return quotaError(size, user.UsedBytes, quota)
+	}
+	return nil
+}
+
+// releaseQuota 归还预留但最终未使用的配额，失败只记录日志（启动时会重新统计）
+func (s *FileService) releaseQuota(userID uint, size int64) {
+	if err := s.userRepo.AddUsage(userID, -size); err != nil {
+		log.Printf("release quota of user %d failed: %v", userID, err)
+	}
+}
+
+// checkQuota 预先检查剩余空间是否足够写入 size 字节，不做预留。
+// 用于已知总大小的复制和解压，在开始写入前尽早拒绝；实际写入时仍需预留
+func (s *FileService) checkQuota(userID uint, size int64) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	quota := s.quotaOf(user)
+	if quota > 0 && user.UsedBytes+size > quota {
+		return quotaError(size, user.UsedBytes, quota)
+	}
+	return nil
+}
+
+func quotaError(size, used, quota int64) error {
+	return fmt.Errorf("%w: %d bytes needed, %d of %d bytes available", ErrQuotaExceeded, size, max(quota-used, 0), quota)
+}
+
+// usageDelta 累计一次删除中各用户释放的空间，在同一事务中扣减
+type usageDelta map[uint]int64
+
+func (d usageDelta) apply(tx *gorm.DB) error {
+	repo := repository.NewUserRepository(tx)
+	for userID, bytes := range d {
+		if err := repo.AddUsage(userID, -bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecalculateUsage 按现有文件、历史版本与未完成上传会话的预留重新统计所有用户的已用空间，
+// 用于启动时修正异常退出留下的预留，并为升级前的旧数据补齐用量
+func (s *FileService) RecalculateUsage() error {
+	return s.userRepo.RecalculateUsage()
+}
+
+// Usage 返回用户的配额与用量明细
+func (s *FileService) Usage(userID uint) (*StorageUsage, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage := &StorageUsage{QuotaBytes: s.quotaOf(user), UsedBytes: user.UsedBytes}
+	if usage.QuotaBytes > 0 {
+		available := max(usage.QuotaBytes-usage.UsedBytes, 0)
+		usage.AvailableBytes = &available
+	}
+
+	versions, err := s.versionRepo.SumByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage.VersionsBytes = versions.Bytes
+	trash, err := s.fileRepo.SumTrash(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage.TrashBytes = trash.Bytes
+
+	if usage.ByType, err = s.usageByType(userID); err != nil {
+		return nil, err
+	}
+	if usage.ByFolder, err = s.usageByFolder(userID); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// usageByType 按文件分类统计，固定返回全部分类
+func (s *FileService) usageByType(userID uint) ([]*TypeUsage, error) {
+	sums, err := s.fileRepo.SumByMimeType(userID)
+	if err != nil {
+		return nil, err
+	}
+	types := []*TypeUsage{
+		{Type: UsageTypeImage}, {Type: UsageTypeVideo}, {Type: UsageTypeAudio},
+		{Type: UsageTypeDocument}, {Type: UsageTypeArchive}, {Type: UsageTypeOther},
+	}
+	index := make(map[string]*TypeUsage, len(types))
+	for _, t := range types {
+		index[t.Type] = t
+	}
+	for _, sum := range sums {
+		t := index[usageType(sum.MimeType)]
+		t.Files += sum.Files
+		t.Bytes += sum.Bytes
+	}
+	return types, nil
+}
+
+// usageByFolder 按根目录下的文件夹统计，按占用空间从大到小排列；直接位于根目录的文件记为 ID 0
+func (s *FileService) usageByFolder(userID uint) ([]*FolderUsage, error) {
+	folders, err := s.fileRepo.FindRootFolders(userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*FolderUsage, 0, len(folders)+1)
+	rootFiles, err := s.fileRepo.SumInParent(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rootFiles.Files > 0 {
+		result = append(result, &FolderUsage{Files: rootFiles.Files, Bytes: rootFiles.Bytes})
+	}
+	for _, folder := range folders {
+		result = append(result, &FolderUsage{ID: folder.ID, Name: folder.Name, Files: folder.FileCount, Bytes: folder.Size})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	return result, nil
+}
+
+// usageType 根据 MIME 类型归类
+func usageType(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return UsageTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return UsageTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return UsageTypeAudio
+	case strings.HasPrefix(mimeType, "text/"), mimeType == "application/pdf", mimeType == "application/rtf",
+		mimeType == "application/msword", strings.HasPrefix(mimeType, "application/vnd.ms-"),
+		strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument."):
+		return UsageTypeDocument
+	case mimeType == "application/zip", mimeType == "application/x-tar", mimeType == "application/gzip",
+		mimeType == "application/x-gzip", mimeType == "application/x-7z-compressed", mimeType == "application/x-bzip2",
+		mimeType == "application/x-xz", mimeType == "application/vnd.rar", mimeType == "application/x-rar-compressed":
+		return UsageTypeArchive
+	}
+	return UsageTypeOther
+}
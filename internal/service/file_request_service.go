@@ -0,0 +1,270 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidFileRequest  = errors.New("invalid file request options")
+	ErrFileRequestClosed   = errors.New("file request is closed")
+	ErrFileTooLarge        = errors.New("file exceeds the size limit of this file request")
+	ErrExtensionNotAllowed = errors.New("file type is not accepted by this file request")
+	ErrInvalidUploader     = errors.New("invalid uploader name or email")
+)
+
+// FileRequestOptions 创建文件收集链接时的说明与限制
+type FileRequestOptions struct {
+	Title             string
+	Message           string
+	MaxFileSize       int64
+	MaxFiles          int
+	AllowedExtensions []string
+	Deadline          *time.Time
+}
+
+// Uploader 上传者自愿填写的信息
+type Uploader struct {
+	Name  string
+	Email string
+}
+
+// PublicFileRequest 通过链接公开的文件收集信息，不包含目标文件夹及其内容
+type PublicFileRequest struct {
+	Title             string     `json:"title"`
+	Message           string     `json:"message,omitempty"`
+	OwnerName         string     `json:"owner_name"`
+	MaxFileSize       int64      `json:"max_file_size"`
+	MaxFiles          int        `json:"max_files"`
+	RemainingFiles    *int       `json:"remaining_files,omitempty"` // 不限制文件数时不返回
+	AllowedExtensions []string   `json:"allowed_extensions"`
+	Deadline          *time.Time `json:"deadline,omitempty"`
+}
+
+// FileRequestService 管理文件收集链接，并在不登录的情况下接收上传
+type FileRequestService struct {
+	db      *gorm.DB
+	reqRepo *repository.FileRequestRepository
+	files   *FileService
+}
+
+func NewFileRequestService(db *gorm.DB, files *FileService) *FileRequestService {
+	return &FileRequestService{
+		db:      db,
+		reqRepo: repository.NewFileRequestRepository(db),
+		files:   files,
+	}
+}
+
+// Create 为用户自己的文件夹创建文件收集链接
+func (s *FileRequestService) Create(userID, folderID uint, opts FileRequestOptions) (*model.FileRequest, error) {
+	folder, err := s.files.fileRepo.FindByIDAndUser(folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !folder.IsDir {
+		return nil, ErrNotAFolder
+	}
+	switch {
+	case opts.MaxFileSize < 0:
+		return nil, fmt.Errorf("%w: max_file_size must not be negative", ErrInvalidFileRequest)
+	case opts.MaxFiles < 0:
+		return nil, fmt.Errorf("%w: max_files must not be negative", ErrInvalidFileRequest)
+	case opts.Deadline != nil && !opts.Deadline.After(time.Now()):
+		return nil, fmt.Errorf("%w: deadline must be in the future", ErrInvalidFileRequest)
+	case utf8.RuneCountInString(opts.Title) > 255 || utf8.RuneCountInString(opts.Message) > 1024:
+		return nil, fmt.Errorf("%w: title or message too long", ErrInvalidFileRequest)
+	}
+	exts, err := normalizeExtensions(opts.AllowedExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	title := strings.TrimSpace(opts.Title)
+	if title == "" {
+		title = folder.Name
+	}
+	req := &model.FileRequest{
+		Token:             token,
+		UserID:            userID,
+		FolderID:          folder.ID,
+		Title:             title,
+		Message:           strings.TrimSpace(opts.Message),
+		MaxFileSize:       opts.MaxFileSize,
+		MaxFiles:          opts.MaxFiles,
+		AllowedExtensions: exts,
+		Deadline:          opts.Deadline,
+	}
+	if err := s.reqRepo.Create(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// List 分页列出用户创建的文件收集链接
+func (s *FileRequestService) List(userID uint, page, limit int) ([]*model.FileRequest, int64, error) {
+	offset := (page - 1) * limit
+	return s.reqRepo.FindByUser(userID, offset, limit)
+}
+
+// ListUploads 分页列出通过文件收集链接收到的文件及上传者信息
+func (s *FileRequestService) ListUploads(userID, requestID uint, page, limit int) ([]*model.FileRequestUpload, int64, error) {
+	req, err := s.reqRepo.FindByIDAndUser(requestID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * limit
+	return s.reqRepo.FindUploads(req.ID, offset, limit)
+}
+
+// Delete 关闭并删除文件收集链接，已收到的文件保留在目标文件夹中
+func (s *FileRequestService) Delete(userID, requestID uint) error {
+	req, err := s.reqRepo.FindByIDAndUser(requestID, userID)
+	if err != nil {
+		return err
+	}
+	return s.reqRepo.Delete(req.ID)
+}
+
+// Open 按 token 查找仍可上传的文件收集链接：未过截止时间、未收满，且目标文件夹仍然存在
+func (s *FileRequestService) Open(token string) (*model.FileRequest, error) {
+	req, err := s.reqRepo.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if req.Deadline != nil && !req.Deadline.After(time.Now()) {
+		return nil, ErrFileRequestClosed
+	}
+	if req.MaxFiles > 0 && req.UploadCount >= req.MaxFiles {
+		return nil, ErrFileRequestClosed
+	}
+	if _, err := s.files.fileRepo.FindByIDAndUser(req.FolderID, req.UserID); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// PublicInfo 返回展示给上传者的说明与限制
+func (s *FileRequestService) PublicInfo(req *model.FileRequest) (*PublicFileRequest, error) {
+	owner, err := repository.NewUserRepository(s.db).FindByIDs([]uint{req.UserID})
+	if err != nil {
+		return nil, err
+	}
+	info := &PublicFileRequest{
+		Title:             req.Title,
+		Message:           req.Message,
+		MaxFileSize:       req.MaxFileSize,
+		MaxFiles:          req.MaxFiles,
+		AllowedExtensions: req.AllowedExtensions,
+		Deadline:          req.Deadline,
+	}
+	if len(owner) > 0 {
+		info.OwnerName = owner[0].Username
+	}
+	if info.AllowedExtensions == nil {
+		info.AllowedExtensions = []string{}
+	}
+	if req.MaxFiles > 0 {
+		remaining := max(req.MaxFiles-req.UploadCount, 0)
+		info.RemainingFiles = &remaining
+	}
+	return info, nil
+}
+
+// Upload 通过文件收集链接上传文件。文件归文件夹所有者，重名时自动重命名，不会覆盖已有文件
+func (s *FileRequestService) Upload(req *model.FileRequest, file *multipart.FileHeader, uploader Uploader) (*model.FileRequestUpload, error) {
+	uploader.Name = strings.TrimSpace(uploader.Name)
+	uploader.Email = strings.TrimSpace(uploader.Email)
+	if utf8.RuneCountInString(uploader.Name) > 100 {
+		return nil, ErrInvalidUploader
+	}
+	if uploader.Email != "" {
+		if addr, err := mail.ParseAddress(uploader.Email); err != nil || addr.Address != uploader.Email {
+			return nil, ErrInvalidUploader
+		}
+	}
+	if req.MaxFileSize > 0 && file.Size > req.MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	if !extensionAllowed(req.AllowedExtensions, file.Filename) {
+		return nil, ErrExtensionNotAllowed
+	}
+
+	reserved, err := s.reqRepo.Reserve(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, ErrFileRequestClosed
+	}
+	stored, err := s.files.UploadFile(req.UserID, file, req.FolderID, ConflictRename)
+	if err != nil {
+		s.reqRepo.Release(req.ID)
+		return nil, err
+	}
+
+	upload := &model.FileRequestUpload{
+		RequestID:     req.ID,
+		FileID:        stored.ID,
+		FileName:      stored.Name,
+		Size:          stored.Size,
+		UploaderName:  uploader.Name,
+		UploaderEmail: uploader.Email,
+	}
+	if err := s.reqRepo.CreateUpload(upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// normalizeExtensions 统一扩展名格式为小写并以 . 开头，去除重复项
+func normalizeExtensions(exts []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if len(ext) > 32 || strings.ContainsAny(ext, `/\ `) {
+			return nil, fmt.Errorf("%w: invalid extension %q", ErrInvalidFileRequest, ext)
+		}
+		if !seen[ext] {
+			seen[ext] = true
+			result = append(result, ext)
+		}
+	}
+	return result, nil
+}
+
+// extensionAllowed 判断文件名是否以允许的扩展名结尾（不区分大小写），支持 ".tar.gz" 这类多段扩展名
+func extensionAllowed(allowed []string, name string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	name = strings.ToLower(filepath.Base(name))
+	for _, ext := range allowed {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
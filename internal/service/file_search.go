@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+)
+
+var ErrInvalidSearch = errors.New("invalid search parameters")
+
+// 名称匹配方式
+const (
+	MatchSubstring = "substring"
+	MatchPrefix    = "prefix"
+	MatchGlob      = "glob" // 支持 * 与 ?，需匹配完整名称
+)
+
+// SearchOptions 搜索条件，零值字段表示不限制
+type SearchOptions struct {
+	Query         string
+	Match         string   // substring（默认）、prefix 或 glob
+	MimeTypes     []string // 如 image/png，或以 /* 结尾表示一类，如 image/*
+	Extensions    []string // 如 jpg 或 .jpg
+	MinSize       *int64
+	MaxSize       *int64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	FolderID      uint   // 仅搜索该文件夹下（递归），0 表示全部
+	Type          string // file、folder，空表示两者
+	Sort          string // name、size、created_at、updated_at（默认）
+	Order         string // asc 或 desc（默认）
+
+	// 媒体元数据条件，指定任一项时只返回已提取到相应元数据的文件
+	Media       string // image、audio、video 或 document
+	Camera      string // 相机厂商或型号包含该文本
+	Artist      string // 艺术家包含该文本
+	Album       string // 专辑包含该文本
+	TakenAfter  *time.Time
+	TakenBefore *time.Time
+	HasLocation *bool // 是否带有拍摄位置
+}
+
+// Search 按名称与元数据搜索文件和文件夹，不含回收站中的项。
+// 指定的文件夹可以是他人授权给该用户的文件夹，此时在其所有者的文件中搜索
+func (s *FileService) Search(userID uint, opts SearchOptions, page, limit int) ([]*model.File, int64, error) {
+	filter, err := searchFilter(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	ownerID := userID
+	if opts.FolderID != 0 {
+		folder, err := s.accessFile(userID, opts.FolderID, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !folder.IsDir {
+			return nil, 0, ErrNotAFolder
+		}
+		ownerID = folder.UserID
+		// 按路径前缀匹配子孙；旧版本留下的错误路径在启动时已按 parent_id 修复（见 EnsureFolderStats）
+		filter.UnderPath = folder.Path + "/"
+	}
+	offset := (page - 1) * limit
+	return s.fileRepo.Search(ownerID, filter, offset, limit)
+}
+
+// EnsureSearchIndexes 创建搜索使用的索引
+func (s *FileService) EnsureSearchIndexes() error {
+	return s.fileRepo.EnsureSearchIndexes()
+}
+
+// searchFilter 校验搜索条件并转换为查询条件
+func searchFilter(opts SearchOptions) (repository.FileSearch, error) {
+	filter := repository.FileSearch{
+		MinSize:       opts.MinSize,
+		MaxSize:       opts.MaxSize,
+		CreatedAfter:  opts.CreatedAfter,
+		CreatedBefore: opts.CreatedBefore,
+		UpdatedAfter:  opts.UpdatedAfter,
+		UpdatedBefore: opts.UpdatedBefore,
+		TakenAfter:    utcTime(opts.TakenAfter),
+		TakenBefore:   utcTime(opts.TakenBefore),
+		HasLocation:   opts.HasLocation,
+		OrderBy:       "updated_at",
+		Desc:          true,
+	}
+
+	query := strings.TrimSpace(opts.Query)
+	if len(query) > 255 {
+		return filter, fmt.Errorf("%w: query too long", ErrInvalidSearch)
+	}
+	switch opts.Match {
+	case "", MatchSubstring:
+		if query != "" {
+			filter.NamePattern = "%" + escapeLike(query) + "%"
+		}
+	case MatchPrefix:
+		if query != "" {
+			filter.NamePattern = escapeLike(query) + "%"
+		}
+	case MatchGlob:
+		if query != "" {
+			filter.NamePattern = globToLike(query)
+		}
+	default:
+		return filter, fmt.Errorf("%w: match must be substring, prefix or glob", ErrInvalidSearch)
+	}
+
+	for _, m := range opts.MimeTypes {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch {
+		case m == "":
+		case strings.HasSuffix(m, "/*") && !strings.ContainsAny(m[:len(m)-2], "/%_\\*"):
+			filter.MimePrefixes = append(filter.MimePrefixes, m[:len(m)-1])
+		case strings.Count(m, "/") == 1 && !strings.ContainsAny(m, "*"):
+			filter.MimeTypes = append(filter.MimeTypes, m)
+		default:
+			return filter, fmt.Errorf("%w: invalid mime type %q", ErrInvalidSearch, m)
+		}
+	}
+	for _, ext := range opts.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if len(ext) > 32 || strings.ContainsAny(ext, `/\ `) {
+			return filter, fmt.Errorf("%w: invalid extension %q", ErrInvalidSearch, ext)
+		}
+		filter.Extensions = append(filter.Extensions, escapeLike(ext))
+	}
+
+	switch {
+	case opts.MinSize != nil && *opts.MinSize < 0, opts.MaxSize != nil && *opts.MaxSize < 0:
+		return filter, fmt.Errorf("%w: size must not be negative", ErrInvalidSearch)
+	case opts.MinSize != nil && opts.MaxSize != nil && *opts.MinSize > *opts.MaxSize:
+		return filter, fmt.Errorf("%w: min_size is greater than max_size", ErrInvalidSearch)
+	case opts.CreatedAfter != nil && opts.CreatedBefore != nil && !opts.CreatedAfter.Before(*opts.CreatedBefore),
+		opts.UpdatedAfter != nil && opts.UpdatedBefore != nil && !opts.UpdatedAfter.Before(*opts.UpdatedBefore),
+		opts.TakenAfter != nil && opts.TakenBefore != nil && !opts.TakenAfter.Before(*opts.TakenBefore):
+		return filter, fmt.Errorf("%w: empty date range", ErrInvalidSearch)
+	}
+
+	switch opts.Media {
+	case "", model.MediaImage, model.MediaAudio, model.MediaVideo, model.MediaDocument:
+		filter.MediaKind = opts.Media
+	default:
+		return filter, fmt.Errorf("%w: media must be image, audio, video or document", ErrInvalidSearch)
+	}
+	for _, f := range []struct {
+		value  string
+		target *string
+	}{{opts.Camera, &filter.CameraPattern}, {opts.Artist, &filter.ArtistPattern}, {opts.Album, &filter.AlbumPattern}} {
+		if v := strings.TrimSpace(f.value); v != "" {
+			if len(v) > 255 {
+				return filter, fmt.Errorf("%w: query too long", ErrInvalidSearch)
+			}
+			*f.target = "%" + escapeLike(v) + "%"
+		}
+	}
+
+	switch opts.Type {
+	case "":
+	case "file", "folder":
+		isDir := opts.Type == "folder"
+		filter.IsDir = &isDir
+	default:
+		return filter, fmt.Errorf("%w: type must be file or folder", ErrInvalidSearch)
+	}
+
+	switch opts.Sort {
+	case "":
+	case "name", "size", "created_at", "updated_at":
+		filter.OrderBy = opts.Sort
+	default:
+		return filter, fmt.Errorf("%w: sort must be name, size, created_at or updated_at", ErrInvalidSearch)
+	}
+	switch opts.Order {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return filter, fmt.Errorf("%w: order must be asc or desc", ErrInvalidSearch)
+	}
+	return filter, nil
+}
+
+// likeEscaper 转义 LIKE 模式中的特殊字符，转义符为 \
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// globToLike 将 glob（* 匹配任意个字符，? 匹配一个字符）转换为 LIKE 模式
+func globToLike(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		default:
+			b.WriteString(escapeLike(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// utcTime 转换为 UTC，与媒体元数据中存储的拍摄时间一致
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
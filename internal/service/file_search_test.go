@@ -0,0 +1,33 @@
+package service
+
+import "testing"
+
+func TestSearchFolderFindsRepairedLegacyFile(t *testing.T) {
+	s, user := newTestFileService(t)
+	a, err := s.CreateFolder(user.ID, "a", 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.CreateFolder(user.ID, "b", a.ID, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := storeText(t, s, user.ID, b.ID, "report.txt", "quarterly numbers")
+	// 模拟旧版本上传的文件：位于子文件夹中，路径却是 "/名称"
+	if err := s.fileRepo.UpdateFields(file.ID, map[string]interface{}{"path": "/report.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnsureFolderStats(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, folderID := range []uint{a.ID, b.ID} {
+		files, total, err := s.Search(user.ID, SearchOptions{Query: "report", FolderID: folderID}, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(files) != 1 || files[0].ID != file.ID {
+			t.Errorf("search in folder %d = %d results, want %s", folderID, total, file.Name)
+		}
+	}
+}
@@ -2,161 +2,506 @@ package service
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"online-disk-server/internal/model"
 	"online-disk-server/internal/repository"
 	"online-disk-server/internal/storage"
 
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
+var ErrNotAFile = errors.New("folders cannot be downloaded")
+
 type FileService struct {
-	db       *gorm.DB
-	fileRepo *repository.FileRepository
-	storage  storage.Storage
+	db          *gorm.DB
+	fileRepo    *repository.FileRepository
+	versionRepo *repository.VersionRepository
+	grantRepo   *repository.GrantRepository
+	userRepo    *repository.UserRepository
+	contentRepo *repository.ContentIndexRepository
+	thumbRepo   *repository.ThumbnailRepository
+	imageRepo   *repository.ImageVariantRepository
+	metaRepo    *repository.MediaMetadataRepository
+	blobs       *BlobService
+	storage     storage.Storage
+	conflict    ConflictPolicy
+
+	versionDefaults model.VersionPolicy // 用户未设置时的版本保留策略
+	defaultQuota    int64               // 用户未设置时的存储配额，0 表示不限制
+	contentLimits   ContentIndexLimits  // 全文索引的大小限制
+	contentWake     chan struct{}       // 唤醒后台全文索引
+	metaWake        chan struct{}       // 唤醒后台媒体元数据提取
+	thumbQueue      chan uint           // 待生成缩略图的对象
+	imageLimits     ImageLimits         // 图片变换的尺寸与缓存限制
+	imageSlots      chan struct{}       // 限制同时解码的图片数
+	imageFlight     singleflight.Group  // 合并同一内容的并发生成
 }
 
 func NewFileService(db *gorm.DB, storage storage.Storage) *FileService {
 	return &FileService{
-		db:       db,
-		fileRepo: repository.NewFileRepository(db),
-		storage:  storage,
+		db:          db,
+		fileRepo:    repository.NewFileRepository(db),
+		versionRepo: repository.NewVersionRepository(db),
+		grantRepo:   repository.NewGrantRepository(db),
+		userRepo:    repository.NewUserRepository(db),
+		contentRepo: repository.NewContentIndexRepository(db),
+		thumbRepo:   repository.NewThumbnailRepository(db),
+		imageRepo:   repository.NewImageVariantRepository(db),
+		metaRepo:    repository.NewMediaMetadataRepository(db),
+		blobs:       NewBlobService(db, storage),
+		storage:     storage,
+		conflict:    ConflictFail,
+		contentWake: make(chan struct{}, 1),
+		metaWake:    make(chan struct{}, 1),
+		thumbQueue:  make(chan uint, thumbnailQueueSize),
+		imageSlots:  make(chan struct{}, maxImageJobs),
 	}
 }
 
-// UploadFile 上传文件
-func (s *FileService) UploadFile(userID uint, file *multipart.FileHeader, parentID uint) (*model.File, error) {
-	// 打开上传的文件
-	src, err := file.Open()
+// SetConflictPolicy 设置请求未指定时使用的默认重名处理策略
+func (s *FileService) SetConflictPolicy(policy ConflictPolicy) {
+	s.conflict = policy
+}
+
+// ConflictPolicy 返回默认重名处理策略
+func (s *FileService) ConflictPolicy() ConflictPolicy {
+	return s.conflict
+}
+
+// UploadFile 上传文件，policy 为同名文件已存在时的处理方式。
+// 上传到被授予编辑权限的文件夹时，文件归该文件夹的所有者
+func (s *FileService) UploadFile(userID uint, file *multipart.FileHeader, parentID uint, policy ConflictPolicy) (*model.File, error) {
+	ownerID, err := s.folderOwner(userID, parentID)
 	if err != nil {
 		return nil, err
 	}
-	defer src.Close()
+	baseName := filepath.Base(file.Filename) // 避免包含相对路径
+	open := func() (io.ReadCloser, error) { return file.Open() }
+	return s.storeFile(ownerID, parentID, baseName, file.Header.Get("Content-Type"), policy, open)
+}
+
+// openFunc 打开待保存内容的读取流。内容需要读取两遍（先计算哈希、再写入存储），
+// 因此每次调用都必须返回一个从头开始的新流
+type openFunc func() (io.ReadCloser, error)
+
+// storeFile 计算内容哈希、写入存储并创建文件记录，供各类上传方式共用
+func (s *FileService) storeFile(userID, parentID uint, name, mimeType string, policy ConflictPolicy, open openFunc) (*model.File, error) {
+	return s.storeReserved(userID, parentID, name, mimeType, policy, 0, open)
+}
+
+// storeReserved 与 storeFile 相同，但已在 userID 名下为这次写入预留了 reserved 字节（如上传会话创建时的预留）。
+// 预留总会被消耗：成功时计入文件，失败或跳过时归还
+func (s *FileService) storeReserved(userID, parentID uint, name, mimeType string, policy ConflictPolicy, reserved int64, open openFunc) (*model.File, error) {
+	// 先确认目标目录存在并处理重名，避免无意义的读取与写入
+	if _, err := s.childPath(userID, parentID, name); err != nil {
+		s.releaseQuota(userID, reserved)
+		return nil, err
+	}
+	res, err := s.resolveConflict(userID, parentID, name, false, policy, 0)
+	if err != nil {
+		s.releaseQuota(userID, reserved)
+		return nil, err
+	}
+	if res.skip != nil {
+		s.releaseQuota(userID, reserved)
+		return res.skip, nil
+	}
 
 	// 计算文件哈希
+	src, err := open()
+	if err != nil {
+		s.releaseQuota(userID, reserved)
+		return nil, err
+	}
 	hash := md5.New()
-	size, err := io.Copy(hash, src)
+	strong := sha256.New()
+	size, err := io.Copy(io.MultiWriter(hash, strong), src)
+	src.Close()
 	if err != nil {
+		s.releaseQuota(userID, reserved)
 		return nil, err
 	}
 	hashStr := fmt.Sprintf("%x", hash.Sum(nil))
+	sha256Str := fmt.Sprintf("%x", strong.Sum(nil))
 
-	// 重新定位到文件开始
-	src.Seek(0, io.SeekStart)
-
-	// 生成存储路径
-	baseName := filepath.Base(file.Filename) // 避免包含相对路径
-	ext := filepath.Ext(baseName)
-	storagePath := fmt.Sprintf("files/%d/%s%s", userID, hashStr, ext)
+	// 写入存储前预留配额（只补足已预留部分的差额），commitFile 失败时负责归还
+	switch extra := size - reserved; {
+	case extra > 0:
+		if err := s.reserveQuota(userID, extra); err != nil {
+			s.releaseQuota(userID, reserved)
+			return nil, err
+		}
+	case extra < 0:
+		s.releaseQuota(userID, -extra)
+	}
 
-	// 上传到存储
-	if err := s.storage.Upload(storagePath, src, size); err != nil {
+	// 相同内容只存一份：已存在时直接引用，否则重新打开并上传到存储
+	blob, err := s.blobs.Acquire(sha256Str, hashStr, size, func(storagePath string) error {
+		src, err := open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		return s.storage.Upload(storagePath, src, size)
+	})
+	if err != nil {
+		s.releaseQuota(userID, size)
 		return nil, err
 	}
 
-	// 创建文件记录
-	fileModel := &model.File{
-		Name:        baseName,
-		Path:        "/" + strings.TrimPrefix(baseName, "/"),
+	// 创建文件记录，或替换同名文件的内容
+	return s.commitFile(userID, parentID, res, &model.File{
 		Size:        size,
-		MimeType:    file.Header.Get("Content-Type"),
+		MimeType:    mimeType,
 		Hash:        hashStr,
-		UserID:      userID,
-		ParentID:    parentID,
-		StoragePath: storagePath,
-		IsDir:       false,
-	}
-
-	if err := s.fileRepo.Create(fileModel); err != nil {
-		// 如果数据库失败，清理已上传的文件
-		s.storage.Delete(storagePath)
-		return nil, err
-	}
+		SHA256:      sha256Str,
+		StoragePath: blob.StoragePath,
+		BlobID:      blob.ID,
+	})
+}
 
-	return fileModel, nil
+// contentStored 新内容写入文件后触发后台处理：建立全文索引、提取媒体元数据、生成缩略图
+func (s *FileService) contentStored(file *model.File) {
+	s.notifyContentIndexer()
+	s.notifyMetadataExtractor()
+	s.queueThumbnails(file)
 }
 
-// GetFile 获取文件信息
+// GetFile 获取文件信息，包括他人授权给该用户的文件
 func (s *FileService) GetFile(userID, fileID uint) (*model.File, error) {
-	return s.fileRepo.FindByIDAndUser(fileID, userID)
+	return s.accessFile(userID, fileID, false)
 }
 
-// DownloadFile 下载文件
-func (s *FileService) DownloadFile(userID, fileID uint) (io.ReadCloser, *model.File, error) {
-	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+// DownloadFile 下载文件，返回的读取器支持 Seek，用于按范围读取
+func (s *FileService) DownloadFile(userID, fileID uint) (io.ReadSeekCloser, *model.File, error) {
+	file, err := s.accessFile(userID, fileID, false)
 	if err != nil {
 		return nil, nil, err
 	}
-
-	reader, err := s.storage.Download(file.StoragePath)
-	if err != nil {
-		return nil, nil, err
+	if file.IsDir {
+		return nil, nil, ErrNotAFile
 	}
 
-	return reader, file, nil
+	return storage.NewRangeReader(s.storage, file.StoragePath, file.Size), file, nil
 }
 
-// ListFiles 列出文件
+// ListFiles 列出文件；parentID 为他人授权给该用户的文件夹时列出其内容
 func (s *FileService) ListFiles(userID, parentID uint, page, limit int) ([]*model.File, int64, error) {
+	ownerID := userID
+	if parentID != 0 {
+		folder, err := s.accessFile(userID, parentID, false)
+		switch {
+		case err == nil:
+			ownerID = folder.UserID
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, 0, err
+		}
+	}
 	offset := (page - 1) * limit
-	return s.fileRepo.FindByUserAndParent(userID, parentID, offset, limit)
+	return s.fileRepo.FindByUserAndParent(ownerID, parentID, offset, limit)
 }
 
-// DeleteFile 删除文件
-func (s *FileService) DeleteFile(userID, fileID uint) error {
-	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+// DeleteResult 删除统计
+type DeleteResult struct {
+	Files   int64 `json:"files"`
+	Folders int64 `json:"folders"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// DeleteFile 删除文件或文件夹（含全部子项）。默认移入回收站，permanent 为 true 时彻底删除并释放存储
+func (s *FileService) DeleteFile(userID, fileID uint, permanent bool) (*DeleteResult, error) {
+	if _, err := s.fileRepo.FindByIDAndUser(fileID, userID); err != nil {
+		return nil, err
+	}
+
+	var result *DeleteResult
+	var garbage *storageGarbage
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 在事务中重新读取并锁定，按当前统计从上级文件夹扣除，子树也以事务内看到的为准
+		repo := repository.NewFileRepository(tx)
+		file, err := repo.FindByIDForUpdate(fileID)
+		if err != nil {
+			return err
+		}
+		files, err := collectSubtreeWith(repo, userID, file)
+		if err != nil {
+			return err
+		}
+		result = &DeleteResult{}
+		ids := make([]uint, len(files))
+		for i, f := range files {
+			ids[i] = f.ID
+			if f.IsDir {
+				result.Folders++
+			} else {
+				result.Files++
+				result.Bytes += f.Size
+			}
+		}
+
+		if err := addToAncestors(tx, userID, file.Path, statsOf(file).neg()); err != nil {
+			return err
+		}
+		if permanent {
+			garbage, err = s.destroyFiles(tx, files)
+			return err
+		}
+		for _, batch := range chunkIDs(ids, 500) {
+			if err := repo.MoveToTrash(batch, file.ID, userID, time.Now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.purgeStorage(garbage)
+	return result, nil
+}
+
+// collectSubtree 返回 root 及其全部子孙（不含已在回收站中的项），按层级从上到下排列
+func (s *FileService) collectSubtree(userID uint, root *model.File) ([]*model.File, error) {
+	return collectSubtreeWith(s.fileRepo, userID, root)
+}
+
+// collectSubtreeWith 同 collectSubtree，通过 repo 查询，可用于事务内
+func collectSubtreeWith(repo *repository.FileRepository, userID uint, root *model.File) ([]*model.File, error) {
+	files := []*model.File{root}
+	if !root.IsDir {
+		return files, nil
+	}
+	level := []uint{root.ID}
+	for len(level) > 0 {
+		var next []uint
+		for _, batch := range chunkIDs(level, 500) {
+			children, err := repo.FindChildren(userID, batch)
+			if err != nil {
+				return nil, err
+			}
+			for _, child := range children {
+				files = append(files, child)
+				if child.IsDir {
+					next = append(next, child.ID)
+				}
+			}
+		}
+		level = next
+	}
+	return files, nil
+}
+
+// chunkIDs 将ID列表按 size 分批，避免 IN 子句过长
+func chunkIDs(ids []uint, size int) [][]uint {
+	var batches [][]uint
+	for len(ids) > size {
+		batches = append(batches, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
+
+// storageGarbage 删除记录后需要在事务提交后清理的存储数据
+type storageGarbage struct {
+	blobs []*model.Blob // 引用归零的对象
+	paths []string      // 内容寻址存储之前的旧对象，无人引用时才删除
+}
+
+// releaseContent 释放一份内容（文件或历史版本）对存储的引用，待清理的数据记入 garbage
+func (s *FileService) releaseContent(tx *gorm.DB, garbage *storageGarbage, blobID uint, storagePath string) error {
+	if blobID == 0 {
+		if storagePath != "" {
+			garbage.paths = append(garbage.paths, storagePath)
+		}
+		return nil
+	}
+	orphan, err := s.blobs.Release(tx, blobID)
 	if err != nil {
 		return err
 	}
+	if orphan != nil {
+		garbage.blobs = append(garbage.blobs, orphan)
+	}
+	return nil
+}
 
-	// 删除存储中的文件
-	if err := s.storage.Delete(file.StoragePath); err != nil {
-		// 即使存储删除失败，也继续删除数据库记录
-		// 可以记录日志用于后续清理
+// destroyFiles 在事务中永久删除文件记录及其历史版本、释放存储对象引用并扣减所有者的已用空间，
+// 调用方应在事务提交后对返回值调用 purgeStorage
+func (s *FileService) destroyFiles(tx *gorm.DB, files []*model.File) (*storageGarbage, error) {
+	garbage := &storageGarbage{}
+	freed := usageDelta{}
+	ids := make([]uint, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.ID)
+		if f.IsDir {
+			continue
+		}
+		freed[f.UserID] += f.Size
+		if err := s.releaseContent(tx, garbage, f.BlobID, f.StoragePath); err != nil {
+			return nil, err
+		}
+	}
+	repo := repository.NewFileRepository(tx)
+	versionRepo := repository.NewVersionRepository(tx)
+	shareRepo := repository.NewShareRepository(tx)
+	grantRepo := repository.NewGrantRepository(tx)
+	requestRepo := repository.NewFileRequestRepository(tx)
+	albumRepo := repository.NewAlbumRepository(tx)
+	for _, batch := range chunkIDs(ids, 500) {
+		versions, err := versionRepo.FindByFileIDs(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			freed[v.UserID] += v.Size
+			if err := s.releaseContent(tx, garbage, v.BlobID, v.StoragePath); err != nil {
+				return nil, err
+			}
+		}
+		if err := versionRepo.DeleteByFileIDs(batch); err != nil {
+			return nil, err
+		}
+		if err := shareRepo.DeleteByFileIDs(batch); err != nil {
+			return nil, err
+		}
+		if err := grantRepo.DeleteByFileIDs(batch); err != nil {
+			return nil, err
+		}
+		if err := requestRepo.DeleteByFolderIDs(batch); err != nil {
+			return nil, err
+		}
+		if err := albumRepo.DeleteByFileIDs(batch); err != nil {
+			return nil, err
+		}
+		if err := repo.DeleteByIDs(batch); err != nil {
+			return nil, err
+		}
+	}
+	if err := freed.apply(tx); err != nil {
+		return nil, err
 	}
+	return garbage, nil
+}
+
+// purgeStorage 删除已无引用的存储数据
+func (s *FileService) purgeStorage(garbage *storageGarbage) {
+	if garbage == nil {
+		return
+	}
+	s.blobs.Purge(garbage.blobs...)
+
+	// 旧记录的存储对象可能被其他记录或历史版本共用，仅在无人引用时删除
+	for _, p := range garbage.paths {
+		if s.storagePathInUse(p) {
+			continue
+		}
+		// 即使存储删除失败也不影响结果，可以记录日志用于后续清理
+		s.storage.Delete(p)
+	}
+}
 
-	// 删除数据库记录
-	return s.fileRepo.Delete(fileID, userID)
+// storagePathInUse 判断旧存储对象是否仍被文件或历史版本引用，查询失败时视为仍在使用
+func (s *FileService) storagePathInUse(storagePath string) bool {
+	refs, err := s.fileRepo.CountByStoragePath(storagePath)
+	if err != nil || refs > 0 {
+		return true
+	}
+	refs, err = s.versionRepo.CountByStoragePath(storagePath)
+	return err != nil || refs > 0
 }
 
-// CreateFolder 创建文件夹
-func (s *FileService) CreateFolder(userID uint, name string, parentID uint) (*model.File, error) {
+// childPath 计算父目录下子项的完整路径
+func (s *FileService) childPath(userID, parentID uint, name string) (string, error) {
 	parentPath := ""
 	if parentID != 0 {
 		parent, err := s.fileRepo.FindByIDAndUser(parentID, userID)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		parentPath = parent.Path
 	}
-	var fullPath string
+	return joinPath(parentPath, name), nil
+}
+
+// joinPath 拼接目录路径与名称，结果总是以 / 开头
+func joinPath(parentPath, name string) string {
+	name = strings.TrimPrefix(name, "/")
 	if parentPath == "" || parentPath == "/" {
-		fullPath = "/" + strings.TrimPrefix(name, "/")
-	} else {
-		fullPath = parentPath
-		if !strings.HasSuffix(fullPath, "/") {
-			fullPath += "/"
-		}
-		fullPath += strings.TrimPrefix(name, "/")
+		return "/" + name
+	}
+	if !strings.HasSuffix(parentPath, "/") {
+		parentPath += "/"
+	}
+	return parentPath + name
+}
+
+// CreateFolder 创建文件夹，policy 为同名项已存在时的处理方式。
+// 在被授予编辑权限的文件夹中创建时，新文件夹归其所有者
+func (s *FileService) CreateFolder(userID uint, name string, parentID uint, policy ConflictPolicy) (*model.File, error) {
+	if !validName(name) {
+		return nil, ErrInvalidName
+	}
+	ownerID, err := s.folderOwner(userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.childPath(ownerID, parentID, name); err != nil {
+		return nil, err
+	}
+	res, err := s.resolveConflict(ownerID, parentID, name, true, policy, 0)
+	if err != nil {
+		return nil, err
+	}
+	if res.skip != nil {
+		return res.skip, nil
 	}
+
+	fullPath, err := s.childPath(ownerID, parentID, res.name)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := s.createFolder(ownerID, parentID, res.name, fullPath, res)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrNameConflict
+	}
+	return folder, err
+}
+
+// createFolder 创建文件夹记录并计入上级文件夹的统计。res 不为空时在同一事务中将被覆盖的文件夹移入回收站
+func (s *FileService) createFolder(userID, parentID uint, name, fullPath string, res *conflictResolution) (*model.File, error) {
 	folder := &model.File{
 		Name:     name,
 		Path:     fullPath,
-		Size:     0,
 		UserID:   userID,
 		ParentID: parentID,
 		IsDir:    true,
 	}
-
-	if err := s.fileRepo.Create(folder); err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if res != nil {
+			if err := trashReplaced(tx, res); err != nil {
+				return err
+			}
+		}
+		if err := repository.NewFileRepository(tx).Create(folder); err != nil {
+			return err
+		}
+		return addToAncestors(tx, userID, fullPath, statsOf(folder))
+	})
+	if err != nil {
 		return nil, err
 	}
-
 	return folder, nil
 }
 
@@ -165,7 +510,13 @@ func (s *FileService) CreateFolder(userID uint, name string, parentID uint) (*mo
 // - files: 来自 multipart 的文件列表
 // - relPaths: 与 files 按索引一一对应的相对路径（例如 "sub/dir/file.txt"），可为空字符串
 // - parentID: 作为起始父目录ID（0 为根）
-func (s *FileService) UploadFilesWithRelativePaths(userID uint, files []*multipart.FileHeader, relPaths []string, parentID uint) ([]*model.File, error) {
+// - policy: 同名文件已存在时的处理方式，目录总是复用已有的同名文件夹
+func (s *FileService) UploadFilesWithRelativePaths(userID uint, files []*multipart.FileHeader, relPaths []string, parentID uint, policy ConflictPolicy) ([]*model.File, error) {
+	// 目标为被授予编辑权限的文件夹时，中间文件夹和文件都归其所有者
+	ownerID, err := s.folderOwner(userID, parentID)
+	if err != nil {
+		return nil, err
+	}
 	result := make([]*model.File, 0, len(files))
 
 	for i, fh := range files {
@@ -186,25 +537,11 @@ func (s *FileService) UploadFilesWithRelativePaths(userID uint, files []*multipa
 		// 逐级确保目录存在
 		currentParent := parentID
 		if dirPart != "." && dirPart != "" {
-			parts := strings.Split(dirPart, "/")
-			var fullPathBuilder strings.Builder
-			fullPathBuilder.WriteString("/")
-			for idx, p := range parts {
-				if p == "" || p == "." || p == ".." {
-					continue
-				}
-				if idx > 0 {
-					fullPathBuilder.WriteString("/")
-				}
-				fullPathBuilder.WriteString(p)
-
-				fullPath := fullPathBuilder.String()
-				folder, err := s.fileRepo.FindOrCreateFolder(userID, currentParent, p, fullPath)
-				if err != nil {
-					return nil, err
-				}
-				currentParent = folder.ID
+			id, err := s.EnsurePath(ownerID, parentID, dirPart)
+			if err != nil {
+				return nil, err
 			}
+			currentParent = id
 		}
 
 		// 为当前文件构造一个新的 FileHeader，保持原内容，但名称使用 baseName
@@ -212,7 +549,7 @@ func (s *FileService) UploadFilesWithRelativePaths(userID uint, files []*multipa
 		// 直接修改 fh.Filename 不会影响底层内容
 		originalName := fh.Filename
 		fh.Filename = baseName
-		f, err := s.UploadFile(userID, fh, currentParent)
+		f, err := s.UploadFile(ownerID, fh, currentParent, policy)
 		// 恢复原始名字以避免副作用（尽管生命周期仅此处）
 		fh.Filename = originalName
 		if err != nil {
@@ -228,7 +565,53 @@ func (s *FileService) FindDirByPath(userID uint, path string) (*model.File, erro
 	return s.fileRepo.FindDirByPath(userID, path)
 }
 
-// FindOrCreateFolder 递归查找或创建目录
+// FindOrCreateFolder 在父目录下查找文件夹，不存在则创建
 func (s *FileService) FindOrCreateFolder(userID, parentID uint, name, fullPath string) (*model.File, error) {
-	return s.fileRepo.FindOrCreateFolder(userID, parentID, name, fullPath)
+	if f, err := s.fileRepo.FindChildFolder(userID, parentID, name); err == nil {
+		return f, nil
+	}
+	folder, err := s.createFolder(userID, parentID, name, fullPath, nil)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 并发创建同名文件夹时由唯一索引拦截，改为使用对方创建的记录
+		if f, findErr := s.fileRepo.FindChildFolder(userID, parentID, name); findErr == nil {
+			return f, nil
+		}
+	}
+	return folder, err
+}
+
+// EnsurePath 从 parentID 开始逐级查找或创建 path 对应的目录，返回最深一级目录的ID
+func (s *FileService) EnsurePath(userID, parentID uint, path string) (uint, error) {
+	fullPath, err := s.childPath(userID, parentID, "")
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range strings.Split(strings.Trim(path, "/"), "/") {
+		if p == "" || p == "." || p == ".." {
+			continue
+		}
+		fullPath = joinPath(fullPath, p)
+		folder, err := s.FindOrCreateFolder(userID, parentID, p, fullPath)
+		if err != nil {
+			return 0, err
+		}
+		parentID = folder.ID
+	}
+	return parentID, nil
+}
+
+// uniqueName 目录下已存在同名项时追加序号，如 "report (1).pdf"
+func (s *FileService) uniqueName(userID, parentID uint, name string) (string, error) {
+	if _, err := s.fileRepo.FindChildByName(userID, parentID, name); err != nil {
+		return name, nil
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; i <= 9999; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := s.fileRepo.FindChildByName(userID, parentID, candidate); err != nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no available name for %q", name)
 }
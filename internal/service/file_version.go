@@ -0,0 +1,262 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+	"online-disk-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoVersions           = errors.New("folders do not have versions")
+	ErrInvalidVersionPolicy = errors.New("version limits must not be negative")
+)
+
+// SetVersionDefaults 设置用户未配置保留策略时的默认值，0 表示不限制
+func (s *FileService) SetVersionDefaults(maxVersions, maxAgeDays int) {
+	s.versionDefaults = model.VersionPolicy{MaxVersions: maxVersions, MaxAgeDays: maxAgeDays}
+}
+
+// VersionPolicy 返回用户生效的版本保留策略
+func (s *FileService) VersionPolicy(userID uint) *model.VersionPolicy {
+	if policy, err := s.versionRepo.FindPolicy(userID); err == nil {
+		return policy
+	}
+	policy := s.versionDefaults
+	policy.UserID = userID
+	return &policy
+}
+
+// SetVersionPolicy 修改用户的版本保留策略，参数为 nil 的字段保持不变；修改后立即按新策略清理
+func (s *FileService) SetVersionPolicy(userID uint, maxVersions, maxAgeDays *int) (*model.VersionPolicy, error) {
+	policy := s.VersionPolicy(userID)
+	if maxVersions != nil {
+		policy.MaxVersions = *maxVersions
+	}
+	if maxAgeDays != nil {
+		policy.MaxAgeDays = *maxAgeDays
+	}
+	if policy.MaxVersions < 0 || policy.MaxAgeDays < 0 {
+		return nil, ErrInvalidVersionPolicy
+	}
+	if err := s.versionRepo.SavePolicy(policy); err != nil {
+		return nil, err
+	}
+	if err := s.pruneUserVersions(userID, policy); err != nil {
+		log.Printf("prune versions for user %d failed: %v", userID, err)
+	}
+	return policy, nil
+}
+
+// ListVersions 列出文件的历史版本，最新的在前
+func (s *FileService) ListVersions(userID, fileID uint) (*model.File, []*model.FileVersion, error) {
+	file, err := s.versionedFile(userID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := s.versionRepo.FindByFile(file.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, versions, nil
+}
+
+// OpenVersion 打开历史版本内容
+func (s *FileService) OpenVersion(userID, fileID, versionID uint) (io.ReadSeekCloser, *model.File, *model.FileVersion, error) {
+	file, err := s.versionedFile(userID, fileID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	version, err := s.versionRepo.FindByIDAndFile(versionID, file.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return storage.NewRangeReader(s.storage, version.StoragePath, version.Size), file, version, nil
+}
+
+// RestoreVersion 将历史版本恢复为当前内容，当前内容保存为新的历史版本
+func (s *FileService) RestoreVersion(userID, fileID, versionID uint) (*model.File, error) {
+	file, err := s.versionedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.versionRepo.FindByIDAndFile(versionID, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 恢复的版本记录删除，其存储引用转给文件，引用计数不变
+	content := &model.File{
+		Size:        version.Size,
+		MimeType:    version.MimeType,
+		Hash:        version.Hash,
+		SHA256:      version.SHA256,
+		StoragePath: version.StoragePath,
+		BlobID:      version.BlobID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.replaceContent(tx, file, content); err != nil {
+			return err
+		}
+		return repository.NewVersionRepository(tx).DeleteByIDs([]uint{version.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.pruneVersions(userID, file.ID)
+	return s.fileRepo.FindByIDAndUser(file.ID, userID)
+}
+
+// DeleteVersion 删除一个历史版本
+func (s *FileService) DeleteVersion(userID, fileID, versionID uint) error {
+	file, err := s.versionedFile(userID, fileID)
+	if err != nil {
+		return err
+	}
+	version, err := s.versionRepo.FindByIDAndFile(versionID, file.ID)
+	if err != nil {
+		return err
+	}
+	return s.destroyVersions([]*model.FileVersion{version})
+}
+
+// DeleteAllVersions 删除文件的全部历史版本，返回删除的数量
+func (s *FileService) DeleteAllVersions(userID, fileID uint) (int, error) {
+	_, versions, err := s.ListVersions(userID, fileID)
+	if err != nil {
+		return 0, err
+	}
+	return len(versions), s.destroyVersions(versions)
+}
+
+// versionedFile 查找可以拥有历史版本的文件
+func (s *FileService) versionedFile(userID, fileID uint) (*model.File, error) {
+	file, err := s.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsDir {
+		return nil, ErrNoVersions
+	}
+	return file, nil
+}
+
+// destroyVersions 删除历史版本记录，释放存储引用并扣减已用空间
+func (s *FileService) destroyVersions(versions []*model.FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	garbage := &storageGarbage{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, len(versions))
+		freed := usageDelta{}
+		for i, v := range versions {
+			ids[i] = v.ID
+			freed[v.UserID] += v.Size
+			if err := s.releaseContent(tx, garbage, v.BlobID, v.StoragePath); err != nil {
+				return err
+			}
+		}
+		repo := repository.NewVersionRepository(tx)
+		for _, batch := range chunkIDs(ids, 500) {
+			if err := repo.DeleteByIDs(batch); err != nil {
+				return err
+			}
+		}
+		return freed.apply(tx)
+	})
+	if err != nil {
+		return err
+	}
+	s.purgeStorage(garbage)
+	return nil
+}
+
+// pruneVersions 新增历史版本后按用户策略清理该文件超出数量或期限的版本，失败只记录日志
+func (s *FileService) pruneVersions(userID, fileID uint) {
+	policy := s.VersionPolicy(userID)
+	if policy.MaxVersions == 0 && policy.MaxAgeDays == 0 {
+		return
+	}
+	versions, err := s.versionRepo.FindByFile(fileID)
+	if err == nil {
+		err = s.destroyVersions(expiredVersions(versions, policy, time.Now()))
+	}
+	if err != nil {
+		log.Printf("prune versions of file %d failed: %v", fileID, err)
+	}
+}
+
+// expiredVersions 从按时间倒序排列的版本中选出超出策略的部分
+func expiredVersions(versions []*model.FileVersion, policy *model.VersionPolicy, now time.Time) []*model.FileVersion {
+	var expired []*model.FileVersion
+	cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+	for i, v := range versions {
+		if (policy.MaxVersions > 0 && i >= policy.MaxVersions) || (policy.MaxAgeDays > 0 && v.CreatedAt.Before(cutoff)) {
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
+
+// pruneUserVersions 按策略清理用户全部文件的历史版本
+func (s *FileService) pruneUserVersions(userID uint, policy *model.VersionPolicy) error {
+	if policy.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+		for {
+			versions, err := s.versionRepo.FindExpired(userID, cutoff, 500)
+			if err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				break
+			}
+			if err := s.destroyVersions(versions); err != nil {
+				return err
+			}
+		}
+	}
+	if policy.MaxVersions > 0 {
+		fileIDs, err := s.versionRepo.FindFileIDsOverLimit(userID, policy.MaxVersions)
+		if err != nil {
+			return err
+		}
+		for _, fileID := range fileIDs {
+			s.pruneVersions(userID, fileID)
+		}
+	}
+	return nil
+}
+
+// PruneExpiredVersions 按各用户策略清理过期的历史版本
+func (s *FileService) PruneExpiredVersions() error {
+	userIDs, err := s.versionRepo.FindUserIDs()
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := s.pruneUserVersions(userID, s.VersionPolicy(userID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartVersionPrune 启动后台定时清理过期历史版本
+func (s *FileService) StartVersionPrune(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.PruneExpiredVersions(); err != nil {
+				log.Printf("prune versions failed: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"log"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// folderStats 文件夹统计：递归大小、文件数与文件夹数
+type folderStats struct {
+	Size    int64
+	Files   int64
+	Folders int64
+}
+
+func (a folderStats) add(b folderStats) folderStats {
+	return folderStats{Size: a.Size + b.Size, Files: a.Files + b.Files, Folders: a.Folders + b.Folders}
+}
+
+func (a folderStats) neg() folderStats {
+	return folderStats{Size: -a.Size, Files: -a.Files, Folders: -a.Folders}
+}
+
+// statsOf 返回 f 自身及其全部子孙计入上级文件夹的统计
+func statsOf(f *model.File) folderStats {
+	if f.IsDir {
+		return folderStats{Size: f.Size, Files: f.FileCount, Folders: f.FolderCount + 1}
+	}
+	return folderStats{Size: f.Size, Files: 1}
+}
+
+// ancestorPaths 返回路径为 p 的项的全部上级文件夹路径（不含根目录），如 "/a/b/c" 得到 "/a"、"/a/b"
+func ancestorPaths(p string) []string {
+	var paths []string
+	for i := 1; i < len(p); i++ {
+		if p[i] == '/' {
+			paths = append(paths, p[:i])
+		}
+	}
+	return paths
+}
+
+// addToAncestors 在事务中把 delta 计入路径为 p 的项的全部上级文件夹
+func addToAncestors(tx *gorm.DB, userID uint, p string, delta folderStats) error {
+	return repository.NewFileRepository(tx).AddFolderStats(userID, ancestorPaths(p), delta.Size, delta.Files, delta.Folders)
+}
+
+// addFolderStats 在事务中按文件夹路径批量计入增量，增量相同的文件夹合并为一条更新
+func addFolderStats(tx *gorm.DB, userID uint, deltas map[string]folderStats) error {
+	groups := make(map[folderStats][]string)
+	for p, delta := range deltas {
+		groups[delta] = append(groups[delta], p)
+	}
+	repo := repository.NewFileRepository(tx)
+	for delta, paths := range groups {
+		for _, batch := range chunkStrings(paths, 500) {
+			if err := repo.AddFolderStats(userID, batch, delta.Size, delta.Files, delta.Folders); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunkStrings 将字符串列表按 size 分批，避免 IN 子句过长
+func chunkStrings(items []string, size int) [][]string {
+	var batches [][]string
+	for len(items) > size {
+		batches = append(batches, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		batches = append(batches, items)
+	}
+	return batches
+}
+
+// EnsureFolderStats 发现升级前创建、尚无统计的文件夹时，按现有数据重建全部文件夹统计。
+// 统计按路径计入上级文件夹，因此先修复旧版本留下的错误路径，并重建这些用户的统计。
+// 之后的统计都随写入增量维护，不再整体扫描
+func (s *FileService) EnsureFolderStats() error {
+	repaired, err := s.repairLegacyPaths()
+	if err != nil {
+		return err
+	}
+	userIDs := repaired
+	stale, err := s.fileRepo.HasStaleFolderStats()
+	if err != nil {
+		return err
+	}
+	if stale {
+		if userIDs, err = s.fileRepo.FindFolderOwnerIDs(); err != nil {
+			return err
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	for _, userID := range userIDs {
+		if err := s.rebuildFolderStats(userID); err != nil {
+			return err
+		}
+	}
+	log.Printf("rebuilt folder statistics for %d users", len(userIDs))
+	return nil
+}
+
+// repairLegacyPaths 按 parent_id 链重写存在旧版本路径的用户的全部路径，返回修复过的用户
+func (s *FileService) repairLegacyPaths() ([]uint, error) {
+	userIDs, err := s.fileRepo.FindLegacyPathOwnerIDs()
+	if err != nil || len(userIDs) == 0 {
+		return nil, err
+	}
+	total := 0
+	for _, userID := range userIDs {
+		n, err := s.repairPaths(userID)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	log.Printf("repaired %d legacy file paths for %d users", total, len(userIDs))
+	return userIDs, nil
+}
+
+// repairPaths 按 parent_id 链重新计算用户全部记录（包括回收站中的记录）的路径，更新与之不符的记录。
+// 上级记录已不存在的项保持不变
+func (s *FileService) repairPaths(userID uint) (int, error) {
+	files, err := s.fileRepo.FindTreeByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[uint]*model.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	paths := make(map[uint]string, len(files))
+	var resolve func(f *model.File, depth int) (string, bool)
+	resolve = func(f *model.File, depth int) (string, bool) {
+		if p, ok := paths[f.ID]; ok {
+			return p, true
+		}
+		if f.ParentID == 0 {
+			paths[f.ID] = joinPath("/", f.Name)
+			return paths[f.ID], true
+		}
+		parent, ok := byID[f.ParentID]
+		if !ok || depth > len(files) {
+			return "", false // 上级已不存在，或 parent_id 成环
+		}
+		parentPath, ok := resolve(parent, depth+1)
+		if !ok {
+			return "", false
+		}
+		paths[f.ID] = joinPath(parentPath, f.Name)
+		return paths[f.ID], true
+	}
+
+	repaired := 0
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewFileRepository(tx)
+		for _, f := range files {
+			p, ok := resolve(f, 0)
+			if !ok || p == f.Path {
+				continue
+			}
+			if err := repo.UpdateFields(f.ID, map[string]interface{}{"path": p}); err != nil {
+				return err
+			}
+			repaired++
+		}
+		return nil
+	})
+	return repaired, err
+}
+
+// rebuildFolderStats 重新计算用户全部文件夹的统计。回收站中的文件夹只统计与其一同删除的子项，
+// 恢复时即可直接计入上级文件夹
+func (s *FileService) rebuildFolderStats(userID uint) error {
+	files, err := s.fileRepo.FindTreeByUser(userID)
+	if err != nil {
+		return err
+	}
+	children := make(map[uint][]*model.File)
+	for _, f := range files {
+		if f.ParentID != 0 {
+			children[f.ParentID] = append(children[f.ParentID], f)
+		}
+	}
+
+	computed := make(map[uint]folderStats)
+	var compute func(dir *model.File) folderStats
+	compute = func(dir *model.File) folderStats {
+		if stats, ok := computed[dir.ID]; ok {
+			return stats
+		}
+		var stats folderStats
+		for _, child := range children[dir.ID] {
+			if child.TrashRootID != dir.TrashRootID {
+				continue // 单独删除的子项属于另一个回收站条目
+			}
+			if child.IsDir {
+				sub := compute(child)
+				stats = stats.add(folderStats{Size: sub.Size, Files: sub.Files, Folders: sub.Folders + 1})
+			} else {
+				stats = stats.add(folderStats{Size: child.Size, Files: 1})
+			}
+		}
+		computed[dir.ID] = stats
+		return stats
+	}
+
+	for _, f := range files {
+		if !f.IsDir {
+			continue
+		}
+		stats := compute(f)
+		if stats == (folderStats{Size: f.Size, Files: f.FileCount, Folders: f.FolderCount}) {
+			continue
+		}
+		if err := s.fileRepo.SetFolderStats(f.ID, stats.Size, stats.Files, stats.Folders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+
+	sqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestFileService 创建使用临时 SQLite 数据库与本地存储的 FileService，以及一个不限配额的用户
+func newTestFileService(t *testing.T) (*FileService, *model.User) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.File{}, &model.UploadSession{}, &model.UploadChunk{}, &model.InstantChallenge{}, &model.Blob{}, &model.Job{}, &model.FileVersion{}, &model.VersionPolicy{}, &model.Share{}, &model.FileGrant{}, &model.FileRequest{}, &model.FileRequestUpload{}, &model.ContentIndex{}, &model.ContentTerm{}, &model.ContentChunk{}, &model.Thumbnail{}, &model.ImageVariant{}, &model.MediaMetadata{}, &model.Album{}, &model.AlbumItem{}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileService(db, storage.NewLocalStorage(filepath.Join(dir, "storage")))
+	if err := s.EnsureNameIndex(); err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Username: "alice", Email: "alice@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return s, user
+}
+
+// storeText 以 content 为内容上传文件
+func storeText(t *testing.T, s *FileService, userID, parentID uint, name, content string) *model.File {
+	t.Helper()
+	open := func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil }
+	file, err := s.storeFile(userID, parentID, name, "text/plain", ConflictFail, open)
+	if err != nil {
+		t.Fatalf("store %s: %v", name, err)
+	}
+	return file
+}
+
+// wantFolderStats 检查文件夹当前的统计
+func wantFolderStats(t *testing.T, s *FileService, folderID uint, want folderStats) {
+	t.Helper()
+	folder, err := s.fileRepo.FindByID(folderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := folderStats{Size: folder.Size, Files: folder.FileCount, Folders: folder.FolderCount}
+	if got != want {
+		t.Errorf("folder %s stats = %+v, want %+v", folder.Path, got, want)
+	}
+}
+
+func TestFolderStatsNestedFile(t *testing.T) {
+	s, user := newTestFileService(t)
+	a, err := s.CreateFolder(user.ID, "a", 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.CreateFolder(user.ID, "b", a.ID, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := storeText(t, s, user.ID, b.ID, "note.txt", "hello")
+	if file.Path != "/a/b/note.txt" {
+		t.Errorf("uploaded path = %q, want /a/b/note.txt", file.Path)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Size: 5, Files: 1, Folders: 1})
+	wantFolderStats(t, s, b.ID, folderStats{Size: 5, Files: 1})
+
+	moved, err := s.MoveFile(user.ID, file.ID, "", &a.ID, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Path != "/a/note.txt" {
+		t.Errorf("moved path = %q, want /a/note.txt", moved.Path)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Size: 5, Files: 1, Folders: 1})
+	wantFolderStats(t, s, b.ID, folderStats{})
+
+	if _, err := s.MoveFile(user.ID, file.ID, "", &b.ID, ConflictFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteFile(user.ID, file.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Folders: 1})
+	wantFolderStats(t, s, b.ID, folderStats{})
+}
+
+func TestFolderStatsLegacyPath(t *testing.T) {
+	s, user := newTestFileService(t)
+	a, err := s.CreateFolder(user.ID, "a", 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.CreateFolder(user.ID, "b", a.ID, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本上传到子文件夹的文件以 "/名称" 作为路径，也没有计入文件夹统计
+	legacy := &model.File{Name: "old.txt", Path: "/old.txt", Size: 7, UserID: user.ID, ParentID: b.ID, StoragePath: "files/legacy/old.txt"}
+	if err := s.db.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.EnsureFolderStats(); err != nil {
+		t.Fatal(err)
+	}
+	repaired, err := s.fileRepo.FindByID(legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Path != "/a/b/old.txt" {
+		t.Errorf("repaired path = %q, want /a/b/old.txt", repaired.Path)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Size: 7, Files: 1, Folders: 1})
+	wantFolderStats(t, s, b.ID, folderStats{Size: 7, Files: 1})
+
+	if _, err := s.MoveFile(user.ID, legacy.ID, "", &a.ID, ConflictFail); err != nil {
+		t.Fatal(err)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Size: 7, Files: 1, Folders: 1})
+	wantFolderStats(t, s, b.ID, folderStats{})
+
+	if _, err := s.DeleteFile(user.ID, legacy.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	wantFolderStats(t, s, a.ID, folderStats{Folders: 1})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// imageOrientation 读取图片 EXIF 中的方向（1–8）；没有或无法读取时返回 1（不需要旋转）
+func imageOrientation(format string, r io.ReadSeeker) int {
+	return parseExif(imageExif(format, r)).orientation
+}
+
+// imageExif 读取图片中 TIFF 格式的 EXIF 数据，目前支持 JPEG 与 WebP；没有或无法读取时返回 nil
+func imageExif(format string, r io.ReadSeeker) []byte {
+	switch format {
+	case "jpeg":
+		return jpegExif(bufio.NewReader(r))
+	case "webp":
+		return webpExif(r)
+	}
+	return nil
+}
+
+// jpegExif 在 JPEG 的 APP1 段中查找 EXIF 数据，读到图像数据（SOS）前为止
+func jpegExif(r *bufio.Reader) []byte {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil || b != 0xFF {
+			return nil
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF { // 标记前可以有填充的 0xFF
+			marker, err = r.ReadByte()
+		}
+		switch {
+		case err != nil, marker == 0xDA, marker == 0xD9:
+			return nil
+		case marker == 0x01, marker >= 0xD0 && marker <= 0xD8:
+			continue // 没有长度字段的标记
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil
+		}
+		n := int(binary.BigEndian.Uint16(size[:])) - 2
+		if n < 0 {
+			return nil
+		}
+		if marker != 0xE1 {
+			if _, err := r.Discard(n); err != nil {
+				return nil
+			}
+			continue
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil
+		}
+		if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			return data[6:]
+		}
+	}
+}
+
+// webpExif 在 WebP 的 RIFF 容器中查找 EXIF 块，图像数据块直接跳过
+func webpExif(r io.ReadSeeker) []byte {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil ||
+		string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil
+	}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == "EXIF" {
+			if size > 1<<20 {
+				return nil
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil
+			}
+			return bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+		}
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil { // 块按偶数字节对齐
+			return nil
+		}
+	}
+}
+
+// applyOrientation 按 EXIF 方向旋转或翻转图片，得到正常显示的方向；方向 5–8 会交换宽高
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿左上—右下对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, h-1-x
+			case 7: // 沿右上—左下对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = w-1-y, x
+			}
+			si := src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+
+	"golang.org/x/image/draw"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTransform = errors.New("invalid image transform")
+
+// 缩放方式
+const (
+	FitContain = "contain" // 等比缩放到 w×h 之内（默认）
+	FitCover   = "cover"   // 等比缩放到覆盖 w×h，居中裁掉多余部分
+	FitFill    = "fill"    // 拉伸为 w×h
+)
+
+// 输出格式。WebP 没有纯 Go 的编码器，不支持输出
+const (
+	ImageFormatAuto = "auto" // 不透明的图片为 JPEG，否则为 PNG（默认）
+	ImageFormatJPEG = "jpeg"
+	ImageFormatPNG  = "png"
+	ImageFormatGIF  = "gif"
+)
+
+const (
+	defaultTransformQuality = 85
+	imageVariantTouchAfter  = time.Hour // 最近使用时间的更新间隔，避免每次命中都写数据库
+	imageVariantEvictBatch  = 100
+)
+
+// ImageLimits 图片变换的限制
+type ImageLimits struct {
+	MaxDimension  int   // 输出图片的宽、高上限，0 表示不限制
+	MaxCacheBytes int64 // 变换结果缓存的总大小上限，超出时淘汰最久未使用的，0 表示不限制
+}
+
+// SetImageLimits 设置图片变换的限制
+func (s *FileService) SetImageLimits(limits ImageLimits) {
+	s.imageLimits = limits
+}
+
+// ImageTransformOptions 图片变换参数。先按 EXIF 方向摆正，再裁剪，最后缩放并转换格式
+type ImageTransformOptions struct {
+	Width   int    // 输出宽度，0 表示按高度等比计算
+	Height  int    // 输出高度，0 表示按宽度等比计算；两者都为 0 时保持裁剪后的尺寸
+	Fit     string // contain（默认）、cover 或 fill，只在同时指定宽高时有意义
+	Crop    string // 裁剪区域 "x,y,w,h"，为摆正后图片中的像素坐标，空表示不裁剪
+	Format  string // auto（默认）、jpeg、png 或 gif
+	Quality int    // JPEG 质量 1–100，0 表示默认值 85
+}
+
+// imageTransform 校验并规范化后的变换参数
+type imageTransform struct {
+	width, height int
+	fit           string
+	crop          image.Rectangle // 为空表示不裁剪
+	format        string
+	quality       int
+}
+
+// normalizeTransform 校验变换参数并填入默认值
+func (s *FileService) normalizeTransform(opts ImageTransformOptions) (*imageTransform, error) {
+	t := &imageTransform{width: opts.Width, height: opts.Height, fit: opts.Fit, format: opts.Format, quality: opts.Quality}
+	limit := s.imageLimits.MaxDimension
+	for _, v := range []struct {
+		name  string
+		value int
+	}{{"w", t.width}, {"h", t.height}} {
+		if v.value < 0 {
+			return nil, fmt.Errorf("%w: %s must be positive", ErrInvalidTransform, v.name)
+		}
+		if limit > 0 && v.value > limit {
+			return nil, fmt.Errorf("%w: %s must not exceed %d", ErrInvalidTransform, v.name, limit)
+		}
+	}
+
+	switch t.fit {
+	case "":
+		t.fit = FitContain
+	case FitContain, FitCover, FitFill:
+	default:
+		return nil, fmt.Errorf("%w: fit must be contain, cover or fill", ErrInvalidTransform)
+	}
+	if t.width == 0 || t.height == 0 {
+		t.fit = FitContain // 只指定一边时总是等比缩放
+	}
+
+	switch strings.ToLower(t.format) {
+	case "":
+		t.format = ImageFormatAuto
+	case "jpg", ImageFormatJPEG:
+		t.format = ImageFormatJPEG
+	case ImageFormatAuto, ImageFormatPNG, ImageFormatGIF:
+		t.format = strings.ToLower(t.format)
+	case "webp":
+		return nil, fmt.Errorf("%w: WebP output is not supported, use jpeg, png or gif", ErrInvalidTransform)
+	default:
+		return nil, fmt.Errorf("%w: format must be auto, jpeg, png or gif", ErrInvalidTransform)
+	}
+
+	switch {
+	case t.quality < 0 || t.quality > 100:
+		return nil, fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidTransform)
+	case t.format == ImageFormatPNG || t.format == ImageFormatGIF:
+		t.quality = 0 // 无损格式不使用质量参数
+	case t.quality == 0:
+		t.quality = defaultTransformQuality
+	}
+
+	if opts.Crop != "" {
+		parts := strings.Split(opts.Crop, ",")
+		var v [4]int
+		valid := len(parts) == 4
+		for i := 0; valid && i < 4; i++ {
+			n, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+			valid = err == nil && n >= 0 && (i < 2 || n > 0)
+			v[i] = n
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: crop must be x,y,w,h with non-negative offsets and positive size", ErrInvalidTransform)
+		}
+		t.crop = image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])
+	}
+	return t, nil
+}
+
+// params 规范化的参数字符串，相同的变换得到相同的字符串
+func (t *imageTransform) params() string {
+	crop := "none"
+	if !t.crop.Empty() {
+		crop = fmt.Sprintf("%d:%d:%d:%d", t.crop.Min.X, t.crop.Min.Y, t.crop.Dx(), t.crop.Dy())
+	}
+	return fmt.Sprintf("w=%d,h=%d,fit=%s,crop=%s,format=%s,q=%d", t.width, t.height, t.fit, crop, t.format, t.quality)
+}
+
+// TransformImage 按参数变换图片文件并返回结果。结果按内容与参数缓存，
+// 相同内容的文件、相同的参数只生成一次
+func (s *FileService) TransformImage(userID, fileID uint, opts ImageTransformOptions) (*model.ImageVariant, []byte, *model.File, error) {
+	t, err := s.normalizeTransform(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	file, err := s.accessFile(userID, fileID, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if file.IsDir || imageFormat(file) == "" {
+		return nil, nil, nil, ErrUnsupportedImage
+	}
+	params := t.params()
+	sum := sha256.Sum256([]byte(params))
+	digest := hex.EncodeToString(sum[:8])
+
+	if file.BlobID == 0 {
+		// 早于内容寻址存储的旧记录没有对应的对象，生成后不缓存
+		out, err := s.renderTransform(file, t)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		variant := &model.ImageVariant{Digest: digest, Params: params, MimeType: out.mimeType, Width: out.width, Height: out.height, Bytes: int64(len(out.data))}
+		return variant, out.data, file, nil
+	}
+
+	variant, err := s.imageRepo.FindByBlobAndDigest(file.BlobID, digest)
+	if err == nil {
+		if now := time.Now(); now.Sub(variant.AccessedAt) > imageVariantTouchAfter {
+			if err := s.imageRepo.Touch(variant, now); err != nil {
+				log.Printf("update image variant access time failed: %v", err)
+			}
+		}
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		variant, err = s.ensureImageVariant(file, t, digest, params)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rc, err := s.storage.Download(variant.StoragePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return variant, data, file, nil
+}
+
+// ensureImageVariant 生成变换结果并写入缓存，同一内容与参数的并发请求只生成一次
+func (s *FileService) ensureImageVariant(file *model.File, t *imageTransform, digest, params string) (*model.ImageVariant, error) {
+	key := "variant:" + strconv.FormatUint(uint64(file.BlobID), 10) + ":" + digest
+	v, err, _ := s.imageFlight.Do(key, func() (interface{}, error) {
+		if variant, err := s.imageRepo.FindByBlobAndDigest(file.BlobID, digest); err == nil {
+			return variant, nil
+		}
+		out, err := s.renderTransform(file, t)
+		if err != nil {
+			return nil, err
+		}
+		storagePath := "variants/" + strings.TrimPrefix(file.StoragePath, "blobs/") + "-" + digest
+		if err := s.storage.Upload(storagePath, bytes.NewReader(out.data), int64(len(out.data))); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		variant := &model.ImageVariant{
+			BlobID:      file.BlobID,
+			Digest:      digest,
+			CreatedAt:   now,
+			AccessedAt:  now,
+			Params:      params,
+			StoragePath: storagePath,
+			MimeType:    out.mimeType,
+			Width:       out.width,
+			Height:      out.height,
+			Bytes:       int64(len(out.data)),
+		}
+		if err := s.imageRepo.Create(variant); err != nil {
+			return nil, err
+		}
+		s.evictImageVariants(variant)
+		return variant, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*model.ImageVariant), nil
+}
+
+// evictImageVariants 缓存超出容量时按最近使用时间从早到晚淘汰变换结果，刚生成的 keep 除外
+func (s *FileService) evictImageVariants(keep *model.ImageVariant) {
+	limit := s.imageLimits.MaxCacheBytes
+	if limit <= 0 {
+		return
+	}
+	total, err := s.imageRepo.TotalBytes()
+	if err != nil {
+		log.Printf("sum image variant size failed: %v", err)
+		return
+	}
+	for total > limit {
+		variants, err := s.imageRepo.FindLeastRecent(imageVariantEvictBatch)
+		if err != nil || len(variants) == 0 {
+			return
+		}
+		var evict []*model.ImageVariant
+		for _, v := range variants {
+			if total <= limit {
+				break
+			}
+			if v.BlobID == keep.BlobID && v.Digest == keep.Digest {
+				continue
+			}
+			evict = append(evict, v)
+			total -= v.Bytes
+		}
+		if len(evict) == 0 || !s.blobs.purgeImageVariants(evict) {
+			return
+		}
+	}
+}
+
+// renderTransform 解码图片，按 EXIF 方向摆正后裁剪、缩放并编码
+func (s *FileService) renderTransform(file *model.File, t *imageTransform) (*renderedImage, error) {
+	s.imageSlots <- struct{}{}
+	defer func() { <-s.imageSlots }()
+
+	src, orientation, err := s.decodeImage(file)
+	if err != nil {
+		return nil, err
+	}
+	if orientation > 1 {
+		rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+		src = applyOrientation(rgba, orientation)
+	}
+
+	b := src.Bounds()
+	region := b
+	if !t.crop.Empty() {
+		region = t.crop.Add(b.Min)
+		if !region.In(b) {
+			return nil, fmt.Errorf("%w: crop exceeds the %dx%d image", ErrInvalidTransform, b.Dx(), b.Dy())
+		}
+	}
+	region, w, h := transformSize(region, t)
+	if limit := s.imageLimits.MaxDimension; limit > 0 && (w > limit || h > limit) {
+		return nil, fmt.Errorf("%w: output %dx%d exceeds the %d pixel limit", ErrInvalidTransform, w, h, limit)
+	}
+
+	out := scaleImage(src, region, w, h)
+	data, mimeType, err := encodeImage(out, t.format, t.quality)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedImage{mimeType: mimeType, width: w, height: h, data: data}, nil
+}
+
+// transformSize 计算输出尺寸；cover 方式下同时返回居中裁掉多余部分后的源区域
+func transformSize(region image.Rectangle, t *imageTransform) (image.Rectangle, int, int) {
+	sw, sh := region.Dx(), region.Dy()
+	switch {
+	case t.width == 0 && t.height == 0:
+		return region, sw, sh
+	case t.height == 0:
+		return region, t.width, max(sh*t.width/sw, 1)
+	case t.width == 0:
+		return region, max(sw*t.height/sh, 1), t.height
+	}
+	switch t.fit {
+	case FitFill:
+		return region, t.width, t.height
+	case FitCover:
+		// 源区域按输出宽高比取中间部分
+		cw, ch := sw, sw*t.height/t.width
+		if ch > sh {
+			cw, ch = sh*t.width/t.height, sh
+		}
+		cw, ch = max(cw, 1), max(ch, 1)
+		x := region.Min.X + (sw-cw)/2
+		y := region.Min.Y + (sh-ch)/2
+		return image.Rect(x, y, x+cw, y+ch), t.width, t.height
+	}
+	// contain：按较小的缩放比例
+	if sw*t.height > sh*t.width {
+		return region, t.width, max(sh*t.width/sw, 1)
+	}
+	return region, max(sw*t.height/sh, 1), t.height
+}
+
+// encodeImage 按格式编码图片。auto 时不透明的图片编码为 JPEG，含透明像素的编码为 PNG；
+// JPEG 不支持透明，透明部分以白色填充
+func encodeImage(img *image.RGBA, format string, quality int) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case ImageFormatJPEG:
+		if !img.Opaque() {
+			bg := image.NewRGBA(img.Bounds())
+			draw.Draw(bg, bg.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+			draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+			img = bg
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	case ImageFormatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	case ImageFormatGIF:
+		if err := gif.Encode(&buf, img, nil); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/gif", nil
+	}
+	if img.Opaque() {
+		return encodeImage(img, ImageFormatJPEG, quality)
+	}
+	return encodeImage(img, ImageFormatPNG, quality)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// JobFunc 任务主体，通过 progress 报告进度，返回结果文件ID
+type JobFunc func(progress *JobProgress) (uint, error)
+
+// JobProgress 供任务主体报告进度
+type JobProgress struct {
+	jobs  *JobService
+	jobID uint
+}
+
+// Done 报告已处理的条目数
+func (p *JobProgress) Done(done int) {
+	p.jobs.update(p.jobID, map[string]interface{}{"done": done})
+}
+
+// Total 更新需处理的条目数，用于开始执行后才能确定总数的任务
+func (p *JobProgress) Total(total int) {
+	p.jobs.update(p.jobID, map[string]interface{}{"total": total})
+}
+
+// JobService 在后台 goroutine 中执行耗时操作，并将状态与进度记录到数据库
+type JobService struct {
+	db      *gorm.DB
+	jobRepo *repository.JobRepository
+}
+
+func NewJobService(db *gorm.DB) *JobService {
+	return &JobService{
+		db:      db,
+		jobRepo: repository.NewJobRepository(db),
+	}
+}
+
+// Start 创建任务并在后台执行 fn，立即返回任务记录
+func (s *JobService) Start(userID uint, jobType string, sourceID uint, total int, fn JobFunc) (*model.Job, error) {
+	job := &model.Job{
+		UserID:   userID,
+		Type:     jobType,
+		Status:   model.JobStatusPending,
+		SourceID: sourceID,
+		Total:    total,
+	}
+	if err := s.jobRepo.Create(job); err != nil {
+		return nil, err
+	}
+	go s.run(job.ID, fn)
+	return job, nil
+}
+
+func (s *JobService) run(jobID uint, fn JobFunc) {
+	s.update(jobID, map[string]interface{}{"status": model.JobStatusRunning})
+
+	var resultID uint
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		resultID, err = fn(&JobProgress{jobs: s, jobID: jobID})
+	}()
+
+	fields := map[string]interface{}{"finished_at": time.Now()}
+	if err != nil {
+		fields["status"] = model.JobStatusFailed
+		fields["error"] = truncate(err.Error(), 1024)
+	} else {
+		fields["status"] = model.JobStatusSucceeded
+		fields["result_id"] = resultID
+	}
+	s.update(jobID, fields)
+}
+
+func (s *JobService) update(jobID uint, fields map[string]interface{}) {
+	if err := s.jobRepo.UpdateFields(jobID, fields); err != nil {
+		log.Printf("update job %d failed: %v", jobID, err)
+	}
+}
+
+// Get 查询任务
+func (s *JobService) Get(userID, jobID uint) (*model.Job, error) {
+	return s.jobRepo.FindByIDAndUser(jobID, userID)
+}
+
+// FailInterrupted 服务启动时调用：上次进程退出时仍在执行的任务已无法继续，标记为失败
+func (s *JobService) FailInterrupted() {
+	n, err := s.jobRepo.FailUnfinished("interrupted by server restart")
+	if err != nil {
+		log.Printf("mark interrupted jobs failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("marked %d interrupted jobs as failed", n)
+	}
+}
+
+// truncate 将 s 截断为不超过 n 字节，不切断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
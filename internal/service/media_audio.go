@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"online-disk-server/internal/model"
+)
+
+const (
+	maxTagFrameBytes = 64 << 10 // 只读取不超过该大小的文本标签，跳过封面等大数据
+	mpegSyncScan     = 64 << 10 // 在标签之后查找第一个 MPEG 帧的范围
+)
+
+var errNotAudio = errors.New("not a recognized audio file")
+
+// readMP3 读取 MP3 的 ID3v2 标签（缺少时使用 ID3v1）并根据 MPEG 帧头计算时长
+func readMP3(r io.ReaderAt, size int64, meta *model.MediaMetadata) error {
+	audioStart := readID3v2(r, meta)
+	audioEnd := size
+	var v1 [128]byte
+	if size >= 128+audioStart {
+		if _, err := r.ReadAt(v1[:], size-128); err == nil && string(v1[:3]) == "TAG" {
+			audioEnd -= 128
+			fields := []*string{&meta.Title, &meta.Artist, &meta.Album}
+			for i, f := range fields {
+				if *f == "" {
+					*f = latin1(bytes.TrimRight(v1[3+i*30:33+i*30], "\x00 "))
+				}
+			}
+		}
+	}
+	if meta.Duration == 0 {
+		meta.Duration = mpegDuration(r, audioStart, audioEnd)
+	}
+	if meta.Duration == 0 && meta.Title == "" && meta.Artist == "" && audioStart == 0 {
+		return errNotAudio
+	}
+	return nil
+}
+
+// readID3v2 读取文件开头的 ID3v2（2.2–2.4）标签中的标题、艺术家、专辑与时长，返回标签之后的偏移
+func readID3v2(r io.ReaderAt, meta *model.MediaMetadata) int64 {
+	var header [10]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil || string(header[:3]) != "ID3" {
+		return 0
+	}
+	version, flags := header[3], header[5]
+	tagSize := int64(syncsafe(header[6:10]))
+	end := 10 + tagSize
+	if flags&0x10 != 0 {
+		end += 10 // 2.4 的标签尾
+	}
+	if version < 2 || version > 4 {
+		return end
+	}
+
+	pos := int64(10)
+	if flags&0x40 != 0 && version >= 3 { // 扩展头
+		var ext [4]byte
+		if _, err := r.ReadAt(ext[:], pos); err != nil {
+			return end
+		}
+		if version == 3 {
+			pos += 4 + int64(binary.BigEndian.Uint32(ext[:]))
+		} else {
+			pos += int64(syncsafe(ext[:]))
+		}
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	frameNames := map[string]*string{
+		"TIT2": &meta.Title, "TPE1": &meta.Artist, "TALB": &meta.Album,
+		"TT2": &meta.Title, "TP1": &meta.Artist, "TAL": &meta.Album,
+	}
+	frame := make([]byte, headerLen)
+	for pos+int64(headerLen) <= 10+tagSize {
+		if _, err := r.ReadAt(frame, pos); err != nil || frame[0] == 0 {
+			break // 填充区
+		}
+		id := string(frame[:idLen])
+		var n int64
+		switch version {
+		case 2:
+			n = int64(frame[3])<<16 | int64(frame[4])<<8 | int64(frame[5])
+		case 3:
+			n = int64(binary.BigEndian.Uint32(frame[4:8]))
+		default:
+			n = int64(syncsafe(frame[4:8]))
+		}
+		pos += int64(headerLen)
+		if n <= 0 || pos+n > 10+tagSize {
+			break
+		}
+		target, isText := frameNames[id]
+		isLength := id == "TLEN" || id == "TLE"
+		if (isText || isLength) && n <= maxTagFrameBytes && (version < 3 || frame[9]&0x0C == 0) { // 跳过压缩与加密的帧
+			data := make([]byte, n)
+			if _, err := r.ReadAt(data, pos); err == nil {
+				text := id3Text(data)
+				if isText {
+					*target = truncate(text, 255)
+				} else if ms, err := strconv.ParseFloat(text, 64); err == nil && ms > 0 {
+					meta.Duration = ms / 1000
+				}
+			}
+		}
+		pos += n
+	}
+	return end
+}
+
+// syncsafe 解析每字节只用低 7 位的整数
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// id3Text 解码 ID3 文本帧：首字节为编码，多个值以 NUL 分隔时只取第一个
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	enc, data := data[0], data[1:]
+	var text string
+	switch enc {
+	case 0:
+		text = latin1(data)
+	case 1, 2:
+		text = utf16Text(data, enc == 2)
+	default:
+		text = strings.ToValidUTF8(string(data), "")
+	}
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// utf16Text 解码 UTF-16 文本，有 BOM 时按 BOM 判断字节序，否则 bigEndian 决定
+func utf16Text(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian, data = true, data[2:]
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian, data = false, data[2:]
+		}
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(data[i*2:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(data[i*2:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 按 ISO-8859-1 解码
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// MPEG 音频帧头中的比特率（kbps），按 [MPEG-1 / MPEG-2 及 2.5][层 I、II、III] 索引
+var mpegBitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mpegSampleRates = [3]int{44100, 48000, 32000}
+
+// mpegDuration 由第一个 MPEG 音频帧计算时长：有 Xing/Info 或 VBRI 头时按总帧数，否则按固定比特率估算
+func mpegDuration(r io.ReaderAt, start, end int64) float64 {
+	buf := make([]byte, min(int64(mpegSyncScan), max(end-start, 0)))
+	n, _ := r.ReadAt(buf, start)
+	buf = buf[:n]
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version := buf[i+1] >> 3 & 3    // 0: 2.5，2: 2，3: 1
+		layer := 4 - int(buf[i+1]>>1&3) // 1–3
+		bitrateIndex := int(buf[i+2] >> 4)
+		rateIndex := int(buf[i+2] >> 2 & 3)
+		if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+		v := 0
+		if version != 3 {
+			v = 1
+		}
+		bitrate := mpegBitrates[v][layer-1][bitrateIndex] * 1000
+		sampleRate := mpegSampleRates[rateIndex]
+		switch version {
+		case 2:
+			sampleRate /= 2
+		case 0:
+			sampleRate /= 4
+		}
+		samples := 1152
+		switch {
+		case layer == 1:
+			samples = 384
+		case layer == 3 && version != 3:
+			samples = 576
+		}
+
+		mono := buf[i+3]>>6 == 3
+		side := 32
+		switch {
+		case version == 3 && mono:
+			side = 17
+		case version != 3 && !mono:
+			side = 17
+		case version != 3:
+			side = 9
+		}
+		frame := buf[i:]
+		if x := 4 + side; len(frame) >= x+12 && (string(frame[x:x+4]) == "Xing" || string(frame[x:x+4]) == "Info") {
+			if binary.BigEndian.Uint32(frame[x+4:])&1 != 0 {
+				frames := binary.BigEndian.Uint32(frame[x+8:])
+				return float64(frames) * float64(samples) / float64(sampleRate)
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames := binary.BigEndian.Uint32(frame[36+14:])
+			return float64(frames) * float64(samples) / float64(sampleRate)
+		}
+		return float64(end-start-int64(i)) * 8 / float64(bitrate)
+	}
+	return 0
+}
+
+// readFLAC 读取 FLAC 的 STREAMINFO（时长）与 VORBIS_COMMENT（标题、艺术家、专辑）
+func readFLAC(r io.ReaderAt, size int64, meta *model.MediaMetadata) error {
+	pos := int64(0)
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err == nil && string(magic[:3]) == "ID3" {
+		var header [10]byte
+		r.ReadAt(header[:], 0)
+		pos = 10 + int64(syncsafe(header[6:10]))
+		r.ReadAt(magic[:], pos)
+	}
+	if string(magic[:]) != "fLaC" {
+		return errNotAudio
+	}
+	pos += 4
+	for {
+		var header [4]byte
+		if _, err := r.ReadAt(header[:], pos); err != nil {
+			return nil
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		n := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		pos += 4
+		if pos+n > size {
+			return nil
+		}
+		switch {
+		case blockType == 0 && n >= 18:
+			var info [18]byte
+			if _, err := r.ReadAt(info[:], pos); err != nil {
+				return err
+			}
+			sampleRate := uint32(info[10])<<12 | uint32(info[11])<<4 | uint32(info[12])>>4
+			samples := uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+			if sampleRate > 0 {
+				meta.Duration = float64(samples) / float64(sampleRate)
+			}
+		case blockType == 4 && n <= maxTagFrameBytes*16:
+			data := make([]byte, n)
+			if _, err := r.ReadAt(data, pos); err != nil {
+				return err
+			}
+			vorbisComments(data, meta)
+		}
+		if last {
+			return nil
+		}
+		pos += n
+	}
+}
+
+// vorbisComments 解析 Vorbis 注释（小端长度前缀的 KEY=value 列表）
+func vorbisComments(data []byte, meta *model.MediaMetadata) {
+	if len(data) < 8 {
+		return
+	}
+	pos := 4 + int(binary.LittleEndian.Uint32(data))
+	if pos+4 > len(data) || pos < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4
+	for i := 0; i < count && pos+4 <= len(data); i++ {
+		n := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if n < 0 || pos+n > len(data) {
+			return
+		}
+		key, value, ok := strings.Cut(string(data[pos:pos+n]), "=")
+		pos += n
+		if !ok {
+			continue
+		}
+		value = truncate(strings.TrimSpace(strings.ToValidUTF8(value, "")), 255)
+		var target *string
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			target = &meta.Title
+		case "ARTIST":
+			target = &meta.Artist
+		case "ALBUM":
+			target = &meta.Album
+		}
+		if target != nil && *target == "" {
+			*target = value
+		}
+	}
+}
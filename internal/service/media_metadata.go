@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// mediaFormat 根据文件名和 MIME 类型判断可以提取元数据的格式，返回媒体类型与格式；不支持时返回空字符串
+func mediaFormat(file *model.File) (kind, format string) {
+	if f := imageFormat(file); f != "" {
+		return model.MediaImage, f
+	}
+	switch strings.ToLower(path.Ext(file.Name)) {
+	case ".mp3":
+		return model.MediaAudio, "mp3"
+	case ".flac":
+		return model.MediaAudio, "flac"
+	case ".m4a", ".m4b":
+		return model.MediaAudio, "mp4"
+	case ".mp4", ".m4v", ".mov":
+		return model.MediaVideo, "mp4"
+	case ".pdf":
+		return model.MediaDocument, "pdf"
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(file.MimeType, ";", 2)[0])) {
+	case "audio/mpeg", "audio/mp3":
+		return model.MediaAudio, "mp3"
+	case "audio/flac", "audio/x-flac":
+		return model.MediaAudio, "flac"
+	case "audio/mp4", "audio/x-m4a":
+		return model.MediaAudio, "mp4"
+	case "video/mp4", "video/quicktime":
+		return model.MediaVideo, "mp4"
+	case "application/pdf":
+		return model.MediaDocument, "pdf"
+	}
+	return "", ""
+}
+
+// GetFileInfo 获取文件信息，已提取媒体元数据的文件附带元数据
+func (s *FileService) GetFileInfo(userID, fileID uint) (*model.File, error) {
+	file, err := s.accessFile(userID, fileID, false)
+	if err != nil || file.IsDir || file.BlobID == 0 {
+		return file, err
+	}
+	meta, err := s.metaRepo.FindByBlob(file.BlobID)
+	switch {
+	case err == nil:
+		if meta.Kind != "" && meta.Error == "" {
+			file.Metadata = meta
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	return file, nil
+}
+
+// notifyMetadataExtractor 有新内容写入时唤醒后台元数据提取，已有待处理的唤醒时不重复
+func (s *FileService) notifyMetadataExtractor() {
+	select {
+	case s.metaWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartMetadataExtractor 启动后台媒体元数据提取：上传后立即被唤醒，另按 interval 定时检查遗漏的内容。
+// 启动前先清理对象已不存在的元数据
+func (s *FileService) StartMetadataExtractor(interval time.Duration) {
+	if n, err := s.metaRepo.DeleteOrphans(); err != nil {
+		log.Printf("clean media metadata failed: %v", err)
+	} else if n > 0 {
+		log.Printf("removed media metadata of %d deleted blobs", n)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.extractPendingMetadata()
+			select {
+			case <-s.metaWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// extractPendingMetadata 为尚未提取元数据的对象逐个提取
+func (s *FileService) extractPendingMetadata() {
+	var afterID uint
+	for {
+		ids, err := s.metaRepo.FindPendingBlobs(afterID, 100)
+		if err != nil {
+			log.Printf("find blobs without media metadata failed: %v", err)
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			if err := s.extractMetadata(id); err != nil {
+				log.Printf("extract media metadata of blob %d failed: %v", id, err)
+				return
+			}
+			afterID = id
+		}
+	}
+}
+
+// extractMetadata 提取对象的媒体元数据并保存。不支持的格式和解析失败（含解析时 panic）也会记录，之后不再重试；
+// 只有数据库出错时返回错误
+func (s *FileService) extractMetadata(blobID uint) error {
+	file, err := s.fileRepo.FindAnyByBlob(blobID)
+	if err != nil {
+		return nil // 引用它的文件已被删除
+	}
+	kind, format := mediaFormat(file)
+	meta := &model.MediaMetadata{BlobID: blobID, Kind: kind}
+	if kind != "" {
+		// 解析器处理的是用户上传的任意内容，异常输入导致的 panic 记为解析失败，不影响服务也不再重试
+		err := func() (err error) {
+			reader := storage.NewRangeReader(s.storage, file.StoragePath, file.Size)
+			defer reader.Close()
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("read metadata panicked: %v", r)
+				}
+			}()
+			return readMetadata(format, reader, meta)
+		}()
+		if err != nil {
+			*meta = model.MediaMetadata{BlobID: blobID, Kind: kind, Error: truncate(err.Error(), 255)}
+		}
+		if meta.TakenAt != nil {
+			// 统一存为 UTC，SQLite 按文本比较时间，时区不同时范围查询会出错
+			t := meta.TakenAt.UTC()
+			meta.TakenAt = &t
+			if meta.TakenAtLocal == nil {
+				zoned := false
+				meta.TakenAtLocal = &zoned
+			}
+		}
+	}
+	_, err = s.metaRepo.Save(meta)
+	return err
+}
+
+// readMetadata 按格式读取元数据
+func readMetadata(format string, r *storage.RangeReader, meta *model.MediaMetadata) error {
+	switch format {
+	case "mp3":
+		return readMP3(r, r.Size(), meta)
+	case "flac":
+		return readFLAC(r, r.Size(), meta)
+	case "mp4":
+		if err := readMP4(r, r.Size(), meta); err != nil {
+			return err
+		}
+		if meta.Kind == model.MediaAudio {
+			meta.Width, meta.Height, meta.TakenAt = 0, 0, nil // 音频文件的创建时间没有意义
+		}
+		return nil
+	case "pdf":
+		return readPDF(r, r.Size(), meta)
+	}
+	return readImageMetadata(format, r, meta)
+}
+
+// readImageMetadata 读取图片的尺寸与 EXIF 信息，尺寸为按方向摆正后的
+func readImageMetadata(format string, r io.ReadSeeker, meta *model.MediaMetadata) error {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	exif := parseExif(imageExif(format, r))
+	meta.Width, meta.Height = config.Width, config.Height
+	if exif.orientation >= 5 {
+		meta.Width, meta.Height = meta.Height, meta.Width
+	}
+	meta.CameraMake = truncate(exif.make, 64)
+	meta.CameraModel = truncate(exif.model, 64)
+	meta.TakenAt = exif.takenAt
+	if exif.takenAt != nil {
+		meta.TakenAtLocal = &exif.takenLocal
+	}
+	meta.Latitude, meta.Longitude = exif.latitude, exif.longitude
+	return nil
+}
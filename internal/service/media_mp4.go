@@ -0,0 +1,216 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+)
+
+const maxMoovBytes = 32 << 20 // moov 盒子（索引与元数据）的大小上限
+
+var errNotMP4 = errors.New("not a recognized MP4 file")
+
+// mp4Epoch MP4 时间字段的起点
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// iso6709 ©xyz 中的位置，如 "+39.9042+116.4074/"
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+// mp4Box 盒子在数据中的范围（不含头部）
+type mp4Box struct {
+	typ        string
+	start, end int64
+}
+
+// readMP4Box 读取 pos 处的盒子头，limit 为所在容器的结束位置
+func readMP4Box(r io.ReaderAt, pos, limit int64) (mp4Box, bool) {
+	var header [16]byte
+	if pos+8 > limit {
+		return mp4Box{}, false
+	}
+	if _, err := r.ReadAt(header[:8], pos); err != nil {
+		return mp4Box{}, false
+	}
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	box := mp4Box{typ: string(header[4:8]), start: pos + 8}
+	switch size {
+	case 0: // 延续到容器末尾
+		box.end = limit
+	case 1: // 64 位大小
+		if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+			return mp4Box{}, false
+		}
+		box.start += 8
+		box.end = pos + int64(binary.BigEndian.Uint64(header[8:16]))
+	default:
+		box.end = pos + size
+	}
+	if box.end < box.start || box.end > limit {
+		return mp4Box{}, false
+	}
+	return box, true
+}
+
+// readMP4 读取 MP4、M4A 与 QuickTime 文件 moov 盒子中的时长、创建时间、画面尺寸、iTunes 标签与位置
+func readMP4(r io.ReaderAt, size int64, meta *model.MediaMetadata) error {
+	var moov []byte
+	isMP4 := false
+	for pos := int64(0); pos < size; {
+		box, ok := readMP4Box(r, pos, size)
+		if !ok {
+			break
+		}
+		switch box.typ {
+		case "ftyp":
+			isMP4 = true
+		case "moov":
+			if box.end-box.start > maxMoovBytes {
+				return errors.New("moov box too large")
+			}
+			moov = make([]byte, box.end-box.start)
+			if _, err := r.ReadAt(moov, box.start); err != nil {
+				return err
+			}
+		}
+		if moov != nil {
+			break
+		}
+		pos = box.end
+	}
+	if moov == nil {
+		if isMP4 {
+			return errors.New("moov box not found")
+		}
+		return errNotMP4
+	}
+	parseMoov(moov, meta)
+	return nil
+}
+
+// parseMoov 递归遍历 moov 中需要的盒子
+func parseMoov(data []byte, meta *model.MediaMetadata) {
+	walkMP4(data, func(typ string, body []byte) bool {
+		switch typ {
+		case "mvhd":
+			parseMvhd(body, meta)
+		case "tkhd":
+			// 取第一条有画面的轨道的尺寸
+			if w, h := tkhdSize(body); w > 0 && h > 0 && meta.Width == 0 {
+				meta.Width, meta.Height = w, h
+			}
+		case "trak", "udta", "ilst":
+			return true
+		case "meta":
+			// ISO 的 meta 是 FullBox（4 字节版本与标志），QuickTime 的不是
+			if len(body) >= 4 && binary.BigEndian.Uint32(body) == 0 {
+				body = body[4:]
+			}
+			parseMoov(body, meta)
+		case "\xa9nam":
+			setIfEmpty(&meta.Title, ilstText(body))
+		case "\xa9ART", "aART":
+			setIfEmpty(&meta.Artist, ilstText(body))
+		case "\xa9alb":
+			setIfEmpty(&meta.Album, ilstText(body))
+		case "\xa9xyz":
+			// QuickTime 的用户数据：2 字节长度、2 字节语言，之后为文本
+			if len(body) > 4 {
+				if m := iso6709.FindStringSubmatch(string(body[4:])); m != nil {
+					lat, err1 := strconv.ParseFloat(m[1], 64)
+					lon, err2 := strconv.ParseFloat(m[2], 64)
+					if err1 == nil && err2 == nil && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+						meta.Latitude, meta.Longitude = &lat, &lon
+					}
+				}
+			}
+		}
+		return false
+	})
+}
+
+// walkMP4 遍历内存中的盒子，fn 返回 true 时进入该盒子的子盒子
+func walkMP4(data []byte, fn func(typ string, body []byte) bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size, headerLen = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return
+		}
+		body := data[headerLen:size]
+		if fn(typ, body) {
+			walkMP4(body, fn)
+		}
+		data = data[size:]
+	}
+}
+
+// parseMvhd 读取影片时长与创建时间
+func parseMvhd(body []byte, meta *model.MediaMetadata) {
+	var created, timescale, duration uint64
+	switch {
+	case len(body) >= 32 && body[0] == 1:
+		created = binary.BigEndian.Uint64(body[4:])
+		timescale = uint64(binary.BigEndian.Uint32(body[20:]))
+		duration = binary.BigEndian.Uint64(body[24:])
+	case len(body) >= 20 && body[0] == 0:
+		created = uint64(binary.BigEndian.Uint32(body[4:]))
+		timescale = uint64(binary.BigEndian.Uint32(body[12:]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:]))
+	default:
+		return
+	}
+	if timescale > 0 {
+		meta.Duration = float64(duration) / float64(timescale)
+	}
+	// 很多编码器不写创建时间（为 0），只接受 1970 年之后的值
+	if t := mp4Epoch.Add(time.Duration(created) * time.Second); created < 1<<33 && t.After(time.Unix(0, 0)) {
+		meta.TakenAt = &t
+	}
+}
+
+// tkhdSize 读取轨道的画面宽高（16.16 定点数），音频轨道为 0
+func tkhdSize(body []byte) (int, int) {
+	offset := 76 // 版本 0：版本与标志 4、时间与 ID 等 20、保留 8、层等 8、矩阵 36
+	if len(body) > 0 && body[0] == 1 {
+		offset = 88
+	}
+	if len(body) < offset+8 {
+		return 0, 0
+	}
+	return int(binary.BigEndian.Uint32(body[offset:]) >> 16), int(binary.BigEndian.Uint32(body[offset+4:]) >> 16)
+}
+
+// ilstText 读取 iTunes 标签中 data 盒子的 UTF-8 文本
+func ilstText(body []byte) string {
+	var text string
+	walkMP4(body, func(typ string, data []byte) bool {
+		// 4 字节类型（1 为 UTF-8）与 4 字节语言
+		if typ == "data" && text == "" && len(data) >= 8 && binary.BigEndian.Uint32(data)&0xFFFFFF == 1 {
+			text = strings.TrimSpace(strings.ToValidUTF8(string(data[8:]), ""))
+		}
+		return false
+	})
+	return truncate(text, 255)
+}
+
+func setIfEmpty(target *string, value string) {
+	if *target == "" {
+		*target = value
+	}
+}
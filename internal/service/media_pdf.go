@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"online-disk-server/internal/model"
+)
+
+const (
+	maxPDFBytes       = 64 << 20 // 超过该大小的 PDF 不解析
+	maxPDFObjStmBytes = 16 << 20 // 解压对象流的总大小上限
+)
+
+var (
+	errNotPDF = errors.New("not a PDF file")
+
+	pdfObject    = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	pdfPagesType = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfPageType  = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfCount     = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfInfoRef   = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfTitle     = regexp.MustCompile(`/Title\s*(?:(\d+)\s+\d+\s+R|([(<]))`)
+	pdfObjStm    = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	pdfStmFirst  = regexp.MustCompile(`/First\s+(\d+)`)
+	pdfStmN      = regexp.MustCompile(`/N\s+(\d+)`)
+	pdfStream    = regexp.MustCompile(`stream\r?\n`)
+)
+
+// readPDF 读取 PDF 的页数与文档信息中的标题。对象可以直接写在文件中，也可以压缩在对象流中
+func readPDF(r io.ReaderAt, size int64, meta *model.MediaMetadata) error {
+	if size > maxPDFBytes {
+		return errors.New("file too large")
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return errNotPDF
+	}
+	objects := pdfObjects(data)
+
+	// 页面树的根节点的 /Count 为总页数，是所有 /Pages 节点中最大的
+	for _, obj := range objects {
+		if pdfPagesType.Match(obj) {
+			if m := pdfCount.FindSubmatch(obj); m != nil {
+				if n, err := strconv.Atoi(string(m[1])); err == nil && n > meta.PageCount {
+					meta.PageCount = n
+				}
+			}
+		}
+	}
+	if meta.PageCount == 0 {
+		for _, obj := range objects {
+			if pdfPageType.Match(obj) {
+				meta.PageCount++
+			}
+		}
+	}
+
+	// 文件可能经过增量更新，使用最后一个 /Info。加密的文档字符串也被加密，不读取标题
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil
+	}
+	refs := pdfInfoRef.FindAllSubmatch(data, -1)
+	if len(refs) == 0 {
+		return nil
+	}
+	info, ok := objects[string(refs[len(refs)-1][1])]
+	if !ok {
+		return nil
+	}
+	m := pdfTitle.FindSubmatchIndex(info)
+	if m == nil {
+		return nil
+	}
+	value := info[m[1]-1:]
+	if m[2] >= 0 { // 间接引用
+		if value, ok = objects[string(info[m[2]:m[3]])]; !ok {
+			return nil
+		}
+		value = bytes.TrimLeft(value, " \t\r\n")
+	}
+	meta.Title = truncate(pdfString(value), 255)
+	return nil
+}
+
+// pdfObjects 返回按对象编号索引的对象内容（流对象只包含字典部分），后出现的同号对象覆盖先前的
+func pdfObjects(data []byte) map[string][]byte {
+	objects := make(map[string][]byte)
+	matches := pdfObject.FindAllSubmatchIndex(data, -1)
+	inflated := 0
+	for i, m := range matches {
+		end := len(data)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		body := data[m[1]:end]
+		if j := bytes.Index(body, []byte("endobj")); j >= 0 {
+			body = body[:j]
+		}
+		dict := body
+		var stream []byte
+		if loc := pdfStream.FindIndex(body); loc != nil {
+			dict, stream = body[:loc[0]], body[loc[1]:]
+		}
+		objects[string(data[m[2]:m[3]])] = dict
+
+		if stream != nil && pdfObjStm.Match(dict) && bytes.Contains(dict, []byte("/FlateDecode")) && inflated < maxPDFObjStmBytes {
+			content, err := inflate(stream, maxPDFObjStmBytes-inflated)
+			if err != nil {
+				continue
+			}
+			inflated += len(content)
+			objStmObjects(dict, content, objects)
+		}
+	}
+	return objects
+}
+
+// objStmObjects 拆分对象流：开头为 N 对 "对象编号 偏移"，对象内容从 /First 处开始
+func objStmObjects(dict, content []byte, objects map[string][]byte) {
+	first, n := pdfStmFirst.FindSubmatch(dict), pdfStmN.FindSubmatch(dict)
+	if first == nil || n == nil {
+		return
+	}
+	base, _ := strconv.Atoi(string(first[1]))
+	count, _ := strconv.Atoi(string(n[1]))
+	if base > len(content) {
+		return
+	}
+	fields := bytes.Fields(content[:base])
+	type entry struct {
+		num    string
+		offset int
+	}
+	var entries []entry
+	for i := 0; i+1 < len(fields) && i/2 < count; i += 2 {
+		offset, err := strconv.Atoi(string(fields[i+1]))
+		if err != nil || base+offset > len(content) {
+			return
+		}
+		entries = append(entries, entry{string(fields[i]), base + offset})
+	}
+	for i, e := range entries {
+		end := len(content)
+		if i+1 < len(entries) && entries[i+1].offset >= e.offset {
+			end = entries[i+1].offset
+		}
+		objects[e.num] = content[e.offset:end]
+	}
+}
+
+// inflate 解压 zlib 数据，最多输出 limit 字节；流之后的数据被忽略
+func inflate(data []byte, limit int) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, io.LimitReader(zr, int64(limit)))
+	if err != nil && buf.Len() == 0 {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfString 解析 value 开头的字面量字符串 (...) 或十六进制字符串 <...>，
+// 以 UTF-16BE BOM 开头时按 UTF-16 解码，否则按 UTF-8 或 Latin-1
+func pdfString(value []byte) string {
+	var raw []byte
+	switch {
+	case len(value) > 0 && value[0] == '(':
+		raw = pdfLiteral(value[1:])
+	case len(value) > 0 && value[0] == '<':
+		end := bytes.IndexByte(value, '>')
+		if end < 0 {
+			return ""
+		}
+		hex := bytes.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F' {
+				return r
+			}
+			return -1
+		}, value[1:end])
+		if len(hex)%2 == 1 {
+			hex = append(hex, '0')
+		}
+		raw = make([]byte, len(hex)/2)
+		for i := range raw {
+			b, _ := strconv.ParseUint(string(hex[i*2:i*2+2]), 16, 8)
+			raw[i] = byte(b)
+		}
+	default:
+		return ""
+	}
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		return utf16Text(raw, true)
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		return string(bytes.ToValidUTF8(raw[3:], nil))
+	case utf8.Valid(raw):
+		return string(raw)
+	}
+	return latin1(raw)
+}
+
+// pdfLiteral 解析字面量字符串的内容（左括号之后），处理转义与嵌套的括号
+func pdfLiteral(s []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n': // 续行
+				if e == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' { // 最多三位八进制
+					v := 0
+					j := i
+					for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+						v = v*8 + int(s[j]-'0')
+					}
+					out = append(out, byte(v))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"online-disk-server/internal/auth"
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidShare         = errors.New("invalid share options")
+	ErrShareExpired         = errors.New("share link has expired")
+	ErrShareExhausted       = errors.New("share link download limit reached")
+	ErrSharePassword        = errors.New("incorrect share password")
+	ErrShareNotPermitted    = errors.New("share link does not allow this operation")
+	ErrOutsideShare         = errors.New("file is not part of this share")
+	ErrSharePasswordMissing = errors.New("share is protected by a password")
+	ErrShareUnlockThrottled = errors.New("too many incorrect share passwords, try again later")
+)
+
+const (
+	// shareUnlockWindow 统计分享密码错误次数的时间窗口
+	shareUnlockWindow = 15 * time.Minute
+	// shareUnlockMaxPerClient 同一来源在窗口内对同一分享允许的密码错误次数
+	shareUnlockMaxPerClient = 5
+	// shareUnlockMaxPerShare 窗口内对同一分享允许的密码错误总次数，防止更换来源地址绕过限制
+	shareUnlockMaxPerShare = 50
+)
+
+// ShareOptions 创建分享链接时的可选限制
+type ShareOptions struct {
+	Password     string     // 为空表示无需密码
+	ExpiresAt    *time.Time // 为空表示永不过期
+	MaxDownloads int        // 0 表示不限制下载次数
+	Permission   string     // 默认只读
+}
+
+// SharedFile 通过分享链接公开的文件信息，不包含所有者和存储信息。
+// Path 以分享的文件或文件夹自身为第一级
+type SharedFile struct {
+	ID        uint      `json:"id"`
+	ParentID  uint      `json:"parent_id,omitempty"` // 分享的根不返回上级目录
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	IsDir     bool      `json:"is_dir"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShareService 管理分享链接，并在不登录的情况下按链接访问分享内容。
+// 通过链接进行的所有文件操作都以分享者的身份执行，且限制在分享的子树内
+type ShareService struct {
+	shareRepo *repository.ShareRepository
+	files     *FileService
+
+	failuresMu sync.Mutex
+	failures   map[string]*unlockFailures // 按分享和来源统计的密码错误次数
+}
+
+func NewShareService(db *gorm.DB, files *FileService) *ShareService {
+	return &ShareService{
+		shareRepo: repository.NewShareRepository(db),
+		files:     files,
+		failures:  make(map[string]*unlockFailures),
+	}
+}
+
+// unlockFailures 一个时间窗口内的密码错误次数
+type unlockFailures struct {
+	count   int
+	resetAt time.Time
+}
+
+// Create 为用户自己的文件或文件夹创建分享链接。上传权限只能用于文件夹
+func (s *ShareService) Create(userID, fileID uint, opts ShareOptions) (*model.Share, error) {
+	file, err := s.files.fileRepo.FindByIDAndUser(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Permission == "" {
+		opts.Permission = model.SharePermissionRead
+	}
+	switch {
+	case opts.Permission != model.SharePermissionRead && opts.Permission != model.SharePermissionUpload:
+		return nil, fmt.Errorf("%w: permission must be read or upload", ErrInvalidShare)
+	case opts.Permission == model.SharePermissionUpload && !file.IsDir:
+		return nil, fmt.Errorf("%w: upload permission requires a folder", ErrInvalidShare)
+	case opts.MaxDownloads < 0:
+		return nil, fmt.Errorf("%w: max_downloads must not be negative", ErrInvalidShare)
+	case opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidShare)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	share := &model.Share{
+		Token:        token,
+		UserID:       userID,
+		FileID:       file.ID,
+		ExpiresAt:    opts.ExpiresAt,
+		MaxDownloads: opts.MaxDownloads,
+		Permission:   opts.Permission,
+	}
+	if opts.Password != "" {
+		if share.PasswordHash, err = auth.HashPassword(opts.Password); err != nil {
+			return nil, err
+		}
+		share.HasPassword = true
+	}
+	if err := s.shareRepo.Create(share); err != nil {
+		return nil, err
+	}
+	if err := s.files.fileRepo.SetPublic(file.ID, true); err != nil {
+		return nil, err
+	}
+	share.FileName, share.IsDir = file.Name, file.IsDir
+	return share, nil
+}
+
+// List 分页列出用户创建的分享。所分享的文件在回收站中时不返回文件名
+func (s *ShareService) List(userID uint, page, limit int) ([]*model.Share, int64, error) {
+	offset := (page - 1) * limit
+	shares, total, err := s.shareRepo.FindByUser(userID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, share := range shares {
+		if file, err := s.files.fileRepo.FindByIDAndUser(share.FileID, userID); err == nil {
+			share.FileName, share.IsDir = file.Name, file.IsDir
+		}
+	}
+	return shares, total, nil
+}
+
+// Revoke 撤销分享链接，链接立即失效
+func (s *ShareService) Revoke(userID, shareID uint) error {
+	share, err := s.shareRepo.FindByIDAndUser(shareID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.shareRepo.Delete(share.ID); err != nil {
+		return err
+	}
+	remaining, err := s.shareRepo.CountByFile(share.FileID)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return s.files.fileRepo.SetPublic(share.FileID, false)
+	}
+	return nil
+}
+
+// Open 按 token 查找仍然有效的分享及其分享的文件或文件夹。密码由调用方另行校验
+func (s *ShareService) Open(token string) (*model.Share, *model.File, error) {
+	share, err := s.shareRepo.FindByToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if share.ExpiresAt != nil && !share.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrShareExpired
+	}
+	if share.MaxDownloads > 0 && share.DownloadCount >= share.MaxDownloads {
+		return nil, nil, ErrShareExhausted
+	}
+	root, err := s.files.fileRepo.FindByIDAndUser(share.FileID, share.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return share, root, nil
+}
+
+// CheckPassword 校验访问密码；未设置密码的分享总是通过。client 为请求来源（如客户端IP），
+// 同一来源或同一分享在时间窗口内密码错误次数过多时返回 ErrShareUnlockThrottled，并返回需等待的时长
+func (s *ShareService) CheckPassword(share *model.Share, password, client string) (time.Duration, error) {
+	if !share.HasPassword {
+		return 0, nil
+	}
+	if password == "" {
+		return 0, ErrSharePasswordMissing
+	}
+	shareKey := strconv.FormatUint(uint64(share.ID), 10)
+	clientKey := shareKey + "|" + client
+	if wait := s.throttled(shareKey, clientKey); wait > 0 {
+		return wait, ErrShareUnlockThrottled
+	}
+	if auth.CheckPassword(share.PasswordHash, password) != nil {
+		s.recordFailure(shareKey, clientKey)
+		return 0, ErrSharePassword
+	}
+	return 0, nil
+}
+
+// throttled 同一分享或同一来源的密码错误次数达到上限时，返回到窗口结束前需等待的时长，否则返回 0
+func (s *ShareService) throttled(shareKey, clientKey string) time.Duration {
+	s.failuresMu.Lock()
+	defer s.failuresMu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for key, limit := range map[string]int{shareKey: shareUnlockMaxPerShare, clientKey: shareUnlockMaxPerClient} {
+		if f, ok := s.failures[key]; ok && now.Before(f.resetAt) && f.count >= limit {
+			wait = max(wait, f.resetAt.Sub(now))
+		}
+	}
+	return wait
+}
+
+// recordFailure 为各 key 记录一次密码错误，窗口过期的记录重新计数
+func (s *ShareService) recordFailure(keys ...string) {
+	s.failuresMu.Lock()
+	defer s.failuresMu.Unlock()
+	now := time.Now()
+	if len(s.failures) > 10000 {
+		for k, f := range s.failures {
+			if !now.Before(f.resetAt) {
+				delete(s.failures, k)
+			}
+		}
+	}
+	for _, key := range keys {
+		f, ok := s.failures[key]
+		if !ok || !now.Before(f.resetAt) {
+			f = &unlockFailures{resetAt: now.Add(shareUnlockWindow)}
+			s.failures[key] = f
+		}
+		f.count++
+	}
+}
+
+// Resolve 查找分享范围内的文件；fileID 为 0 时返回分享的根
+func (s *ShareService) Resolve(share *model.Share, root *model.File, fileID uint) (*model.File, error) {
+	if fileID == 0 || fileID == root.ID {
+		return root, nil
+	}
+	if !root.IsDir {
+		return nil, ErrOutsideShare
+	}
+	file, err := s.files.fileRepo.FindByIDAndUser(fileID, share.UserID)
+	if err != nil {
+		return nil, err
+	}
+	within, err := s.files.isWithin(share.UserID, root.ID, file)
+	if err != nil {
+		return nil, err
+	}
+	if !within {
+		return nil, ErrOutsideShare
+	}
+	return file, nil
+}
+
+// ListFolder 列出分享范围内某个文件夹的内容；folderID 为 0 时列出分享的根
+func (s *ShareService) ListFolder(share *model.Share, root *model.File, folderID uint, page, limit int) ([]*SharedFile, int64, error) {
+	folder, err := s.Resolve(share, root, folderID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !folder.IsDir {
+		return nil, 0, ErrNotAFolder
+	}
+	files, total, err := s.files.ListFiles(share.UserID, folder.ID, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]*SharedFile, 0, len(files))
+	for _, f := range files {
+		result = append(result, SharedView(root, f))
+	}
+	return result, total, nil
+}
+
+// CountDownload 记录一次下载，已达到下载次数上限时返回 ErrShareExhausted
+func (s *ShareService) CountDownload(share *model.Share) error {
+	ok, err := s.shareRepo.IncrementDownloads(share.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrShareExhausted
+	}
+	return nil
+}
+
+// Upload 通过具有上传权限的分享向其中的文件夹上传文件。通过链接上传不得覆盖分享者已有的文件，
+// 因此重名时总是自动重命名
+func (s *ShareService) Upload(share *model.Share, root *model.File, parentID uint, file *multipart.FileHeader) (*model.File, error) {
+	if share.Permission != model.SharePermissionUpload {
+		return nil, ErrShareNotPermitted
+	}
+	parent, err := s.Resolve(share, root, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsDir {
+		return nil, ErrNotAFolder
+	}
+	return s.files.UploadFile(share.UserID, file, parent.ID, ConflictRename)
+}
+
+// SharedView 将文件转换为通过分享公开的信息，路径相对于分享的根
+func SharedView(root, file *model.File) *SharedFile {
+	view := &SharedFile{
+		ID:        file.ID,
+		Name:      file.Name,
+		Path:      "/" + root.Name + strings.TrimPrefix(file.Path, root.Path),
+		Size:      file.Size,
+		MimeType:  file.MimeType,
+		IsDir:     file.IsDir,
+		UpdatedAt: file.UpdatedAt,
+	}
+	if file.ID != root.ID {
+		view.ParentID = file.ParentID
+	}
+	return view
+}
+
+// newShareToken 生成 URL 安全的随机分享 token（128 位）
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
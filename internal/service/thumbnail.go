@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器，取第一帧
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/storage"
+
+	_ "golang.org/x/image/bmp" // 注册 BMP 解码器
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedImage     = errors.New("thumbnails are only available for JPEG, PNG, GIF, WebP and BMP images")
+	ErrInvalidImage         = errors.New("invalid image")
+	ErrInvalidThumbnailSize = errors.New("invalid thumbnail size")
+)
+
+// 缩略图尺寸
+const (
+	ThumbnailSmall  = "small"
+	ThumbnailMedium = "medium"
+	ThumbnailLarge  = "large"
+)
+
+// thumbnailSizes 各尺寸缩略图长边的最大像素，从大到小排列，较小的尺寸由上一级缩小得到。
+// 原图更小时不放大
+var thumbnailSizes = []struct {
+	name string
+	max  int
+}{
+	{ThumbnailLarge, 1024},
+	{ThumbnailMedium, 256},
+	{ThumbnailSmall, 128},
+}
+
+const (
+	maxImageSourcePixels = 50_000_000 // 原图像素上限，避免解码超大图片占用过多内存
+	maxImageJobs         = 2          // 同时解码的图片数
+	thumbnailQueueSize   = 256
+	thumbnailJPEGQuality = 85
+)
+
+// renderedImage 生成的缩略图或变换结果
+type renderedImage struct {
+	size     string
+	mimeType string
+	width    int
+	height   int
+	data     []byte
+}
+
+// imageFormat 根据文件名和 MIME 类型判断是否为支持生成缩略图的图片，返回解码器名称；不支持时返回空字符串
+func imageFormat(file *model.File) string {
+	switch strings.ToLower(path.Ext(file.Name)) {
+	case ".jpg", ".jpeg", ".jpe", ".jfif":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".gif":
+		return "gif"
+	case ".webp":
+		return "webp"
+	case ".bmp", ".dib":
+		return "bmp"
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(file.MimeType, ";", 2)[0])) {
+	case "image/jpeg", "image/pjpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	case "image/bmp", "image/x-ms-bmp":
+		return "bmp"
+	}
+	return ""
+}
+
+// Thumbnail 返回图片文件指定尺寸的缩略图及其内容。已生成的缩略图直接从存储读取，
+// 否则（如功能上线前上传的图片）当场生成全部尺寸并保存
+func (s *FileService) Thumbnail(userID, fileID uint, size string) (*model.Thumbnail, []byte, *model.File, error) {
+	if !validThumbnailSize(size) {
+		return nil, nil, nil, fmt.Errorf("%w: size must be small, medium or large", ErrInvalidThumbnailSize)
+	}
+	file, err := s.accessFile(userID, fileID, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if file.IsDir || imageFormat(file) == "" {
+		return nil, nil, nil, ErrUnsupportedImage
+	}
+
+	if file.BlobID == 0 {
+		// 早于内容寻址存储的旧记录没有对应的对象，生成后不保存
+		rendered, err := s.renderThumbnails(file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, r := range rendered {
+			if r.size == size {
+				thumb := &model.Thumbnail{Size: r.size, MimeType: r.mimeType, Width: r.width, Height: r.height, Bytes: int64(len(r.data))}
+				return thumb, r.data, file, nil
+			}
+		}
+		return nil, nil, nil, gorm.ErrRecordNotFound
+	}
+
+	thumb, err := s.thumbRepo.FindByBlobAndSize(file.BlobID, size)
+	if err == nil && thumb.Error != "" {
+		err = fmt.Errorf("%w: %s", ErrInvalidImage, thumb.Error)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var thumbs []*model.Thumbnail
+		if thumbs, err = s.ensureThumbnails(file); err == nil {
+			thumb, err = pickThumbnail(thumbs, size)
+		}
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rc, err := s.storage.Download(thumb.StoragePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return thumb, data, file, nil
+}
+
+func validThumbnailSize(size string) bool {
+	for _, ts := range thumbnailSizes {
+		if ts.name == size {
+			return true
+		}
+	}
+	return false
+}
+
+func pickThumbnail(thumbs []*model.Thumbnail, size string) (*model.Thumbnail, error) {
+	for _, t := range thumbs {
+		if t.Size == size {
+			return t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// queueThumbnails 图片写入后排队在后台生成缩略图；队列已满时跳过，首次请求时再生成
+func (s *FileService) queueThumbnails(file *model.File) {
+	if file.BlobID == 0 || imageFormat(file) == "" {
+		return
+	}
+	select {
+	case s.thumbQueue <- file.BlobID:
+	default:
+	}
+}
+
+// StartThumbnailWorker 启动后台缩略图生成，并清理对象已不存在的缩略图与图片变换结果
+func (s *FileService) StartThumbnailWorker() {
+	s.blobs.PurgeOrphanImages()
+	go func() {
+		for blobID := range s.thumbQueue {
+			s.generateThumbnails(blobID)
+		}
+	}()
+}
+
+// generateThumbnails 后台为一个对象生成缩略图。无法解码的图片由 ensureThumbnails 记录失败，
+// 其他意外的 panic 也只记录日志，不影响后续对象
+func (s *FileService) generateThumbnails(blobID uint) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("generate thumbnails of blob %d panicked: %v", blobID, r)
+		}
+	}()
+	file, err := s.fileRepo.FindAnyByBlob(blobID)
+	if err != nil {
+		return // 文件已被删除
+	}
+	if _, err := s.ensureThumbnails(file); err != nil {
+		log.Printf("generate thumbnails of blob %d failed: %v", blobID, err)
+	}
+}
+
+// ensureThumbnails 返回文件内容对应的全部尺寸的缩略图，缺少时生成并保存。
+// 同一内容的并发请求只生成一次
+func (s *FileService) ensureThumbnails(file *model.File) ([]*model.Thumbnail, error) {
+	key := "thumbnail:" + strconv.FormatUint(uint64(file.BlobID), 10)
+	v, err, _ := s.imageFlight.Do(key, func() (interface{}, error) {
+		thumbs, err := s.thumbRepo.FindByBlobs([]uint{file.BlobID})
+		if err != nil || len(thumbs) == len(thumbnailSizes) {
+			return thumbs, err
+		}
+		for _, t := range thumbs {
+			if t.Error != "" {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidImage, t.Error)
+			}
+		}
+		rendered, err := s.renderThumbnails(file)
+		if errors.Is(err, ErrInvalidImage) {
+			// 内容本身无法处理，记录失败以免每次请求或重新排队时再次解码
+			s.saveThumbnailFailure(file.BlobID, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumbs = thumbs[:0]
+		for _, r := range rendered {
+			storagePath := thumbnailStoragePath(file.StoragePath, r.size)
+			if err := s.storage.Upload(storagePath, bytes.NewReader(r.data), int64(len(r.data))); err != nil {
+				return nil, err
+			}
+			thumbs = append(thumbs, &model.Thumbnail{
+				BlobID:      file.BlobID,
+				Size:        r.size,
+				StoragePath: storagePath,
+				MimeType:    r.mimeType,
+				Width:       r.width,
+				Height:      r.height,
+				Bytes:       int64(len(r.data)),
+			})
+		}
+		return thumbs, s.thumbRepo.CreateAll(thumbs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*model.Thumbnail), nil
+}
+
+// saveThumbnailFailure 为对象的每个尺寸保存一条没有存储对象、带失败原因的记录
+func (s *FileService) saveThumbnailFailure(blobID uint, cause error) {
+	thumbs := make([]*model.Thumbnail, 0, len(thumbnailSizes))
+	for _, ts := range thumbnailSizes {
+		thumbs = append(thumbs, &model.Thumbnail{BlobID: blobID, Size: ts.name, Error: truncate(cause.Error(), 255)})
+	}
+	if err := s.thumbRepo.CreateAll(thumbs); err != nil {
+		log.Printf("save thumbnail failure of blob %d failed: %v", blobID, err)
+	}
+}
+
+// thumbnailStoragePath 缩略图的存储路径，由对象的存储路径（含随机后缀）派生，与对象一一对应
+func thumbnailStoragePath(blobPath, size string) string {
+	return "thumbnails/" + strings.TrimPrefix(blobPath, "blobs/") + "-" + size
+}
+
+// decodeImage 解码图片文件，返回图像及其 EXIF 方向。像素数超出上限的图片不解码；
+// 解码器与 EXIF 解析处理的是用户上传的任意内容，遇到异常输入 panic 时同样返回 ErrInvalidImage。
+// 调用方需占用一个 imageSlots 名额
+func (s *FileService) decodeImage(file *model.File) (src image.Image, orientation int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			src, orientation, err = nil, 0, fmt.Errorf("%w: decoder panicked: %v", ErrInvalidImage, r)
+		}
+	}()
+	reader := storage.NewRangeReader(s.storage, file.StoragePath, file.Size)
+	defer reader.Close()
+	config, format, err := image.DecodeConfig(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxImageSourcePixels {
+		return nil, 0, fmt.Errorf("%w: %dx%d pixels exceeds the limit", ErrInvalidImage, config.Width, config.Height)
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	orientation = imageOrientation(format, reader)
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	src, _, err = image.Decode(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return src, orientation, nil
+}
+
+// renderThumbnails 解码图片并按 EXIF 方向摆正，生成全部尺寸的缩略图
+func (s *FileService) renderThumbnails(file *model.File) ([]*renderedImage, error) {
+	s.imageSlots <- struct{}{}
+	defer func() { <-s.imageSlots }()
+
+	src, orientation, err := s.decodeImage(file)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := make([]*renderedImage, 0, len(thumbnailSizes))
+	current := src
+	for i, ts := range thumbnailSizes {
+		w, h := fitWithin(current.Bounds().Dx(), current.Bounds().Dy(), ts.max)
+		scaled := scaleImage(current, current.Bounds(), w, h)
+		if i == 0 {
+			// 方形边界框与方向无关，缩小后再旋转，处理的像素更少
+			scaled = applyOrientation(scaled, orientation)
+		}
+		data, mimeType, err := encodeImage(scaled, ImageFormatAuto, thumbnailJPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, &renderedImage{
+			size:     ts.name,
+			mimeType: mimeType,
+			width:    scaled.Bounds().Dx(),
+			height:   scaled.Bounds().Dy(),
+			data:     data,
+		})
+		current = scaled
+	}
+	return rendered, nil
+}
+
+// fitWithin 等比缩放 w×h 使长边不超过 limit，不放大
+func fitWithin(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(h*limit/w, 1)
+	}
+	return max(w*limit/h, 1), limit
+}
+
+// scaleImage 将图片中 b 区域缩放为 w×h。缩小倍数较大时先用双线性快速缩小到目标的两倍，再用 Catmull-Rom 精细缩放
+func scaleImage(src image.Image, b image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if b.Dx() == w && b.Dy() == h {
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return dst
+	}
+	if b.Dx() >= w*4 && b.Dy() >= h*4 {
+		mid := image.NewRGBA(image.Rect(0, 0, w*2, h*2))
+		draw.ApproxBiLinear.Scale(mid, mid.Bounds(), src, b, draw.Src, nil)
+		src, b = mid, mid.Bounds()
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+)
+
+var ErrInvalidTimeline = errors.New("invalid timeline parameters")
+
+// 时间线的分组粒度
+const (
+	TimelineByMonth = "month"
+	TimelineByDay   = "day"
+)
+
+// TimelineOptions 时间线的过滤与分组方式
+type TimelineOptions struct {
+	Media    string // image 或 video，空表示两者
+	Group    string // month（默认）或 day
+	TimeZone string // 按该时区（IANA 名称）划分日期，默认 UTC
+}
+
+// TimelineItem 时间线中的一个文件及其拍摄时间（没有拍摄时间时为上传时间）
+type TimelineItem struct {
+	*model.File
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// TimelineBucket 一个月或一天中的文件。Cursor 可用于从该日期开始浏览时间线
+type TimelineBucket struct {
+	Date   string          `json:"date"` // 2006-01 或 2006-01-02
+	Count  int             `json:"count"`
+	Cursor string          `json:"cursor,omitempty"`
+	Items  []*TimelineItem `json:"items,omitempty"`
+}
+
+// timelineLayout 返回分组使用的日期格式
+func timelineLayout(opts TimelineOptions) (string, *time.Location, error) {
+	layout := "2006-01"
+	switch opts.Group {
+	case "", TimelineByMonth:
+	case TimelineByDay:
+		layout = time.DateOnly
+	default:
+		return "", nil, fmt.Errorf("%w: group must be month or day", ErrInvalidTimeline)
+	}
+	switch opts.Media {
+	case "", model.MediaImage, model.MediaVideo:
+	default:
+		return "", nil, fmt.Errorf("%w: media must be image or video", ErrInvalidTimeline)
+	}
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: unknown time zone", ErrInvalidTimeline)
+	}
+	return layout, loc, nil
+}
+
+// Timeline 按拍摄时间倒序分页列出用户的照片与视频，并按月或日分组。
+// cursor 为上一页返回的 next_cursor 或日期统计中的 cursor，为空时从最新的开始；没有更多时 next_cursor 为空
+func (s *FileService) Timeline(userID uint, opts TimelineOptions, cursor string, limit int) ([]*TimelineBucket, string, error) {
+	layout, loc, err := timelineLayout(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	var after *repository.TimelineCursor
+	if cursor != "" {
+		if after, err = decodeTimelineCursor(cursor); err != nil {
+			return nil, "", err
+		}
+	}
+	clock := repository.NewWallClock(loc)
+	// 多取一个用于判断是否还有下一页
+	files, err := s.fileRepo.Timeline(userID, opts.Media, clock, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	more := len(files) > limit
+	if more {
+		files = files[:limit]
+	}
+	metas, err := s.attachMetadata(files)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buckets := make([]*TimelineBucket, 0)
+	for _, f := range files {
+		item := &TimelineItem{File: f, CapturedAt: f.CreatedAt}
+		if meta := metas[f.BlobID]; meta != nil && meta.TakenAt != nil {
+			item.CapturedAt = *meta.TakenAt
+			if meta.TakenAtLocal != nil && *meta.TakenAtLocal {
+				// 未记录时区的拍摄时间是当地时间，按所选时区解释，不做换算
+				item.CapturedAt = clock.Instant(*meta.TakenAt).In(loc)
+			}
+		}
+		date := item.CapturedAt.In(loc).Format(layout)
+		if n := len(buckets); n == 0 || buckets[n-1].Date != date {
+			buckets = append(buckets, &TimelineBucket{Date: date})
+		}
+		bucket := buckets[len(buckets)-1]
+		bucket.Items = append(bucket.Items, item)
+		bucket.Count++
+	}
+	next := ""
+	if more {
+		last := buckets[len(buckets)-1].Items
+		item := last[len(last)-1]
+		next = encodeTimelineCursor(item.CapturedAt, item.ID)
+	}
+	return buckets, next, nil
+}
+
+// TimelineBuckets 统计用户每月或每天的照片与视频数量，按日期倒序。数据库按 15 分钟区间汇总，
+// 再按所选时区归入日期，不读取每个文件
+func (s *FileService) TimelineBuckets(userID uint, opts TimelineOptions) ([]*TimelineBucket, error) {
+	layout, loc, err := timelineLayout(opts)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := s.fileRepo.CapturedSlots(userID, opts.Media, repository.NewWallClock(loc))
+	if err != nil {
+		return nil, err
+	}
+	type bucketRange struct {
+		count int
+		end   time.Time // 该日期中最晚的时间之后
+	}
+	ranges := make(map[string]*bucketRange)
+	dates := make([]string, 0)
+	for _, slot := range slots {
+		date := slot.Start.In(loc).Format(layout)
+		r, ok := ranges[date]
+		if !ok {
+			start, _ := time.ParseInLocation(layout, date, loc)
+			end := start.AddDate(0, 1, 0)
+			if layout == time.DateOnly {
+				end = start.AddDate(0, 0, 1)
+			}
+			r = &bucketRange{end: end}
+			ranges[date] = r
+			dates = append(dates, date)
+		}
+		r.count += slot.Count
+	}
+	// 日期格式可以直接按字符串排序
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	buckets := make([]*TimelineBucket, len(dates))
+	for i, date := range dates {
+		r := ranges[date]
+		buckets[i] = &TimelineBucket{Date: date, Count: r.count, Cursor: encodeTimelineCursor(r.end, 0)}
+	}
+	return buckets, nil
+}
+
+// attachMetadata 为文件附带已提取的媒体元数据，返回按对象ID索引的元数据
+func (s *FileService) attachMetadata(files []*model.File) (map[uint]*model.MediaMetadata, error) {
+	blobIDs := make([]uint, 0, len(files))
+	for _, f := range files {
+		if f.BlobID != 0 {
+			blobIDs = append(blobIDs, f.BlobID)
+		}
+	}
+	metas, err := s.metaRepo.FindByBlobs(blobIDs)
+	if err != nil {
+		return nil, err
+	}
+	byBlob := make(map[uint]*model.MediaMetadata, len(metas))
+	for _, m := range metas {
+		if m.Kind != "" && m.Error == "" {
+			byBlob[m.BlobID] = m
+		}
+	}
+	for _, f := range files {
+		f.Metadata = byBlob[f.BlobID]
+	}
+	return byBlob, nil
+}
+
+// encodeTimelineCursor 将分页位置编码为不透明的字符串，时间统一为 UTC。
+// 查询时数据库按换算后的时间比较，与存储值和游标各自的时区无关
+func encodeTimelineCursor(t time.Time, fileID uint) string {
+	raw := t.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatUint(uint64(fileID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTimelineCursor(cursor string) (*repository.TimelineCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidTimeline)
+	}
+	ts, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidTimeline)
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidTimeline)
+	}
+	fileID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidTimeline)
+	}
+	return &repository.TimelineCursor{CapturedAt: t, FileID: uint(fileID)}, nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"online-disk-server/internal/model"
+)
+
+func TestDecodeTimelineCursor(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	captured := time.Date(2024, 5, 1, 1, 2, 3, 400, time.UTC)
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantID  uint
+		wantErr bool
+	}{
+		{name: "round trip", in: encodeTimelineCursor(captured, 42), want: captured, wantID: 42},
+		{name: "bucket cursor", in: encodeTimelineCursor(captured, 0), want: captured},
+		{name: "offset normalized", in: encodeTimelineCursor(captured.In(time.FixedZone("", 8*3600)), 7), want: captured, wantID: 7},
+		{name: "explicit offset", in: enc("2024-05-01T09:02:03+08:00,5"), want: time.Date(2024, 5, 1, 1, 2, 3, 0, time.UTC), wantID: 5},
+		{name: "empty", in: "", wantErr: true},
+		{name: "not base64", in: "!!!", wantErr: true},
+		{name: "padded base64", in: base64.URLEncoding.EncodeToString([]byte("2024-05-01T01:02:03Z,1")), wantErr: true},
+		{name: "missing id", in: enc("2024-05-01T01:02:03Z"), wantErr: true},
+		{name: "bad time", in: enc("2024-05-01,1"), wantErr: true},
+		{name: "negative id", in: enc("2024-05-01T01:02:03Z,-1"), wantErr: true},
+		{name: "id overflow", in: enc("2024-05-01T01:02:03Z,4294967296"), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := decodeTimelineCursor(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidTimeline) {
+				t.Errorf("%s: error = %v, want ErrInvalidTimeline", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !got.CapturedAt.Equal(tt.want) || got.FileID != tt.wantID {
+			t.Errorf("%s: got (%v, %d), want (%v, %d)", tt.name, got.CapturedAt, got.FileID, tt.want, tt.wantID)
+		}
+	}
+}
+
+func TestTimelineLocalTakenAt(t *testing.T) {
+	s, user := newTestFileService(t)
+	photo := func(name string, blobID uint, created time.Time, taken *time.Time, local bool) *model.File {
+		t.Helper()
+		f := &model.File{Name: name, Path: "/" + name, MimeType: "image/jpeg", UserID: user.ID, BlobID: blobID, CreatedAt: created}
+		if err := s.db.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+		if taken != nil {
+			meta := &model.MediaMetadata{BlobID: blobID, Kind: model.MediaImage, TakenAt: taken, TakenAtLocal: &local}
+			if err := s.db.Create(meta).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f
+	}
+	uploaded := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	wall := time.Date(2024, 5, 2, 0, 30, 0, 0, time.UTC) // 未记录时区：当地时间 5 月 2 日 00:30
+	zoned := time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC) // 纽约 5 月 1 日 22:00
+	local := photo("local.jpg", 1, uploaded, &wall, true)
+	abs := photo("zoned.jpg", 2, uploaded, &zoned, false)
+	plain := photo("plain.jpg", 3, time.Date(2024, 5, 2, 20, 0, 0, 0, time.FixedZone("", 8*3600)), nil, false)
+
+	opts := TimelineOptions{Group: TimelineByDay, TimeZone: "America/New_York"}
+	buckets, err := s.TimelineBuckets(user.ID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, b := range buckets {
+		got[b.Date] = b.Count
+	}
+	if len(buckets) != 2 || got["2024-05-02"] != 2 || got["2024-05-01"] != 1 {
+		t.Errorf("buckets = %v, want 2024-05-02: 2, 2024-05-01: 1", got)
+	}
+
+	// 逐个分页，顺序与分组都按纽约当地时间：plain 为 08:00，local 为 00:30，zoned 为前一天 22:00
+	want := []struct {
+		id   uint
+		date string
+	}{{plain.ID, "2024-05-02"}, {local.ID, "2024-05-02"}, {abs.ID, "2024-05-01"}}
+	cursor := ""
+	for i, w := range want {
+		page, next, err := s.Timeline(user.ID, opts, cursor, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || len(page[0].Items) != 1 {
+			t.Fatalf("page %d: got %d buckets, want 1 item", i, len(page))
+		}
+		item := page[0].Items[0]
+		if item.ID != w.id || page[0].Date != w.date {
+			t.Errorf("page %d = file %d on %s, want file %d on %s", i, item.ID, page[0].Date, w.id, w.date)
+		}
+		if item.ID == local.ID && item.CapturedAt.Format("15:04") != "00:30" {
+			t.Errorf("local captured_at = %v, want 00:30 wall clock", item.CapturedAt)
+		}
+		if (next == "") != (i == len(want)-1) {
+			t.Fatalf("page %d: next cursor = %q", i, next)
+		}
+		cursor = next
+	}
+
+	// 从日期统计的游标开始浏览，得到该日期及更早的文件
+	for _, b := range buckets {
+		if b.Date != "2024-05-01" {
+			continue
+		}
+		page, _, err := s.Timeline(user.ID, opts, b.Cursor, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].Date != "2024-05-01" || page[0].Items[0].ID != abs.ID {
+			t.Errorf("timeline from 2024-05-01 cursor = %+v", page)
+		}
+	}
+}
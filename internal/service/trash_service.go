@@ -0,0 +1,174 @@
+package service
+
+import (
+	"log"
+	"path"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// TrashService 回收站：列出、恢复、彻底删除，以及按保留期自动清理
+type TrashService struct {
+	db        *gorm.DB
+	fileRepo  *repository.FileRepository
+	files     *FileService
+	retention time.Duration
+}
+
+func NewTrashService(db *gorm.DB, files *FileService, retention time.Duration) *TrashService {
+	return &TrashService{
+		db:        db,
+		fileRepo:  repository.NewFileRepository(db),
+		files:     files,
+		retention: retention,
+	}
+}
+
+// List 分页列出回收站条目
+func (s *TrashService) List(userID uint, page, limit int) ([]*model.File, int64, error) {
+	offset := (page - 1) * limit
+	return s.fileRepo.FindTrashRoots(userID, offset, limit)
+}
+
+// Restore 恢复回收站条目到原位置；原目录已不存在时按原路径重新创建，重名时自动追加序号
+func (s *TrashService) Restore(userID, fileID uint) (*model.File, error) {
+	root, err := s.fileRepo.FindTrashRoot(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID := root.OriginalParentID
+	if parentID != 0 {
+		if _, err := s.files.GetFile(userID, parentID); err != nil {
+			parentID, err = s.files.EnsurePath(userID, 0, path.Dir(root.Path))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	name, err := s.files.uniqueName(userID, parentID, root.Name)
+	if err != nil {
+		return nil, err
+	}
+	fullPath, err := s.files.childPath(userID, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewFileRepository(tx)
+		if err := repo.RestoreTrash(root.ID); err != nil {
+			return err
+		}
+		if err := repo.UpdateFields(root.ID, map[string]interface{}{
+			"parent_id": parentID,
+			"name":      name,
+			"path":      fullPath,
+		}); err != nil {
+			return err
+		}
+		// 文件夹在回收站中保留了统计，恢复后整体计入新的上级文件夹
+		if err := addToAncestors(tx, userID, fullPath, statsOf(root)); err != nil {
+			return err
+		}
+		// 恢复到新位置的文件夹需要同步子项路径
+		if !root.IsDir || fullPath == root.Path {
+			return nil
+		}
+		subtree, err := collectSubtreeWith(repo, userID, root)
+		if err != nil {
+			return err
+		}
+		return rewriteSubtreePaths(tx, subtree, fullPath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.files.GetFile(userID, root.ID)
+}
+
+// DeletePermanently 彻底删除回收站条目并释放存储
+func (s *TrashService) DeletePermanently(userID, fileID uint) error {
+	root, err := s.fileRepo.FindTrashRoot(fileID, userID)
+	if err != nil {
+		return err
+	}
+	return s.destroy(root)
+}
+
+// Empty 清空用户的回收站，返回删除的条目数
+func (s *TrashService) Empty(userID uint) (int, error) {
+	count := 0
+	for {
+		roots, _, err := s.fileRepo.FindTrashRoots(userID, 0, 100)
+		if err != nil {
+			return count, err
+		}
+		if len(roots) == 0 {
+			return count, nil
+		}
+		for _, root := range roots {
+			if err := s.destroy(root); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// PurgeExpired 彻底删除超过保留期的回收站条目，返回删除的条目数
+func (s *TrashService) PurgeExpired() (int, error) {
+	count := 0
+	for {
+		roots, err := s.fileRepo.FindExpiredTrashRoots(time.Now().Add(-s.retention), 100)
+		if err != nil {
+			return count, err
+		}
+		if len(roots) == 0 {
+			return count, nil
+		}
+		for _, root := range roots {
+			if err := s.destroy(root); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// StartPurge 启动后台任务，定期清理过期的回收站条目
+func (s *TrashService) StartPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := s.PurgeExpired()
+			if err != nil {
+				log.Printf("trash purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("trash purge: removed %d expired items", n)
+			}
+		}
+	}()
+}
+
+func (s *TrashService) destroy(root *model.File) error {
+	files, err := s.fileRepo.FindByTrashRoot(root.ID)
+	if err != nil {
+		return err
+	}
+	var garbage *storageGarbage
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		garbage, err = s.files.destroyFiles(tx, files)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.files.purgeStorage(garbage)
+	return nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"online-disk-server/internal/model"
+)
+
+const (
+	// instantProofLength 秒传证明需要提交哈希的字节数
+	instantProofLength int64 = 256 << 10
+	// instantChallengeTTL 挑战有效期
+	instantChallengeTTL = 5 * time.Minute
+)
+
+var (
+	ErrChallengeNotFound = errors.New("challenge not found or expired")
+	ErrProofMismatch     = errors.New("content proof mismatch")
+)
+
+// InstantCheck 秒传预检：无论服务器上是否已有相同内容（SHA-256 + 大小），都返回一个需要客户端证明的字节区间，
+// 避免预检本身泄露其他用户是否存有某个文件。是否命中只在 InstantUpload 证明通过后才能得知
+func (s *UploadService) InstantCheck(userID uint, size int64, sha256Hex string) (*model.InstantChallenge, error) {
+	sha256Hex = strings.ToLower(sha256Hex)
+	if size < 0 || !isSHA256Hex(sha256Hex) {
+		return nil, errors.New("invalid size or sha256")
+	}
+	var blobID uint
+	if blob, err := s.files.blobs.FindByHash(sha256Hex); err == nil && blob.Size == size {
+		blobID = blob.ID
+	}
+
+	// 服务器随机选择一段区间，防止仅凭哈希值冒领他人文件
+	length := instantProofLength
+	if length > size {
+		length = size
+	}
+	var offset int64
+	if size > length {
+		n, err := rand.Int(rand.Reader, big.NewInt(size-length+1))
+		if err != nil {
+			return nil, err
+		}
+		offset = n.Int64()
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	challenge := &model.InstantChallenge{
+		ID:        id,
+		UserID:    userID,
+		SHA256:    sha256Hex,
+		Size:      size,
+		BlobID:    blobID,
+		Offset:    offset,
+		Length:    length,
+		ExpiresAt: time.Now().Add(instantChallengeTTL),
+	}
+	if err := s.uploadRepo.CreateChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// InstantUpload 校验区间证明（该区间字节的 SHA-256 十六进制值），通过后直接引用已有内容创建文件记录。
+// 服务器上没有相同内容时与证明错误一样返回 ErrProofMismatch，客户端应改走普通上传
+func (s *UploadService) InstantUpload(userID uint, challengeID, proof, name string, parentID uint, policy ConflictPolicy) (*model.File, error) {
+	name = filepath.Base(name)
+	if name == "." || name == "/" || name == ".." {
+		return nil, errors.New("invalid file name")
+	}
+	// 与普通上传一致：放入被授予编辑权限的文件夹时，文件归该文件夹的所有者
+	ownerID, err := s.files.folderOwner(userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.files.childPath(ownerID, parentID, name); err != nil {
+		return nil, err
+	}
+	res, err := s.files.resolveConflict(ownerID, parentID, name, false, policy, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.uploadRepo.TakeChallenge(challengeID, userID)
+	if err != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrChallengeNotFound
+	}
+	if challenge.BlobID == 0 {
+		return nil, ErrProofMismatch
+	}
+	blob, err := s.files.blobs.FindByID(challenge.BlobID)
+	if err != nil {
+		return nil, ErrProofMismatch
+	}
+	expected, err := s.rangeDigest(blob.StoragePath, challenge.Offset, challenge.Length)
+	if err != nil {
+		return nil, err
+	}
+	// 按字节常量时间比较，避免通过响应时间逐位猜出证明
+	got, err := hex.DecodeString(proof)
+	if err != nil || subtle.ConstantTimeCompare(expected, got) != 1 {
+		return nil, ErrProofMismatch
+	}
+	if res.skip != nil {
+		return res.skip, nil
+	}
+
+	// 引用已有对象，不传输任何数据；秒传的文件同样计入配额
+	if err := s.files.reserveQuota(ownerID, blob.Size); err != nil {
+		return nil, err
+	}
+	if err := s.files.blobs.RetainByID(nil, blob.ID); err != nil {
+		s.files.releaseQuota(ownerID, blob.Size)
+		return nil, ErrChallengeNotFound
+	}
+	return s.files.commitFile(ownerID, parentID, res, &model.File{
+		Size:        blob.Size,
+		MimeType:    mime.TypeByExtension(filepath.Ext(name)),
+		Hash:        blob.MD5,
+		SHA256:      blob.Hash,
+		StoragePath: blob.StoragePath,
+		BlobID:      blob.ID,
+	})
+}
+
+// rangeDigest 计算存储对象中 [offset, offset+length) 区间的 SHA-256，只读取该区间
+func (s *UploadService) rangeDigest(storagePath string, offset, length int64) ([]byte, error) {
+	reader, err := s.storage.DownloadRange(storagePath, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	h := sha256.New()
+	if _, err := io.CopyN(h, reader, length); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
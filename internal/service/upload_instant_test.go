@@ -0,0 +1,46 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstantUploadProof(t *testing.T) {
+	files, user := newTestFileService(t)
+	s := NewUploadService(files.db, files.storage, files, time.Hour)
+	content := strings.Repeat("0123456789abcdef", 40<<10) // 640 KiB，大于证明区间
+	stored := storeText(t, files, user.ID, 0, "orig.bin", content)
+
+	proofOf := func(offset, length int64) string {
+		sum := sha256.Sum256([]byte(content[offset : offset+length]))
+		return hex.EncodeToString(sum[:])
+	}
+	tests := []struct {
+		name  string
+		proof func(offset, length int64) string
+		want  error
+	}{
+		{name: "not hex", proof: func(int64, int64) string { return "zz" }, want: ErrProofMismatch},
+		{name: "wrong range", proof: func(offset, length int64) string { return proofOf(offset+1, length-1) }, want: ErrProofMismatch},
+		{name: "upper case", proof: func(offset, length int64) string { return strings.ToUpper(proofOf(offset, length)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, err := s.InstantCheck(user.ID, int64(len(content)), stored.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := s.InstantUpload(user.ID, challenge.ID, tt.proof(challenge.Offset, challenge.Length), tt.name+".bin", 0, ConflictFail)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && file.BlobID != stored.BlobID {
+				t.Errorf("blob = %d, want %d", file.BlobID, stored.BlobID)
+			}
+		})
+	}
+}
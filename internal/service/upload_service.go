@@ -0,0 +1,423 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path/filepath"
+	"time"
+
+	"online-disk-server/internal/model"
+	"online-disk-server/internal/repository"
+	"online-disk-server/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultChunkSize 客户端未指定时的分片大小
+	DefaultChunkSize int64 = 8 << 20
+	// MinChunkSize / MaxChunkSize 允许的分片大小范围
+	MinChunkSize int64 = 256 << 10
+	MaxChunkSize int64 = 128 << 20
+	// MaxTotalChunks 单个会话允许的最大分片数
+	MaxTotalChunks = 10000
+)
+
+var (
+	ErrSessionNotFound  = errors.New("upload session not found")
+	ErrSessionExpired   = errors.New("upload session expired")
+	ErrSessionCompleted = errors.New("upload session already completed")
+	ErrInvalidChunk     = errors.New("invalid chunk")
+	ErrChunkChecksum    = errors.New("chunk checksum mismatch")
+	ErrChunksMissing    = errors.New("upload incomplete, missing chunks")
+)
+
+// UploadService 分片上传（断点续传）服务
+type UploadService struct {
+	db         *gorm.DB
+	uploadRepo *repository.UploadRepository
+	files      *FileService
+	storage    storage.Storage
+	ttl        time.Duration
+}
+
+func NewUploadService(db *gorm.DB, storage storage.Storage, files *FileService, ttl time.Duration) *UploadService {
+	return &UploadService{
+		db:         db,
+		uploadRepo: repository.NewUploadRepository(db),
+		files:      files,
+		storage:    storage,
+		ttl:        ttl,
+	}
+}
+
+// InitSession 创建上传会话，policy 在完成上传时用于处理同名文件
+func (s *UploadService) InitSession(userID, parentID uint, fileName, mimeType string, size, chunkSize int64, policy ConflictPolicy) (*model.UploadSession, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == "/" || fileName == ".." {
+		return nil, errors.New("invalid file name")
+	}
+	if size < 0 {
+		return nil, errors.New("invalid file size")
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between %d and %d", MinChunkSize, MaxChunkSize)
+	}
+	totalChunks := int((size + chunkSize - 1) / chunkSize)
+	if totalChunks == 0 {
+		// 空文件也需要上传一个空分片
+		totalChunks = 1
+	}
+	if totalChunks > MaxTotalChunks {
+		return nil, fmt.Errorf("too many chunks (max %d), use a larger chunk size", MaxTotalChunks)
+	}
+	ownerID, err := s.checkTarget(userID, parentID, fileName, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	session := &model.UploadSession{
+		ID:          id,
+		UserID:      userID,
+		ParentID:    parentID,
+		FileName:    fileName,
+		MimeType:    mimeType,
+		OnConflict:  string(policy),
+		Protocol:    model.UploadProtocolChunked,
+		Size:        size,
+		ChunkSize:   chunkSize,
+		TotalChunks: totalChunks,
+		Status:      model.UploadStatusUploading,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	}
+	if err := s.createSession(session, ownerID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// createSession 在所有者名下为会话声明的大小预留配额后保存会话。总大小已知，
+// 传输开始前预留，已写入存储的分片也计入用量，同时打开的多个会话不会超出配额
+func (s *UploadService) createSession(session *model.UploadSession, ownerID uint) error {
+	if err := s.files.reserveQuota(ownerID, session.Size); err != nil {
+		return err
+	}
+	session.QuotaUserID = ownerID
+	session.ReservedBytes = session.Size
+	if err := s.uploadRepo.CreateSession(session); err != nil {
+		s.files.releaseQuota(ownerID, session.Size)
+		return err
+	}
+	return nil
+}
+
+// checkTarget 创建会话前确认目标目录存在且可写入（含被授予编辑权限的他人文件夹），返回文件的所有者；
+// 冲突策略为 fail 时提前拒绝同名文件，避免上传完成后才失败
+func (s *UploadService) checkTarget(userID, parentID uint, fileName string, policy ConflictPolicy) (uint, error) {
+	ownerID, err := s.files.folderOwner(userID, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if policy == ConflictFail {
+		if _, err := s.files.fileRepo.FindChildByName(ownerID, parentID, fileName); err == nil {
+			return 0, ErrNameConflict
+		}
+	}
+	return ownerID, nil
+}
+
+// GetSession 获取会话及已接收的分片
+func (s *UploadService) GetSession(userID uint, sessionID string) (*model.UploadSession, []*model.UploadChunk, error) {
+	session, err := s.findActiveSession(userID, sessionID)
+	if err != nil && !errors.Is(err, ErrSessionCompleted) {
+		return nil, nil, err
+	}
+	chunks, err := s.uploadRepo.FindChunks(session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, chunks, nil
+}
+
+// PutChunk 写入编号为 index 的分片；contentMD5 为可选的 base64 编码 MD5 校验值
+func (s *UploadService) PutChunk(userID uint, sessionID string, index int, reader io.Reader, size int64, contentMD5 string) (*model.UploadChunk, error) {
+	session, err := s.findActiveSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Protocol == model.UploadProtocolTus || index < 0 || index >= session.TotalChunks {
+		return nil, ErrInvalidChunk
+	}
+	offset := int64(index) * session.ChunkSize
+	expected := session.ChunkSize
+	if offset+expected > session.Size {
+		expected = session.Size - offset
+	}
+	if size >= 0 && size != expected {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidChunk, expected, size)
+	}
+
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(io.LimitReader(reader, expected), hash)}
+	chunkPath := chunkStoragePath(session.ID, index)
+	if err := s.storage.Upload(chunkPath, counter, expected); err != nil {
+		s.storage.Delete(chunkPath)
+		return nil, err
+	}
+	if counter.n != expected {
+		s.storage.Delete(chunkPath)
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidChunk, expected, counter.n)
+	}
+	sum := hash.Sum(nil)
+	if contentMD5 != "" && contentMD5 != base64.StdEncoding.EncodeToString(sum) {
+		s.storage.Delete(chunkPath)
+		return nil, ErrChunkChecksum
+	}
+
+	chunk := &model.UploadChunk{
+		CreatedAt:  time.Now(),
+		SessionID:  session.ID,
+		ChunkIndex: index,
+		Offset:     offset,
+		Size:       expected,
+		Hash:       hex.EncodeToString(sum),
+	}
+	if err := s.uploadRepo.SaveChunk(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// Complete 合并所有分片并生成文件记录
+func (s *UploadService) Complete(userID uint, sessionID string) (*model.File, error) {
+	session, err := s.findActiveSession(userID, sessionID)
+	if errors.Is(err, ErrSessionCompleted) {
+		// 重复调用时直接返回已生成的文件
+		return s.files.GetFile(userID, session.FileID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := s.uploadRepo.FindChunks(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) != session.TotalChunks {
+		return nil, fmt.Errorf("%w: received %d of %d", ErrChunksMissing, len(chunks), session.TotalChunks)
+	}
+
+	return s.finalize(session, chunks)
+}
+
+// finalize 按顺序拼接分片生成文件记录，并清理分片
+func (s *UploadService) finalize(session *model.UploadSession, chunks []*model.UploadChunk) (*model.File, error) {
+	paths := make([]string, len(chunks))
+	for i, c := range chunks {
+		paths[i] = chunkStoragePath(session.ID, c.ChunkIndex)
+	}
+	open := func() (io.ReadCloser, error) {
+		return &chunkReader{storage: s.storage, paths: paths}, nil
+	}
+	policy, _ := ParseConflictPolicy(session.OnConflict, s.files.ConflictPolicy())
+	// 与普通上传一致：上传到被授予编辑权限的文件夹时，文件归该文件夹的所有者。合并时重新检查权限
+	ownerID, err := s.files.folderOwner(session.UserID, session.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	// 会话的预留交给 storeReserved，由它计入文件或在失败时归还；先从会话中清除，避免过期清理时再次归还
+	reserved, quotaUserID := session.ReservedBytes, session.QuotaUserID
+	if reserved != 0 {
+		session.ReservedBytes = 0
+		if err := s.uploadRepo.UpdateSession(session); err != nil {
+			session.ReservedBytes = reserved
+			return nil, err
+		}
+		if quotaUserID != ownerID {
+			// 文件夹的所有者已变化，预留归还原用户，按新的所有者重新预留
+			s.files.releaseQuota(quotaUserID, reserved)
+			reserved = 0
+		}
+	}
+	file, err := s.files.storeReserved(ownerID, session.ParentID, session.FileName, session.MimeType, policy, reserved, open)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Status = model.UploadStatusCompleted
+	session.FileID = file.ID
+	if err := s.uploadRepo.UpdateSession(session); err != nil {
+		return nil, err
+	}
+	s.removeChunks(session.ID, paths)
+	return file, nil
+}
+
+// Abort 取消上传并清理已上传的分片
+func (s *UploadService) Abort(userID uint, sessionID string) error {
+	session, err := s.uploadRepo.FindSession(sessionID, userID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	return s.purgeSession(session)
+}
+
+// CleanupExpired 清理过期会话及其残留分片，返回清理的会话数
+func (s *UploadService) CleanupExpired() (int, error) {
+	if err := s.uploadRepo.DeleteExpiredChallenges(time.Now()); err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		sessions, err := s.uploadRepo.FindExpiredSessions(time.Now(), 100)
+		if err != nil {
+			return count, err
+		}
+		if len(sessions) == 0 {
+			return count, nil
+		}
+		for _, session := range sessions {
+			if err := s.purgeSession(session); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// StartCleanup 启动后台任务，定期清理过期会话
+func (s *UploadService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := s.CleanupExpired()
+			if err != nil {
+				log.Printf("upload session cleanup failed: %v", err)
+			} else if n > 0 {
+				log.Printf("upload session cleanup: removed %d expired sessions", n)
+			}
+		}
+	}()
+}
+
+func (s *UploadService) findActiveSession(userID uint, sessionID string) (*model.UploadSession, error) {
+	session, err := s.uploadRepo.FindSession(sessionID, userID)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	if session.Status == model.UploadStatusCompleted {
+		return session, ErrSessionCompleted
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return session, nil
+}
+
+func (s *UploadService) purgeSession(session *model.UploadSession) error {
+	chunks, err := s.uploadRepo.FindChunks(session.ID)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, len(chunks))
+	for i, c := range chunks {
+		paths[i] = chunkStoragePath(session.ID, c.ChunkIndex)
+	}
+	s.removeChunks(session.ID, paths)
+	tusSessionLocks.Delete(session.ID)
+	if err := s.uploadRepo.DeleteSession(session.ID); err != nil {
+		return err
+	}
+	// 未完成的会话归还创建时预留的配额
+	s.files.releaseQuota(session.QuotaUserID, session.ReservedBytes)
+	return nil
+}
+
+func (s *UploadService) removeChunks(sessionID string, paths []string) {
+	for _, p := range paths {
+		// 存储删除失败不影响流程，残留对象可后续清理
+		s.storage.Delete(p)
+	}
+	s.uploadRepo.DeleteChunks(sessionID)
+}
+
+func chunkStoragePath(sessionID string, index int) string {
+	return fmt.Sprintf("uploads/%s/%d", sessionID, index)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// countingReader 统计实际读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// chunkReader 按顺序依次读取存储中的分片，同一时刻只打开一个分片
+type chunkReader struct {
+	storage storage.Storage
+	paths   []string
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.paths) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := r.storage.Download(r.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current = rc
+			r.paths = r.paths[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
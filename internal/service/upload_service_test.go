@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wantUsedBytes 检查用户当前的已用空间
+func wantUsedBytes(t *testing.T, s *FileService, userID uint, want int64) {
+	t.Helper()
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UsedBytes != want {
+		t.Errorf("used bytes = %d, want %d", user.UsedBytes, want)
+	}
+}
+
+func TestUploadSessionReservesQuota(t *testing.T) {
+	files, user := newTestFileService(t)
+	quota := int64(10)
+	if err := files.db.Model(user).Update("quota_bytes", quota).Error; err != nil {
+		t.Fatal(err)
+	}
+	s := NewUploadService(files.db, files.storage, files, time.Hour)
+
+	first, err := s.CreateTusSession(user.ID, 0, "a.txt", "text/plain", 6, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+	// 已打开的会话占用配额，后续会话不能超出
+	if _, err := s.InitSession(user.ID, 0, "b.txt", "text/plain", 6, 0, ConflictFail); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second session: err = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.CreateTusSession(user.ID, 0, "b.txt", "text/plain", 6, ConflictFail); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second tus session: err = %v, want ErrQuotaExceeded", err)
+	}
+
+	// 重启时重新统计保留进行中会话的预留
+	if err := files.RecalculateUsage(); err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+
+	// 完成时预留转为文件用量，不重复计算
+	_, file, err := s.AppendChunk(user.ID, first.ID, 0, strings.NewReader("abcdef"), 6, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil {
+		t.Fatal("upload did not finish")
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+	if err := files.RecalculateUsage(); err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+
+	// 取消与过期时归还预留
+	second, err := s.InitSession(user.ID, 0, "b.txt", "text/plain", 4, 0, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 10)
+	if err := s.Abort(user.ID, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+	third, err := s.CreateTusSession(user.ID, 0, "c.txt", "text/plain", 4, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := s.uploadRepo.UpdateSession(third); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CleanupExpired(); err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 6)
+}
+
+func TestUploadSessionFailedCompleteReleasesReservation(t *testing.T) {
+	files, user := newTestFileService(t)
+	s := NewUploadService(files.db, files.storage, files, time.Hour)
+
+	session, err := s.CreateTusSession(user.ID, 0, "a.txt", "text/plain", 3, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 合并前目标位置出现同名文件，合并失败
+	storeText(t, files, user.ID, 0, "a.txt", "xy")
+	wantUsedBytes(t, files, user.ID, 5)
+	if _, _, err := s.AppendChunk(user.ID, session.ID, 0, strings.NewReader("abc"), 3, "", nil); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("finish: err = %v, want ErrNameConflict", err)
+	}
+	wantUsedBytes(t, files, user.ID, 2)
+	// 会话的预留已归还，过期清理不再重复归还
+	if err := s.Abort(user.ID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	wantUsedBytes(t, files, user.ID, 2)
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"online-disk-server/internal/model"
+)
+
+var (
+	ErrOffsetMismatch      = errors.New("upload offset mismatch")
+	ErrUploadTooLarge      = errors.New("upload exceeds declared length")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+	ErrUploadLocked        = errors.New("upload is being written by another request")
+)
+
+// tusSessionLocks 防止同一上传被并发 PATCH
+var tusSessionLocks sync.Map
+
+// TusChecksumAlgorithms 支持的 Upload-Checksum 算法
+var TusChecksumAlgorithms = []string{"sha1", "md5", "sha256"}
+
+func newChecksumHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	}
+	return nil, ErrUnsupportedChecksum
+}
+
+// CreateTusSession 创建 tus 上传，文件长度必须在创建时给出
+func (s *UploadService) CreateTusSession(userID, parentID uint, fileName, mimeType string, size int64, policy ConflictPolicy) (*model.UploadSession, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == "/" || fileName == ".." {
+		return nil, errors.New("invalid file name")
+	}
+	if size < 0 {
+		return nil, errors.New("invalid upload length")
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+	ownerID, err := s.checkTarget(userID, parentID, fileName, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	session := &model.UploadSession{
+		ID:         id,
+		UserID:     userID,
+		ParentID:   parentID,
+		FileName:   fileName,
+		MimeType:   mimeType,
+		OnConflict: string(policy),
+		Protocol:   model.UploadProtocolTus,
+		Size:       size,
+		Status:     model.UploadStatusUploading,
+		ExpiresAt:  time.Now().Add(s.ttl),
+	}
+	if err := s.createSession(session, ownerID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// AppendChunk 在 offset 处追加数据（tus PATCH）。size 为请求体长度，未知时传 -1；
+// checksumAlg/checksum 为可选的 Upload-Checksum，校验失败时丢弃本次数据。
+// 未带校验的请求中途断开时保留已收到的数据并推进 offset，保存后再返回读取错误。
+// 上传达到声明长度时自动合并并返回生成的文件。
+func (s *UploadService) AppendChunk(userID uint, sessionID string, offset int64, reader io.Reader, size int64, checksumAlg string, checksum []byte) (*model.UploadSession, *model.File, error) {
+	// 先确认会话存在且属于该用户再加锁，不为不存在或他人的会话留下锁
+	session, err := s.findActiveSession(userID, sessionID)
+	if err != nil {
+		return session, nil, err
+	}
+	if session.Protocol != model.UploadProtocolTus {
+		return nil, nil, ErrSessionNotFound
+	}
+	lock, _ := tusSessionLocks.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, nil, ErrUploadLocked
+	}
+	defer mu.Unlock()
+
+	// 加锁前会话可能已被其他请求推进、完成或取消，以加锁后读到的为准
+	session, err = s.findActiveSession(userID, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			tusSessionLocks.Delete(sessionID)
+		}
+		return session, nil, err
+	}
+	if offset != session.Offset {
+		return session, nil, ErrOffsetMismatch
+	}
+	remaining := session.Size - session.Offset
+	if size > remaining {
+		return session, nil, ErrUploadTooLarge
+	}
+	if size < 0 {
+		size = remaining
+	}
+
+	var sum hash.Hash
+	body := &interruptedReader{r: reader}
+	var r io.Reader = io.LimitReader(body, size)
+	if checksumAlg != "" {
+		if sum, err = newChecksumHash(checksumAlg); err != nil {
+			return session, nil, err
+		}
+		r = io.TeeReader(r, sum)
+	}
+	chunks, err := s.uploadRepo.FindChunks(session.ID)
+	if err != nil {
+		return session, nil, err
+	}
+	index := len(chunks)
+	counter := &countingReader{r: r}
+	chunkPath := chunkStoragePath(session.ID, index)
+	if err := s.storage.Upload(chunkPath, counter, -1); err != nil {
+		s.storage.Delete(chunkPath)
+		return session, nil, err
+	}
+	if sum != nil && body.err != nil {
+		// 不完整的数据无法通过校验
+		s.storage.Delete(chunkPath)
+		return session, nil, body.err
+	}
+	if sum != nil && !bytes.Equal(sum.Sum(nil), checksum) {
+		s.storage.Delete(chunkPath)
+		return session, nil, ErrChunkChecksum
+	}
+	if counter.n == 0 {
+		s.storage.Delete(chunkPath)
+	} else {
+		chunk := &model.UploadChunk{
+			CreatedAt:  time.Now(),
+			SessionID:  session.ID,
+			ChunkIndex: index,
+			Offset:     session.Offset,
+			Size:       counter.n,
+		}
+		if sum != nil {
+			chunk.Hash = hex.EncodeToString(checksum)
+		}
+		if err := s.uploadRepo.SaveChunk(chunk); err != nil {
+			s.storage.Delete(chunkPath)
+			return session, nil, err
+		}
+		session.Offset += counter.n
+		chunks = append(chunks, chunk)
+		if err := s.uploadRepo.UpdateSession(session); err != nil {
+			return session, nil, err
+		}
+	}
+
+	if body.err != nil {
+		return session, nil, body.err
+	}
+	if session.Offset < session.Size {
+		return session, nil, nil
+	}
+	file, err := s.finalize(session, chunks)
+	if err != nil {
+		return session, nil, fmt.Errorf("finalize upload: %w", err)
+	}
+	tusSessionLocks.Delete(sessionID)
+	return session, file, nil
+}
+
+// interruptedReader 记录请求体的读取错误（如客户端断开）并将其当作 EOF 返回，
+// 使存储保留断开前已收到的数据
+type interruptedReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+		err = io.EOF
+	}
+	return n, err
+}
+
+// FinishEmptyTusSession 长度为 0 的上传无需 PATCH，创建后直接生成文件
+func (s *UploadService) FinishEmptyTusSession(session *model.UploadSession) (*model.File, error) {
+	if session.Size != 0 {
+		return nil, ErrChunksMissing
+	}
+	return s.finalize(session, nil)
+}
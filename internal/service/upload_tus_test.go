@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tusLockHeld 判断是否仍保留会话的 PATCH 锁
+func tusLockHeld(sessionID string) bool {
+	_, ok := tusSessionLocks.Load(sessionID)
+	return ok
+}
+
+func TestAppendChunkLocks(t *testing.T) {
+	files, user := newTestFileService(t)
+	s := NewUploadService(files.db, files.storage, files, time.Hour)
+
+	// 不存在的会话与他人的会话不留下锁
+	if _, _, err := s.AppendChunk(user.ID, "missing", 0, strings.NewReader("x"), 1, "", nil); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("append to missing session: err = %v, want ErrSessionNotFound", err)
+	}
+	if tusLockHeld("missing") {
+		t.Error("lock left for missing session")
+	}
+	session, err := s.CreateTusSession(user.ID, 0, "a.txt", "text/plain", 4, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AppendChunk(user.ID+1, session.ID, 0, strings.NewReader("ab"), 2, "", nil); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("append to other user's session: err = %v, want ErrSessionNotFound", err)
+	}
+	if tusLockHeld(session.ID) {
+		t.Error("lock left for other user's session")
+	}
+
+	// 取消后释放锁
+	if _, _, err := s.AppendChunk(user.ID, session.ID, 0, strings.NewReader("ab"), 2, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Abort(user.ID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if tusLockHeld(session.ID) {
+		t.Error("lock left after abort")
+	}
+
+	// 过期清理后释放锁
+	session, err = s.CreateTusSession(user.ID, 0, "b.txt", "text/plain", 4, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AppendChunk(user.ID, session.ID, 0, strings.NewReader("ab"), 2, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := s.uploadRepo.UpdateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CleanupExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if tusLockHeld(session.ID) {
+		t.Error("lock left after expiry cleanup")
+	}
+
+	// 完成后释放锁
+	session, err = s.CreateTusSession(user.ID, 0, "c.txt", "text/plain", 4, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AppendChunk(user.ID, session.ID, 0, strings.NewReader("ab"), 2, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	_, file, err := s.AppendChunk(user.ID, session.ID, 2, strings.NewReader("cd"), 2, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil || file.Size != 4 {
+		t.Fatalf("finished upload = %+v, want a 4 byte file", file)
+	}
+	if tusLockHeld(session.ID) {
+		t.Error("lock left after upload finished")
+	}
+}
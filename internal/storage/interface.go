@@ -12,6 +12,9 @@ type Storage interface {
 	// Download 下载文件
 	Download(path string) (io.ReadCloser, error)
 
+	// DownloadRange 从 offset 开始读取 length 字节，length 小于 0 时读到文件末尾
+	DownloadRange(path string, offset, length int64) (io.ReadCloser, error)
+
 	// Delete 删除文件
 	Delete(path string) error
 
@@ -21,3 +24,9 @@ type Storage interface {
 	// GetSize 获取文件大小
 	GetSize(path string) (int64, error)
 }
+
+// Copier 由支持服务端复制的存储实现，复制时无需经过应用读写数据
+type Copier interface {
+	// Copy 将 src 复制到 dst
+	Copy(src, dst string) error
+}
@@ -39,6 +39,21 @@ func (l *LocalStorage) Download(path string) (io.ReadCloser, error) {
 	return os.Open(fullPath)
 }
 
+func (l *LocalStorage) DownloadRange(path string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(l.basePath, path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
 func (l *LocalStorage) Delete(path string) error {
 	fullPath := filepath.Join(l.basePath, path)
 	return os.Remove(fullPath)
@@ -61,3 +76,12 @@ func (l *LocalStorage) GetSize(path string) (int64, error) {
 	}
 	return info.Size(), nil
 }
+
+func (l *LocalStorage) Copy(src, dst string) error {
+	in, err := os.Open(filepath.Join(l.basePath, src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return l.Upload(dst, in, -1)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// limitedReadCloser 限制读取长度并关闭底层对象
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// RangeReader 基于 DownloadRange 的可定位读取器（io.ReadSeeker、io.ReaderAt）：Seek 只移动位置，
+// 读取时才按当前位置打开一次到文件末尾的读取，位置跳转时重新打开
+type RangeReader struct {
+	storage Storage
+	path    string
+	size    int64
+
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewRangeReader 为大小为 size 的存储对象创建可定位读取器
+func NewRangeReader(s Storage, path string, size int64) *RangeReader {
+	return &RangeReader{storage: s, path: path, size: size}
+}
+
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.storage.DownloadRange(r.path, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	if remaining := r.size - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// ReadAt 读取 off 处的内容，连续的 ReadAt 复用同一读取流。
+// 与 Read/Seek 共用当前位置，不能并发调用
+func (r *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF && r.offset == r.size {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Size 返回对象大小
+func (r *RangeReader) Size() int64 {
+	return r.size
+}
+
+// Close 关闭当前打开的读取
+func (r *RangeReader) Close() error {
+	return r.closeBody()
+}
+
+func (r *RangeReader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"io"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -50,6 +51,25 @@ func (s *S3Storage) Download(path string) (io.ReadCloser, error) {
 	return s.client.GetObject(ctx, s.bucket, path, minio.GetObjectOptions{})
 }
 
+func (s *S3Storage) DownloadRange(path string, offset, length int64) (io.ReadCloser, error) {
+	ctx := context.Background()
+	opts := minio.GetObjectOptions{}
+	switch {
+	case length == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, err
+		}
+	case offset > 0:
+		// bytes=offset-
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	return s.client.GetObject(ctx, s.bucket, path, opts)
+}
+
 func (s *S3Storage) Delete(path string) error {
 	ctx := context.Background()
 	return s.client.RemoveObject(ctx, s.bucket, path, minio.RemoveObjectOptions{})
@@ -76,3 +96,13 @@ func (s *S3Storage) GetSize(path string) (int64, error) {
 	}
 	return info.Size, nil
 }
+
+// Copy 服务端复制；ComposeObject 在对象超过 5GB（CopyObject 上限）时自动分段复制
+func (s *S3Storage) Copy(src, dst string) error {
+	ctx := context.Background()
+	_, err := s.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: src},
+	)
+	return err
+}